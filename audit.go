@@ -0,0 +1,318 @@
+package jsencrypt
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Weak key detection for RSA public keys. These checks only look at the
+// modulus, so they work on keys imported from devices without access to the
+// private half.
+
+const (
+	// auditSmallPrimeBound is the largest prime tried by trial division.
+	auditSmallPrimeBound = 10000
+	// auditFermatRounds bounds the Fermat factorisation search. Keys with
+	// primes close enough to be generated by broken "next prime" routines
+	// are factored in the first few rounds.
+	auditFermatRounds = 1000
+)
+
+// AuditReport is the result of auditing a single public key.
+type AuditReport struct {
+	KeyID string
+	Bits  int
+	// ROCA reports whether the modulus has the structure produced by the
+	// Infineon RSALib key generator (CVE-2017-15361).
+	ROCA bool
+	// FermatFactorable reports whether the primes are close enough for
+	// Fermat's method to factor the modulus.
+	FermatFactorable bool
+	// SmallFactor is a prime factor below 10000, if one exists.
+	SmallFactor *big.Int
+	// SharedFactor is a prime factor shared with another audited key, as
+	// found by AuditKeys.
+	SharedFactor *big.Int
+	// SharedWith lists the indexes of the keys passed to AuditKeys that
+	// share a factor with this key.
+	SharedWith []int
+	// Findings describes every problem found, in human readable form.
+	Findings []string
+}
+
+// Vulnerable reports whether any weakness was found.
+func (r *AuditReport) Vulnerable() bool {
+	return len(r.Findings) > 0
+}
+
+// Audit checks the public key for the ROCA fingerprint, primes close enough
+// for Fermat factorisation, and small prime factors. It does not generate a
+// key; ErrNoKey is returned when none is set.
+func (j *JSEncrypt) Audit() (*AuditReport, error) {
+	pub, err := j.identityKey()
+	if err != nil {
+		return nil, err
+	}
+	return audit(pub), nil
+}
+
+// audit runs the single key checks on pub.
+func audit(pub *rsa.PublicKey) *AuditReport {
+	report := &AuditReport{Bits: pub.N.BitLen(), KeyID: jwkThumbprint(pub)}
+
+	n := pub.N
+	if report.SmallFactor = smallFactor(n); report.SmallFactor != nil {
+		report.Findings = append(report.Findings, fmt.Sprintf("modulus is divisible by the small prime %s", report.SmallFactor))
+	}
+	if isROCA(n) {
+		report.ROCA = true
+		report.Findings = append(report.Findings, "modulus has the ROCA fingerprint (CVE-2017-15361)")
+	}
+	if fermatFactor(n, auditFermatRounds) != nil {
+		report.FermatFactorable = true
+		report.Findings = append(report.Findings, "primes are close enough to be found by Fermat factorisation")
+	}
+	return report
+}
+
+// AuditKeys audits every key and additionally runs a batch GCD across all of
+// them to find moduli that share a prime factor, which happens when devices
+// generate keys with poor entropy. Reports are returned in input order.
+func AuditKeys(keys []*JSEncrypt) ([]*AuditReport, error) {
+	reports := make([]*AuditReport, len(keys))
+	// Identical moduli are grouped first, so duplicates are found without
+	// comparing every pair and the batch GCD only sees distinct moduli.
+	var moduli []*big.Int
+	var members [][]int
+	groups := make(map[string]int)
+	for i, key := range keys {
+		if key == nil {
+			return nil, fmt.Errorf("key %d is nil", i)
+		}
+		// The report and the modulus come from the same key snapshot.
+		pub, err := key.identityKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		reports[i] = audit(pub)
+		g, ok := groups[string(pub.N.Bytes())]
+		if !ok {
+			g = len(moduli)
+			groups[string(pub.N.Bytes())] = g
+			moduli = append(moduli, pub.N)
+			members = append(members, nil)
+		}
+		members[g] = append(members[g], i)
+	}
+
+	// A modulus shares a prime only with moduli whose batch GCD is not 1
+	// either, so only those are compared with each other.
+	var flagged []int
+	for g, d := range batchGCD(moduli) {
+		if d.Cmp(bigOne) != 0 {
+			flagged = append(flagged, g)
+		}
+	}
+	sharedFactors := make(map[int]map[int]*big.Int, len(flagged))
+	for _, g := range flagged {
+		sharedFactors[g] = make(map[int]*big.Int)
+		for _, h := range flagged {
+			if h == g {
+				continue
+			}
+			if f := new(big.Int).GCD(nil, nil, moduli[g], moduli[h]); f.Cmp(bigOne) != 0 {
+				sharedFactors[g][h] = f
+			}
+		}
+	}
+
+	for g, n := range moduli {
+		for _, i := range members[g] {
+			report := reports[i]
+			for _, k := range members[g] {
+				if k != i {
+					report.SharedWith = append(report.SharedWith, k)
+					report.SharedFactor = new(big.Int).Set(n)
+				}
+			}
+			for h, f := range sharedFactors[g] {
+				report.SharedWith = append(report.SharedWith, members[h]...)
+				if report.SharedFactor == nil || f.Cmp(report.SharedFactor) < 0 {
+					report.SharedFactor = new(big.Int).Set(f)
+				}
+			}
+			if report.SharedFactor == nil {
+				continue
+			}
+			sort.Ints(report.SharedWith)
+			if report.SharedFactor.Cmp(n) == 0 {
+				report.Findings = append(report.Findings, fmt.Sprintf("modulus is identical to key(s) %v", report.SharedWith))
+			} else {
+				report.Findings = append(report.Findings, fmt.Sprintf("modulus shares a prime factor with key(s) %v", report.SharedWith))
+			}
+		}
+	}
+	return reports, nil
+}
+
+var bigOne = big.NewInt(1)
+
+// smallPrimes returns all primes up to bound using a sieve of Eratosthenes.
+func smallPrimes(bound int) []int64 {
+	composite := make([]bool, bound+1)
+	var primes []int64
+	for i := 2; i <= bound; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, int64(i))
+		for k := i * i; k <= bound; k += i {
+			composite[k] = true
+		}
+	}
+	return primes
+}
+
+var auditPrimes = smallPrimes(auditSmallPrimeBound)
+
+// smallFactor returns the smallest prime factor of n below the trial
+// division bound, or nil.
+func smallFactor(n *big.Int) *big.Int {
+	m := new(big.Int)
+	for _, p := range auditPrimes {
+		bp := big.NewInt(p)
+		if m.Mod(n, bp).Sign() == 0 && n.Cmp(bp) != 0 {
+			return bp
+		}
+	}
+	return nil
+}
+
+// rocaPrimes are the small primes dividing the Infineon primorial M for
+// every supported key size.
+var rocaPrimes = []int64{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71,
+	73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151,
+	157, 163, 167,
+}
+
+// rocaSubgroups[i] marks the residues mod rocaPrimes[i] that lie in the
+// multiplicative subgroup generated by 65537.
+var rocaSubgroups = func() [][]bool {
+	groups := make([][]bool, len(rocaPrimes))
+	for i, p := range rocaPrimes {
+		group := make([]bool, p)
+		g := 65537 % p
+		for x := int64(1); !group[x]; x = x * g % p {
+			group[x] = true
+		}
+		groups[i] = group
+	}
+	return groups
+}()
+
+// isROCA reports whether n has the ROCA fingerprint. Vulnerable primes have
+// the form k*M + (65537^a mod M), so the modulus lies in the subgroup
+// generated by 65537 modulo every prime dividing M. A random modulus passes
+// this test with negligible probability.
+func isROCA(n *big.Int) bool {
+	m := new(big.Int)
+	for i, p := range rocaPrimes {
+		r := m.Mod(n, big.NewInt(p)).Int64()
+		if !rocaSubgroups[i][r] {
+			return false
+		}
+	}
+	return true
+}
+
+// fermatFactor tries to write n as a^2 - b^2 with a starting at ceil(sqrt(n)),
+// returning the factor a-b when found within rounds steps.
+func fermatFactor(n *big.Int, rounds int) *big.Int {
+	if n.Bit(0) == 0 {
+		return nil
+	}
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) < 0 {
+		a.Add(a, bigOne)
+	}
+
+	b2 := new(big.Int).Mul(a, a)
+	b2.Sub(b2, n)
+	b := new(big.Int)
+	step := new(big.Int)
+	for i := 0; i < rounds; i++ {
+		if isSquare(b2, b) {
+			p := new(big.Int).Sub(a, b)
+			if p.Cmp(bigOne) > 0 {
+				return p
+			}
+		}
+		// (a+1)^2 - n = a^2 - n + 2a + 1
+		step.Lsh(a, 1)
+		step.Add(step, bigOne)
+		b2.Add(b2, step)
+		a.Add(a, bigOne)
+	}
+	return nil
+}
+
+// isSquare reports whether x is a perfect square, storing the root in root.
+func isSquare(x, root *big.Int) bool {
+	if x.Sign() == 0 {
+		root.SetInt64(0)
+		return true
+	}
+	// Only 12 of the 64 residues mod 64 are squares; filter cheaply first.
+	switch x.Bits()[0] & 63 {
+	case 0, 1, 4, 9, 16, 17, 25, 33, 36, 41, 49, 57:
+	default:
+		return false
+	}
+	root.Sqrt(x)
+	return new(big.Int).Mul(root, root).Cmp(x) == 0
+}
+
+// batchGCD returns gcd(n_i, prod_{j!=i} n_j) for every modulus using
+// Bernstein's product and remainder trees.
+func batchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) == 0 {
+		return nil
+	}
+
+	// Product tree: levels[0] holds the moduli, the last level the product.
+	levels := [][]*big.Int{moduli}
+	for len(levels[len(levels)-1]) > 1 {
+		prev := levels[len(levels)-1]
+		next := make([]*big.Int, (len(prev)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(prev) {
+				next[i] = new(big.Int).Mul(prev[2*i], prev[2*i+1])
+			} else {
+				next[i] = prev[2*i]
+			}
+		}
+		levels = append(levels, next)
+	}
+
+	// Remainder tree: reduce the product modulo the square of every node.
+	rems := levels[len(levels)-1]
+	for l := len(levels) - 2; l >= 0; l-- {
+		level := levels[l]
+		next := make([]*big.Int, len(level))
+		for i, node := range level {
+			sq := new(big.Int).Mul(node, node)
+			next[i] = new(big.Int).Mod(rems[i/2], sq)
+		}
+		rems = next
+	}
+
+	out := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		q := new(big.Int).Quo(rems[i], n)
+		out[i] = q.GCD(nil, nil, q, n)
+	}
+	return out
+}
@@ -0,0 +1,173 @@
+package jsencrypt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func publicKeyInstance(n *big.Int) *JSEncrypt {
+	jsCrypt := NewJSEncrypt()
+	jsCrypt.publicKey = &rsa.PublicKey{N: n, E: 65537}
+	return jsCrypt
+}
+
+func randomPrime(t *testing.T, bits int) *big.Int {
+	p, err := rand.Prime(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestJSEncrypt_AuditCleanKey(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	if _, err := jsCrypt.Audit(); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Expected ErrNoKey, got %v", err)
+	}
+
+	if err := jsCrypt.SetPublicKey(exampleTestKeys.publicKey); err != nil {
+		t.Fatal(err)
+	}
+	report, err := jsCrypt.Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Vulnerable() {
+		t.Errorf("Expected no findings for a well generated key, got %v", report.Findings)
+	}
+	if report.Bits != 2048 || report.KeyID == "" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestJSEncrypt_AuditROCA(t *testing.T) {
+	// Build a modulus with the structure of RSALib primes:
+	// p = k*M + (65537^a mod M), where M is the primorial of 2..167.
+	m := big.NewInt(1)
+	for _, p := range append([]int64{2}, rocaPrimes...) {
+		m.Mul(m, big.NewInt(p))
+	}
+	rocaPrime := func(k, a int64) *big.Int {
+		g := new(big.Int).Exp(big.NewInt(65537), big.NewInt(a), m)
+		return g.Add(g, new(big.Int).Mul(big.NewInt(k), m))
+	}
+	n := new(big.Int).Mul(rocaPrime(0x1234567, 1001), rocaPrime(0x7654321, 2002))
+
+	report, err := publicKeyInstance(n).Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.ROCA {
+		t.Error("Expected the ROCA fingerprint to be detected")
+	}
+}
+
+func TestJSEncrypt_AuditFermat(t *testing.T) {
+	p := randomPrime(t, 512)
+	q := new(big.Int).Add(p, big.NewInt(2))
+	for !q.ProbablyPrime(20) {
+		q.Add(q, big.NewInt(2))
+	}
+
+	report, err := publicKeyInstance(new(big.Int).Mul(p, q)).Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.FermatFactorable || report.ROCA {
+		t.Errorf("Expected only Fermat finding, got %+v", report)
+	}
+}
+
+func TestJSEncrypt_AuditSmallFactor(t *testing.T) {
+	n := new(big.Int).Mul(big.NewInt(7919), randomPrime(t, 1000))
+	report, err := publicKeyInstance(n).Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SmallFactor == nil || report.SmallFactor.Int64() != 7919 {
+		t.Errorf("Expected small factor 7919, got %v", report.SmallFactor)
+	}
+}
+
+func TestAuditKeys_SharedFactors(t *testing.T) {
+	shared := randomPrime(t, 256)
+	keys := []*JSEncrypt{
+		publicKeyInstance(new(big.Int).Mul(shared, randomPrime(t, 256))),
+		publicKeyInstance(new(big.Int).Mul(randomPrime(t, 256), randomPrime(t, 256))),
+		publicKeyInstance(new(big.Int).Mul(shared, randomPrime(t, 256))),
+		publicKeyInstance(new(big.Int).Mul(randomPrime(t, 256), randomPrime(t, 256))),
+	}
+	// An exact duplicate of an otherwise clean key.
+	keys = append(keys, publicKeyInstance(keys[3].publicKey.N))
+
+	reports, err := AuditKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports[1].Vulnerable() {
+		t.Errorf("Key 1 should be clean, got %v", reports[1].Findings)
+	}
+	for _, i := range []int{0, 2} {
+		if reports[i].SharedFactor == nil || reports[i].SharedFactor.Cmp(shared) != 0 {
+			t.Errorf("Key %d: expected shared factor to be recovered, got %v", i, reports[i].SharedFactor)
+		}
+	}
+	if len(reports[0].SharedWith) != 1 || reports[0].SharedWith[0] != 2 {
+		t.Errorf("Key 0 should share a factor with key 2, got %v", reports[0].SharedWith)
+	}
+	if len(reports[4].SharedWith) != 1 || reports[4].SharedWith[0] != 3 || reports[4].SharedFactor.Cmp(keys[3].publicKey.N) != 0 {
+		t.Errorf("Key 4 should be reported as a duplicate of key 3, got %+v", reports[4])
+	}
+}
+
+func TestAuditKeys_Duplicates(t *testing.T) {
+	n := new(big.Int).Mul(randomPrime(t, 256), randomPrime(t, 256))
+	shared := randomPrime(t, 256)
+	keys := []*JSEncrypt{
+		publicKeyInstance(n),
+		publicKeyInstance(new(big.Int).Mul(shared, randomPrime(t, 256))),
+		publicKeyInstance(n),
+		publicKeyInstance(new(big.Int).Mul(shared, randomPrime(t, 256))),
+		publicKeyInstance(n),
+	}
+	reports, err := AuditKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]int{{2, 4}, {3}, {0, 4}, {1}, {0, 2}} {
+		if fmt.Sprint(reports[i].SharedWith) != fmt.Sprint(want) {
+			t.Errorf("Key %d: SharedWith = %v, want %v", i, reports[i].SharedWith, want)
+		}
+	}
+	if reports[2].SharedFactor.Cmp(n) != 0 || !strings.Contains(reports[2].Findings[len(reports[2].Findings)-1], "identical") {
+		t.Errorf("Key 2 should be reported as a duplicate: %+v", reports[2])
+	}
+	if reports[3].SharedFactor.Cmp(shared) != 0 {
+		t.Errorf("Key 3: shared factor %v", reports[3].SharedFactor)
+	}
+}
+
+func TestAuditKeys_Errors(t *testing.T) {
+	key := publicKeyInstance(new(big.Int).Mul(randomPrime(t, 256), randomPrime(t, 256)))
+	if _, err := AuditKeys([]*JSEncrypt{key, nil}); err == nil || !strings.Contains(err.Error(), "key 1") {
+		t.Errorf("nil key: got %v", err)
+	}
+	if _, err := AuditKeys([]*JSEncrypt{key, NewJSEncrypt()}); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Empty key: got %v, want ErrNoKey", err)
+	}
+}
+
+func TestBatchGCDMatchesPairwise(t *testing.T) {
+	moduli := []*big.Int{big.NewInt(3 * 5), big.NewInt(7 * 11), big.NewInt(5 * 13), big.NewInt(17 * 19), big.NewInt(11 * 23)}
+	want := []int64{5, 11, 5, 1, 11}
+	for i, g := range batchGCD(moduli) {
+		if g.Int64() != want[i] {
+			t.Errorf("batchGCD[%d] = %s, want %d", i, g, want[i])
+		}
+	}
+}
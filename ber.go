@@ -0,0 +1,161 @@
+package jsencrypt
+
+import "errors"
+
+// berToDER re-encodes a BER structure with definite lengths so it can be
+// parsed by encoding/asn1. PKCS#12 files exported by Windows and some Java
+// versions use indefinite lengths and segmented OCTET STRINGs, both of
+// which are rejected by the DER-only standard library parser.
+func berToDER(in []byte) ([]byte, error) {
+	out, rest, err := berElement(in, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("ber: trailing data")
+	}
+	return out, nil
+}
+
+const berMaxDepth = 64
+
+// berElement converts one element and returns the remaining input.
+func berElement(in []byte, depth int) ([]byte, []byte, error) {
+	if depth > berMaxDepth {
+		return nil, nil, errors.New("ber: structure too deep")
+	}
+
+	// Identifier octets.
+	if len(in) == 0 {
+		return nil, nil, errors.New("ber: truncated element")
+	}
+	idLen := 1
+	if in[0]&0x1f == 0x1f {
+		for {
+			if idLen >= len(in) {
+				return nil, nil, errors.New("ber: truncated tag")
+			}
+			idLen++
+			if in[idLen-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	id := in[:idLen]
+	constructed := in[0]&0x20 != 0
+	in = in[idLen:]
+
+	// Length octets.
+	if len(in) == 0 {
+		return nil, nil, errors.New("ber: truncated length")
+	}
+	indefinite := false
+	length := 0
+	switch l := in[0]; {
+	case l == 0x80:
+		if !constructed {
+			return nil, nil, errors.New("ber: indefinite length on primitive element")
+		}
+		indefinite = true
+		in = in[1:]
+	case l < 0x80:
+		length = int(l)
+		in = in[1:]
+	default:
+		n := int(l & 0x7f)
+		if n > 4 || len(in) < 1+n {
+			return nil, nil, errors.New("ber: invalid length")
+		}
+		for _, b := range in[1 : 1+n] {
+			length = length<<8 | int(b)
+		}
+		in = in[1+n:]
+	}
+	if length < 0 || length > len(in) {
+		return nil, nil, errors.New("ber: length exceeds input")
+	}
+
+	if !constructed {
+		return derEncode(id, in[:length]), in[length:], nil
+	}
+
+	var body, rest []byte
+	if indefinite {
+		rest = in
+	} else {
+		body, rest = in[:length], in[length:]
+	}
+
+	var children [][]byte
+	for {
+		if indefinite {
+			if len(rest) < 2 {
+				return nil, nil, errors.New("ber: missing end-of-contents")
+			}
+			if rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			child, r, err := berElement(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			children, rest = append(children, child), r
+			continue
+		}
+		if len(body) == 0 {
+			break
+		}
+		child, r, err := berElement(body, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		children, body = append(children, child), r
+	}
+
+	// A constructed universal OCTET STRING is the concatenation of its
+	// segments; DER requires the primitive form.
+	if len(id) == 1 && id[0] == 0x24 {
+		var content []byte
+		for _, child := range children {
+			if len(child) == 0 || child[0] != 0x04 {
+				return nil, nil, errors.New("ber: invalid OCTET STRING segment")
+			}
+			content = append(content, derContent(child)...)
+		}
+		return derEncode([]byte{0x04}, content), rest, nil
+	}
+
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return derEncode(id, content), rest, nil
+}
+
+// derEncode builds an element from identifier octets and content.
+func derEncode(id, content []byte) []byte {
+	out := append([]byte(nil), id...)
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		var lenBytes []byte
+		for ; n > 0; n >>= 8 {
+			lenBytes = append([]byte{byte(n)}, lenBytes...)
+		}
+		out = append(out, 0x80|byte(len(lenBytes)))
+		out = append(out, lenBytes...)
+	}
+	return append(out, content...)
+}
+
+// derContent returns the content octets of a DER element produced by
+// derEncode with a single byte identifier.
+func derContent(elem []byte) []byte {
+	l := elem[1]
+	if l < 0x80 {
+		return elem[2:]
+	}
+	return elem[2+int(l&0x7f):]
+}
@@ -0,0 +1,94 @@
+package jsencrypt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"math/big"
+)
+
+// ErrClosed is returned by every operation on a JSEncrypt after Close.
+var ErrClosed = errors.New("jsencrypt: instance is closed")
+
+// Close wipes the private key and drops all references to key material.
+// Every later operation, including setting a new key, fails with ErrClosed.
+// Close is safe to call more than once and always returns nil; it implements
+// io.Closer so it can be deferred.
+//
+// Operations already using the private key when Close is called run to
+// completion: Close waits for them before wiping the key.
+//
+// The limbs of D, the primes and the CRT values are overwritten in place,
+// so any other holder of the same *rsa.PrivateKey sees a wiped key as well.
+// Wiping is best effort: the Go runtime may have copied the values, for
+// example while growing a slice or inside crypto/rsa, and keys held by an
+// external signer or decrypter are only dereferenced.
+func (j *JSEncrypt) Close() error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return nil
+	}
+	priv := j.privateKey
+	j.keyState = keyState{}
+	j.closed = true
+	j.mu.Unlock()
+
+	// No operation can start once closed is set, so the wait is bounded.
+	j.inFlight.Wait()
+	if priv != nil {
+		wipePrivateKey(priv)
+	}
+	return nil
+}
+
+// SetKeyBytes is SetKey for callers that want to wipe their input after
+// use. data may be PEM or DER; it is not modified or retained, and
+// intermediate copies of the decoded key are wiped.
+func (j *JSEncrypt) SetKeyBytes(data []byte) error {
+	return j.setKeyData(data)
+}
+
+// SetPrivateKeyBytes sets the private key from PEM or DER; see SetKeyBytes.
+func (j *JSEncrypt) SetPrivateKeyBytes(data []byte) error {
+	return j.SetKeyBytes(data)
+}
+
+// SetPublicKeyBytes sets the public key from PEM or DER; see SetKeyBytes.
+func (j *JSEncrypt) SetPublicKeyBytes(data []byte) error {
+	return j.SetKeyBytes(data)
+}
+
+// wipePrivateKey zeroes the secret values of priv. The public modulus and
+// exponent are left intact.
+func wipePrivateKey(priv *rsa.PrivateKey) {
+	wipeInt(priv.D)
+	for _, p := range priv.Primes {
+		wipeInt(p)
+	}
+	wipeInt(priv.Precomputed.Dp)
+	wipeInt(priv.Precomputed.Dq)
+	wipeInt(priv.Precomputed.Qinv)
+	for _, crt := range priv.Precomputed.CRTValues {
+		wipeInt(crt.Exp)
+		wipeInt(crt.Coeff)
+		wipeInt(crt.R)
+	}
+}
+
+// wipeInt overwrites the limbs of x and sets it to zero.
+func wipeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	limbs := x.Bits()
+	for i := range limbs {
+		limbs[i] = 0
+	}
+	x.SetInt64(0)
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
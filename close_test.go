@@ -0,0 +1,148 @@
+package jsencrypt
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSEncrypt_Close(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	priv := jsCrypt.privateKey
+	ciphertext, err := jsCrypt.Encrypt("wiped")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := jsCrypt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsCrypt.Close(); err != nil {
+		t.Errorf("Second Close() = %v, want nil", err)
+	}
+
+	if priv.D.Sign() != 0 || priv.Precomputed.Dp.Sign() != 0 || priv.Precomputed.Qinv.Sign() != 0 {
+		t.Error("Private exponent and CRT values should be zeroed")
+	}
+	for i, p := range priv.Primes {
+		if p.Sign() != 0 {
+			t.Errorf("Prime %d should be zeroed", i)
+		}
+	}
+	if jsCrypt.privateKey != nil || jsCrypt.publicKey != nil {
+		t.Error("Close should drop key references")
+	}
+
+	checks := map[string]error{}
+	_, checks["Encrypt"] = jsCrypt.Encrypt("msg")
+	_, checks["Decrypt"] = jsCrypt.Decrypt(ciphertext)
+	_, checks["Sign"] = jsCrypt.Sign("msg")
+	_, checks["Verify"] = jsCrypt.Verify("msg", "c2ln")
+	_, checks["GetPrivateKey"] = jsCrypt.GetPrivateKey()
+	_, checks["GetPublicKey"] = jsCrypt.GetPublicKey()
+	_, checks["KeyID"] = jsCrypt.KeyID()
+	_, checks["Inspect"] = jsCrypt.Inspect()
+	_, checks["Audit"] = jsCrypt.Audit()
+	checks["Validate"] = jsCrypt.Validate()
+	checks["SetPrivateKey"] = jsCrypt.SetPrivateKey(exampleTestKeys.privateKey)
+	checks["SetPublicKey"] = jsCrypt.SetPublicKey(exampleTestKeys.publicKey)
+	checks["SetSigner"] = jsCrypt.SetSigner(newExternalKey(t))
+	for op, err := range checks {
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Close: got %v, want ErrClosed", op, err)
+		}
+	}
+}
+
+func TestJSEncrypt_CloseWaitsForOperations(t *testing.T) {
+	jsCrypt := newTestKey(t, testPrivateKeys[3])
+	priv, release, err := jsCrypt.getKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan struct{})
+	go func() {
+		jsCrypt.Close()
+		close(closed)
+	}()
+
+	// New operations fail at once while Close waits for the one in flight.
+	eventually(t, func() bool {
+		_, err := jsCrypt.Sign("msg")
+		return errors.Is(err, ErrClosed)
+	})
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the key was in use")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if priv.D.Sign() == 0 {
+		t.Fatal("The key was wiped while in use")
+	}
+	release()
+	<-closed
+	if priv.D.Sign() != 0 {
+		t.Error("The key should be wiped once the operation finished")
+	}
+
+	// Operations racing with Close either complete correctly or fail with
+	// ErrClosed. Run with -race.
+	jsCrypt = newTestKey(t, testPrivateKeys[3])
+	ciphertext, err := jsCrypt.Encrypt("racing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				plaintext, err := jsCrypt.Decrypt(ciphertext)
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil || plaintext != "racing" {
+					t.Errorf("Decrypt during Close = %q, %v", plaintext, err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	jsCrypt.Close()
+	wg.Wait()
+}
+
+func TestJSEncrypt_SetKeyBytes(t *testing.T) {
+	input := []byte(exampleTestKeys.privateKey)
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPrivateKeyBytes(input); err != nil {
+		t.Fatal(err)
+	}
+	if string(input) != exampleTestKeys.privateKey {
+		t.Error("SetPrivateKeyBytes must not modify its input")
+	}
+
+	// The caller can wipe its buffer without affecting the loaded key.
+	wipeBytes(input)
+	ciphertext, err := jsCrypt.Encrypt("after wipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := jsCrypt.Decrypt(ciphertext); err != nil || plaintext != "after wipe" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+
+	public := NewJSEncrypt()
+	if err := public.SetPublicKeyBytes([]byte(exampleTestKeys.publicKey)); err != nil {
+		t.Fatal(err)
+	}
+	if !publicKeysEqual(public.publicKey, jsCrypt.publicKey) {
+		t.Error("Public key does not match")
+	}
+	if err := public.SetKeyBytes([]byte("garbage")); err == nil {
+		t.Error("Expected an error for garbage input")
+	}
+}
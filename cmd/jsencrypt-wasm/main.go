@@ -0,0 +1,23 @@
+//go:build js && wasm
+
+// Command jsencrypt-wasm is go-jsencrypt compiled to WebAssembly. It
+// defines a global JSEncrypt constructor with the methods of the browser
+// library, backed by the Go implementation:
+//
+//	GOOS=js GOARCH=wasm go build -o jsencrypt.wasm ./cmd/jsencrypt-wasm
+//
+// Load it with the wasm_exec.js shipped with Go:
+//
+//	const go = new Go();
+//	const { instance } = await WebAssembly.instantiateStreaming(fetch("jsencrypt.wasm"), go.importObject);
+//	go.run(instance);
+//	const crypt = new JSEncrypt();
+package main
+
+import "github.com/gmodx/go-jsencrypt/wasm"
+
+func main() {
+	wasm.Register("JSEncrypt")
+	// Keep the Go runtime alive for calls from JavaScript.
+	select {}
+}
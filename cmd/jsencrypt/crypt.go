@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// The default schemes are those of Encrypt, Decrypt, Sign and Verify, and so
+// of JSEncrypt in the browser.
+var (
+	encryptionSchemes = map[string]jsencrypt.Scheme{
+		"pkcs1":       jsencrypt.SchemePKCS1v15,
+		"oaep-sha1":   {Padding: jsencrypt.PaddingOAEP, Hash: crypto.SHA1},
+		"oaep-sha256": jsencrypt.SchemeOAEPSHA256,
+	}
+	signatureSchemes = map[string]jsencrypt.Scheme{
+		"pkcs1-sha256": jsencrypt.SchemePKCS1v15SHA256,
+		"pkcs1-sha384": {Padding: jsencrypt.PaddingPKCS1v15, Hash: crypto.SHA384},
+		"pkcs1-sha512": {Padding: jsencrypt.PaddingPKCS1v15, Hash: crypto.SHA512},
+		"pss-sha256":   jsencrypt.SchemePSSSHA256,
+		"pss-sha512":   {Padding: jsencrypt.PaddingPSS, Hash: crypto.SHA512},
+	}
+)
+
+// lookupScheme returns the scheme named by name from schemes.
+func lookupScheme(schemes map[string]jsencrypt.Scheme, name string) (jsencrypt.Scheme, error) {
+	scheme, ok := schemes[name]
+	if !ok {
+		names := make([]string, 0, len(schemes))
+		for n := range schemes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return jsencrypt.Scheme{}, fmt.Errorf("unknown scheme %q (want %s)", name, strings.Join(names, ", "))
+	}
+	return scheme, nil
+}
+
+// keyFlags are the flags shared by the commands that use a key.
+type keyFlags struct {
+	key, passphrase, in, out, scheme *string
+}
+
+func addKeyFlags(fs *flag.FlagSet, defaultScheme, schemeUsage string) keyFlags {
+	return keyFlags{
+		key:        fs.String("key", "", "key file, \"-\" for standard input"),
+		passphrase: fs.String("passphrase", "", "passphrase of .ppk and PKCS#12 keys"),
+		in:         fs.String("in", "", "input file (default standard input)"),
+		out:        fs.String("out", "", "output file (default standard output)"),
+		scheme:     fs.String("scheme", defaultScheme, schemeUsage),
+	}
+}
+
+// setup parses args, then loads the key and the input.
+func (e *env) setup(fs *flag.FlagSet, f keyFlags, args []string) (*jsencrypt.JSEncrypt, []byte, error) {
+	if err := e.parse(fs, args); err != nil {
+		return nil, nil, err
+	}
+	if err := e.requireFlag(fs, "key", *f.key); err != nil {
+		return nil, nil, err
+	}
+	if *f.key == "-" && (*f.in == "" || *f.in == "-") {
+		return nil, nil, errors.New("the key and the input cannot both be read from standard input")
+	}
+	key, err := e.loadKey(*f.key, *f.passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	input, err := e.readInput(*f.in)
+	if err != nil {
+		key.Close()
+		return nil, nil, err
+	}
+	return key, input, nil
+}
+
+// requirePrivateKey fails if key holds only a public key. Decryption and
+// signing would otherwise generate a fresh key pair.
+func requirePrivateKey(key *jsencrypt.JSEncrypt, op string) error {
+	private, err := hasPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if !private {
+		return errors.New(op + " needs a private key")
+	}
+	return nil
+}
+
+// decodeBase64 decodes base64 input, ignoring line breaks and surrounding
+// whitespace.
+func decodeBase64(data []byte) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	if err != nil {
+		return nil, errors.New("input is not base64")
+	}
+	return decoded, nil
+}
+
+func runEncrypt(e *env, args []string) error {
+	fs := e.newFlagSet("encrypt")
+	f := addKeyFlags(fs, "pkcs1", "padding: pkcs1, oaep-sha1 or oaep-sha256")
+	key, input, err := e.setup(fs, f, args)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	scheme, err := lookupScheme(encryptionSchemes, *f.scheme)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := key.EncryptWith(input, scheme)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(*f.out, []byte(base64.StdEncoding.EncodeToString(ciphertext)+"\n"), false)
+}
+
+func runDecrypt(e *env, args []string) error {
+	fs := e.newFlagSet("decrypt")
+	f := addKeyFlags(fs, "pkcs1", "padding: pkcs1, oaep-sha1 or oaep-sha256")
+	key, input, err := e.setup(fs, f, args)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	scheme, err := lookupScheme(encryptionSchemes, *f.scheme)
+	if err != nil {
+		return err
+	}
+	if err := requirePrivateKey(key, "decryption"); err != nil {
+		return err
+	}
+	ciphertext, err := decodeBase64(input)
+	if err != nil {
+		return err
+	}
+	plaintext, err := key.DecryptWith(ciphertext, scheme)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(*f.out, plaintext, true)
+}
+
+func runSign(e *env, args []string) error {
+	fs := e.newFlagSet("sign")
+	f := addKeyFlags(fs, "pkcs1-sha256", "scheme: pkcs1-sha256, pkcs1-sha384, pkcs1-sha512, pss-sha256 or pss-sha512")
+	key, input, err := e.setup(fs, f, args)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	scheme, err := lookupScheme(signatureSchemes, *f.scheme)
+	if err != nil {
+		return err
+	}
+	if err := requirePrivateKey(key, "signing"); err != nil {
+		return err
+	}
+	signature, err := key.SignWith(input, scheme)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(*f.out, []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), false)
+}
+
+func runVerify(e *env, args []string) error {
+	fs := e.newFlagSet("verify")
+	f := addKeyFlags(fs, "pkcs1-sha256", "scheme: pkcs1-sha256, pkcs1-sha384, pkcs1-sha512, pss-sha256 or pss-sha512")
+	signatureB64 := fs.String("signature", "", "base64 signature")
+	signatureFile := fs.String("sigfile", "", "file holding the base64 signature")
+	key, input, err := e.setup(fs, f, args)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	scheme, err := lookupScheme(signatureSchemes, *f.scheme)
+	if err != nil {
+		return err
+	}
+
+	encoded := []byte(*signatureB64)
+	switch {
+	case *signatureB64 != "" && *signatureFile != "":
+		return errors.New("use either -signature or -sigfile")
+	case *signatureFile != "":
+		if encoded, err = e.readInput(*signatureFile); err != nil {
+			return err
+		}
+	case *signatureB64 == "":
+		return e.requireFlag(fs, "signature", "")
+	}
+	signature, err := decodeBase64(encoded)
+	if err != nil {
+		return err
+	}
+
+	if err := key.VerifyWith(input, signature, scheme); err != nil {
+		fmt.Fprintln(e.stdout, "Verification failure")
+		return errVerification
+	}
+	fmt.Fprintln(e.stdout, "Verified OK")
+	return nil
+}
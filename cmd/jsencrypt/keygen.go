@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// defaultExponent is the public exponent of keys generated by the library.
+const defaultExponent = 65537
+
+// newKeyPair generates a key pair of the given size. The library always
+// uses exponent 65537; other exponents are generated here, and those below
+// 65537 only when allowSmall is set.
+func newKeyPair(bits, exponent int, allowSmall bool) (*jsencrypt.JSEncrypt, error) {
+	key := jsencrypt.NewJSEncrypt()
+	key.DefaultKeySize = bits
+	if exponent == defaultExponent {
+		if _, err := key.GetPrivateKey(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if exponent < defaultExponent && !allowSmall {
+		return nil, fmt.Errorf("public exponent %d is below %d; pass -small-exponent to allow it", exponent, defaultExponent)
+	}
+
+	priv, err := generateKey(bits, exponent)
+	if err != nil {
+		return nil, err
+	}
+	der := x509.MarshalPKCS1PrivateKey(priv)
+	defer func() {
+		for i := range der {
+			der[i] = 0
+		}
+	}()
+	if err := key.SetPrivateKeyBytes(der); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// generateKey generates a two-prime key with public exponent e, picking
+// primes p and q with gcd(e, p-1) = gcd(e, q-1) = 1. rsa.GenerateKey only
+// produces keys with exponent 65537.
+func generateKey(bits, e int) (*rsa.PrivateKey, error) {
+	if e < 3 || e%2 == 0 || int64(e) > 1<<31-1 {
+		return nil, errors.New("public exponent must be an odd number between 3 and 2^31-1")
+	}
+	if bits < 64 {
+		return nil, errors.New("key size too small")
+	}
+
+	one := big.NewInt(1)
+	bigE := big.NewInt(int64(e))
+	for {
+		// rand.Prime sets the top two bits, so n has exactly bits bits.
+		p, err := rand.Prime(rand.Reader, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		pMinus1 := new(big.Int).Sub(p, one)
+		qMinus1 := new(big.Int).Sub(q, one)
+		totient := new(big.Int).Mul(pMinus1, qMinus1)
+		d := new(big.Int).ModInverse(bigE, totient)
+		if d == nil {
+			continue
+		}
+
+		priv := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: new(big.Int).Mul(p, q), E: e},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := priv.Validate(); err != nil {
+			return nil, err
+		}
+		priv.Precompute()
+		return priv, nil
+	}
+}
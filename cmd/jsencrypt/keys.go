@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// requireFlag fails with errUsage when a mandatory flag is empty.
+func (e *env) requireFlag(fs *flag.FlagSet, name, value string) error {
+	if value != "" {
+		return nil
+	}
+	fmt.Fprintf(e.stderr, "%s: flag -%s is required\n", fs.Name(), name)
+	fs.Usage()
+	return errUsage
+}
+
+// loadKey reads a key from path ("-" for standard input). PEM and DER
+// (PKCS#1, PKCS#8, PKIX), JWK, OpenSSH public keys, PuTTY .ppk files and
+// PKCS#12 bundles are accepted; passphrase decrypts the last two.
+func (e *env) loadKey(path, passphrase string) (*jsencrypt.JSEncrypt, error) {
+	data, err := e.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	key := jsencrypt.NewJSEncrypt()
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		err = key.SetJWK(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("ssh-rsa ")):
+		err = key.SetSSHPublicKey(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("PuTTY-User-Key-File-")):
+		err = key.SetKeyPPK(data, passphrase)
+	default:
+		if err = key.SetKeyBytes(data); err != nil {
+			if p12Err := key.SetKeyPKCS12(data, passphrase); p12Err == nil {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+	}
+	return key, nil
+}
+
+func displayPath(path string) string {
+	if path == "" || path == "-" {
+		return "standard input"
+	}
+	return path
+}
+
+// hasPrivateKey reports whether key holds a private key. Export methods
+// generate a key pair when none is set, so this must be checked before
+// exporting a private key.
+func hasPrivateKey(key *jsencrypt.JSEncrypt) (bool, error) {
+	report, err := key.Inspect()
+	if err != nil {
+		return false, err
+	}
+	return report.HasPrivateKey, nil
+}
+
+// exportKey writes key in the named format: pkcs1, pkcs8, pkix, jwk or
+// ssh. Only the public half is written when public is set, or the format
+// holds public keys only (pkix, ssh).
+func exportKey(key *jsencrypt.JSEncrypt, format string, public, der bool, comment string) ([]byte, error) {
+	encoding := jsencrypt.EncodingPEM
+	if der {
+		encoding = jsencrypt.EncodingDER
+	}
+	switch format {
+	case "pkix", "ssh":
+		public = true
+	case "pkcs1", "pkcs8", "jwk":
+	default:
+		return nil, fmt.Errorf("unknown key format %q (want pkcs1, pkcs8, pkix, jwk or ssh)", format)
+	}
+	if der && (format == "jwk" || format == "ssh") {
+		return nil, fmt.Errorf("-der does not apply to %s", format)
+	}
+	if !public {
+		private, err := hasPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !private {
+			if format == "pkcs8" {
+				return nil, errors.New("pkcs8 needs a private key; use pkix for public keys")
+			}
+			public = true
+		}
+	}
+
+	switch {
+	case format == "ssh":
+		return key.ExportSSHPublicKey(comment)
+	case format == "jwk" && public:
+		return jsonLine(key.ExportPublicJWK())
+	case format == "jwk":
+		return jsonLine(key.ExportPrivateJWK())
+	case format == "pkcs8" && public:
+		return nil, errors.New("pkcs8 holds private keys only; use pkix for the public key")
+	case format == "pkcs8":
+		return key.ExportPrivateKey(jsencrypt.ExportOptions{Format: jsencrypt.FormatPKCS8, Encoding: encoding})
+	case format == "pkcs1" && public:
+		return key.ExportPublicKey(jsencrypt.ExportOptions{Format: jsencrypt.FormatPKCS1, Encoding: encoding})
+	case format == "pkcs1":
+		return key.ExportPrivateKey(jsencrypt.ExportOptions{Format: jsencrypt.FormatPKCS1, Encoding: encoding})
+	}
+	return key.ExportPublicKey(jsencrypt.ExportOptions{Format: jsencrypt.FormatPKIX, Encoding: encoding})
+}
+
+func jsonLine(data []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func runKeygen(e *env, args []string) error {
+	fs := e.newFlagSet("keygen")
+	bits := fs.Int("bits", 2048, "modulus size in bits")
+	exponent := fs.Int("exponent", defaultExponent, "public exponent")
+	smallExponent := fs.Bool("small-exponent", false, "allow public exponents below 65537")
+	format := fs.String("format", "pkcs1", "private key format: pkcs1, pkcs8 or jwk")
+	der := fs.Bool("der", false, "write DER instead of PEM")
+	out := fs.String("out", "", "private key file (default standard output)")
+	pubOut := fs.String("pubout", "", "also write the public key to this file")
+	pubFormat := fs.String("pubformat", "pkix", "public key format: pkix, pkcs1, jwk or ssh")
+	comment := fs.String("comment", "", "comment for ssh public keys")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if *format != "pkcs1" && *format != "pkcs8" && *format != "jwk" {
+		return fmt.Errorf("unknown private key format %q (want pkcs1, pkcs8 or jwk)", *format)
+	}
+
+	key, err := newKeyPair(*bits, *exponent, *smallExponent)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	private, err := exportKey(key, *format, false, *der, "")
+	if err != nil {
+		return err
+	}
+	if err := e.writeOutput(*out, private, true); err != nil {
+		return err
+	}
+	if *pubOut == "" {
+		return nil
+	}
+	public, err := exportKey(key, *pubFormat, true, *der, *comment)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(*pubOut, public, false)
+}
+
+func runConvert(e *env, args []string) error {
+	fs := e.newFlagSet("convert")
+	in := fs.String("in", "", "key file (default standard input)")
+	out := fs.String("out", "", "output file (default standard output)")
+	to := fs.String("to", "", "output format: pkcs1, pkcs8, pkix, jwk or ssh")
+	public := fs.Bool("public", false, "write only the public key")
+	der := fs.Bool("der", false, "write DER instead of PEM")
+	passphrase := fs.String("passphrase", "", "passphrase of .ppk and PKCS#12 input")
+	comment := fs.String("comment", "", "comment for ssh public keys")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if err := e.requireFlag(fs, "to", *to); err != nil {
+		return err
+	}
+
+	key, err := e.loadKey(*in, *passphrase)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	converted, err := exportKey(key, *to, *public, *der, *comment)
+	if err != nil {
+		return err
+	}
+	secret := !*public && *to != "pkix" && *to != "ssh"
+	return e.writeOutput(*out, converted, secret)
+}
+
+// inspection is the output of the inspect command.
+type inspection struct {
+	Type         string   `json:"type"`
+	Bits         int      `json:"bits"`
+	Exponent     int      `json:"exponent"`
+	KeyID        string   `json:"kid"`
+	SHA256       string   `json:"sha256"`
+	SHA256Base64 string   `json:"sha256_base64"`
+	MD5          string   `json:"md5"`
+	SSH          string   `json:"ssh"`
+	Consistent   bool     `json:"consistent"`
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+func runInspect(e *env, args []string) error {
+	fs := e.newFlagSet("inspect")
+	in := fs.String("in", "", "key file (default standard input)")
+	passphrase := fs.String("passphrase", "", "passphrase of .ppk and PKCS#12 input")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	key, err := e.loadKey(*in, *passphrase)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	report, err := key.Inspect()
+	if err != nil {
+		return err
+	}
+	info := inspection{
+		Type:       "public",
+		Bits:       report.Bits,
+		Exponent:   report.PublicExponent,
+		Consistent: report.Consistent,
+		Warnings:   report.Warnings,
+	}
+	if report.HasPrivateKey {
+		info.Type = "private"
+	}
+	for _, field := range []struct {
+		dst *string
+		fn  func() (string, error)
+	}{
+		{&info.KeyID, key.KeyID},
+		{&info.SHA256, key.FingerprintSHA256},
+		{&info.SHA256Base64, key.FingerprintSHA256Base64},
+		{&info.MD5, key.FingerprintMD5},
+		{&info.SSH, key.FingerprintSSH},
+	} {
+		if *field.dst, err = field.fn(); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		return e.writeOutput("", append(out, '\n'), false)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Type:        RSA %s key\n", info.Type)
+	fmt.Fprintf(&b, "Size:        %d bits\n", info.Bits)
+	fmt.Fprintf(&b, "Exponent:    %d\n", info.Exponent)
+	fmt.Fprintf(&b, "Key ID:      %s\n", info.KeyID)
+	fmt.Fprintf(&b, "SHA-256:     %s\n", info.SHA256)
+	fmt.Fprintf(&b, "SPKI SHA256: %s\n", info.SHA256Base64)
+	fmt.Fprintf(&b, "MD5:         %s\n", info.MD5)
+	fmt.Fprintf(&b, "SSH:         %s\n", info.SSH)
+	fmt.Fprintf(&b, "Consistent:  %t\n", info.Consistent)
+	for _, warning := range info.Warnings {
+		fmt.Fprintf(&b, "Warning:     %s\n", warning)
+	}
+	return e.writeOutput("", []byte(b.String()), false)
+}
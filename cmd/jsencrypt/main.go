@@ -0,0 +1,163 @@
+// Command jsencrypt exposes the go-jsencrypt library on the command line, so
+// keys, ciphertexts and signatures can be produced and checked exactly as a
+// service using the library would:
+//
+//	jsencrypt keygen -bits 2048 -out key.pem -pubout key.pub
+//	printf 'secret' | jsencrypt encrypt -key key.pub > secret.b64
+//	jsencrypt decrypt -key key.pem -in secret.b64
+//	jsencrypt sign -key key.pem -in message.txt > message.sig
+//	jsencrypt verify -key key.pub -in message.txt -sigfile message.sig
+//	jsencrypt convert -in key.pem -to jwk
+//	jsencrypt inspect -in key.pub
+//	jsencrypt secrets encrypt -key key.pub -in config.yaml -out config.enc.yaml
+//
+// Input is read from the file given by -in, or standard input; output goes to
+// the file given by -out, or standard output. Ciphertexts and signatures are
+// the base64 strings returned by Encrypt and Sign followed by a newline.
+// Input to encrypt and sign is used byte for byte, including any trailing
+// newline.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a subcommand of jsencrypt.
+type command struct {
+	summary string
+	run     func(env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"keygen":  {"generate a key pair", runKeygen},
+	"encrypt": {"encrypt input with a public key", runEncrypt},
+	"decrypt": {"decrypt base64 input with a private key", runDecrypt},
+	"sign":    {"sign input with a private key", runSign},
+	"verify":  {"verify a signature over input", runVerify},
+	"convert": {"convert a key between PKCS#1, PKCS#8, PKIX, JWK and SSH", runConvert},
+	"inspect": {"print the size, exponent and fingerprints of a key", runInspect},
+	"secrets": {"encrypt or decrypt the values of a JSON, YAML or dotenv file", runSecrets},
+}
+
+// env holds the standard streams, so commands can be run from tests.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// errUsage reports invalid arguments; the flag set has already printed why.
+var errUsage = errors.New("usage")
+
+// errVerification reports a signature that did not verify. It is not an
+// operational error, so nothing is printed besides the verify output.
+var errVerification = errors.New("verification failure")
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run runs the subcommand named by args[0] and returns the exit status: 0 on
+// success, 1 on failure and 2 on invalid arguments.
+func run(args []string, e *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "jsencrypt: unknown command %q\n", args[0])
+		usage(e.stderr)
+		return 2
+	}
+
+	err := cmd.run(e, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, errVerification):
+		return 1
+	}
+	fmt.Fprintf(e.stderr, "jsencrypt %s: %v\n", args[0], err)
+	return 1
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: jsencrypt <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "jsencrypt <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet returns a flag set that reports errors to stderr instead of
+// exiting.
+func (e *env) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("jsencrypt "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// parse parses args and rejects positional arguments. The flag set reports
+// parse errors itself, so only errUsage is returned.
+func (e *env) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(e.stderr, "%s: unexpected argument %q\n", fs.Name(), fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// readInput reads the file at path, or standard input for "" and "-".
+func (e *env) readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes data to the file at path, or standard output for ""
+// and "-". Files holding private keys or plaintext are made readable by the
+// owner only, including existing files being overwritten.
+func (e *env) writeOutput(path string, data []byte, secret bool) error {
+	if path == "" || path == "-" {
+		_, err := e.stdout.Write(data)
+		return err
+	}
+	if !secret {
+		return os.WriteFile(path, data, 0o644)
+	}
+	// The mode passed to OpenFile only applies to new files, so an existing
+	// file is restricted before anything is written to it.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// jsencryptCmd runs the command with the given standard input and returns
+// the exit status and output.
+func jsencryptCmd(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(args, &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return status, stdout.String(), stderr.String()
+}
+
+// mustRun runs the command and fails the test unless it succeeds.
+func mustRun(t *testing.T, stdin string, args ...string) string {
+	t.Helper()
+	status, stdout, stderr := jsencryptCmd(t, stdin, args...)
+	if status != 0 {
+		t.Fatalf("jsencrypt %s: exit %d: %s", strings.Join(args, " "), status, stderr)
+	}
+	return stdout
+}
+
+// newKeyFiles generates a 1024-bit key pair and returns the paths of the
+// private and public key files.
+func newKeyFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	private, public := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub")
+	mustRun(t, "", "keygen", "-bits", "1024", "-out", private, "-pubout", public)
+	return private, public
+}
+
+func loadLibraryKey(t *testing.T, path string) *jsencrypt.JSEncrypt {
+	key, err := jsencrypt.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeygen(t *testing.T) {
+	private, public := newKeyFiles(t)
+	info, err := os.Stat(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Private key mode = %v", info.Mode().Perm())
+	}
+	key := loadLibraryKey(t, private)
+	pem, _ := key.GetPublicKey()
+	if data, _ := os.ReadFile(public); string(data) != pem {
+		t.Errorf("Public key file does not match the private key:\n%s", data)
+	}
+
+	var report struct {
+		Type     string
+		Bits     int
+		Exponent int
+		Kid      string
+	}
+	if status, _, stderr := jsencryptCmd(t, "", "keygen", "-bits", "1024", "-exponent", "3"); status != 1 || !strings.Contains(stderr, "-small-exponent") {
+		t.Errorf("keygen -exponent 3: exit %d: %s", status, stderr)
+	}
+	out := mustRun(t, "", "keygen", "-bits", "1024", "-exponent", "3", "-small-exponent")
+	if err := json.Unmarshal([]byte(mustRun(t, out, "inspect", "-json")), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Type != "private" || report.Bits != 1024 || report.Exponent != 3 || report.Kid == "" {
+		t.Errorf("inspect = %+v", report)
+	}
+
+	out = mustRun(t, "", "keygen", "-bits", "1024", "-format", "jwk")
+	if !strings.Contains(out, `"d":`) {
+		t.Errorf("JWK private key = %s", out)
+	}
+
+	// Overwriting an existing file also restricts its mode.
+	existing := filepath.Join(t.TempDir(), "existing.pem")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mustRun(t, "", "keygen", "-bits", "1024", "-out", existing)
+	if info, err := os.Stat(existing); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Overwritten private key: %v, %v", info.Mode().Perm(), err)
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	for _, e := range []int{3, 17, 65537, 1<<31 - 1} {
+		priv, err := generateKey(1024, e)
+		if err != nil {
+			t.Fatalf("e=%d: %v", e, err)
+		}
+		if err := priv.Validate(); err != nil {
+			t.Errorf("e=%d: Validate: %v", e, err)
+		}
+		if priv.N.BitLen() != 1024 || priv.E != e {
+			t.Errorf("e=%d: got %d bits, exponent %d", e, priv.N.BitLen(), priv.E)
+		}
+		parsed, err := x509.ParsePKCS1PrivateKey(x509.MarshalPKCS1PrivateKey(priv))
+		if err != nil {
+			t.Fatalf("e=%d: PKCS#1 round trip: %v", e, err)
+		}
+		if !parsed.Equal(priv) {
+			t.Errorf("e=%d: PKCS#1 round trip changed the key", e)
+		}
+	}
+	for _, e := range []int{1, 4, 65536} {
+		if _, err := generateKey(1024, e); err == nil {
+			t.Errorf("generateKey accepted exponent %d", e)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	private, public := newKeyFiles(t)
+	key := loadLibraryKey(t, private)
+
+	for _, scheme := range []string{"pkcs1", "oaep-sha256"} {
+		ciphertext := mustRun(t, "secret\n", "encrypt", "-key", public, "-scheme", scheme)
+		if !strings.HasSuffix(ciphertext, "\n") {
+			t.Errorf("%s: output should end with a newline", scheme)
+		}
+		if plaintext := mustRun(t, ciphertext, "decrypt", "-key", private, "-scheme", scheme); plaintext != "secret\n" {
+			t.Errorf("%s: decrypt = %q", scheme, plaintext)
+		}
+	}
+
+	// Ciphertexts are interchangeable with the library.
+	ciphertext := mustRun(t, "from the cli", "encrypt", "-key", public)
+	if plaintext, err := key.Decrypt(strings.TrimSpace(ciphertext)); err != nil || plaintext != "from the cli" {
+		t.Errorf("Library Decrypt = %q, %v", plaintext, err)
+	}
+	libraryCiphertext, _ := key.Encrypt("from the library")
+	if plaintext := mustRun(t, libraryCiphertext, "decrypt", "-key", private); plaintext != "from the library" {
+		t.Errorf("CLI decrypt = %q", plaintext)
+	}
+
+	if status, _, stderr := jsencryptCmd(t, ciphertext, "decrypt", "-key", public); status != 1 || !strings.Contains(stderr, "private key") {
+		t.Errorf("Decrypt with a public key: exit %d: %s", status, stderr)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	private, public := newKeyFiles(t)
+	key := loadLibraryKey(t, private)
+
+	signature := mustRun(t, "message", "sign", "-key", private)
+	want, _ := key.Sign("message")
+	if signature != want+"\n" {
+		t.Errorf("sign = %q, library Sign = %q", signature, want)
+	}
+	if out := mustRun(t, "message", "verify", "-key", public, "-signature", want); out != "Verified OK\n" {
+		t.Errorf("verify = %q", out)
+	}
+	status, out, _ := jsencryptCmd(t, "messagE", "verify", "-key", public, "-signature", want)
+	if status != 1 || out != "Verification failure\n" {
+		t.Errorf("Tampered message: exit %d, %q", status, out)
+	}
+
+	sigFile := filepath.Join(t.TempDir(), "message.sig")
+	mustRun(t, "message", "sign", "-key", private, "-scheme", "pss-sha256", "-out", sigFile)
+	mustRun(t, "message", "verify", "-key", public, "-scheme", "pss-sha256", "-sigfile", sigFile)
+	if status, _, _ := jsencryptCmd(t, "message", "verify", "-key", public, "-sigfile", sigFile); status != 1 {
+		t.Errorf("A PSS signature must not verify as PKCS#1 v1.5, exit %d", status)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	private, public := newKeyFiles(t)
+	key := loadLibraryKey(t, private)
+	pkcs1, _ := os.ReadFile(private)
+
+	jwk := mustRun(t, "", "convert", "-in", private, "-to", "jwk")
+	pkcs8 := mustRun(t, jwk, "convert", "-to", "pkcs8")
+	if back := mustRun(t, pkcs8, "convert", "-to", "pkcs1"); back != string(pkcs1) {
+		t.Errorf("PKCS#1 -> JWK -> PKCS#8 -> PKCS#1 changed the key:\n%s", back)
+	}
+	if want, _ := key.ExportPrivateKey(jsencrypt.ExportOptions{Format: jsencrypt.FormatPKCS8}); pkcs8 != string(want) {
+		t.Error("PKCS#8 output differs from ExportPrivateKey")
+	}
+
+	ssh := mustRun(t, "", "convert", "-in", private, "-to", "ssh", "-comment", "ops")
+	if want, _ := key.ExportSSHPublicKey("ops"); ssh != string(want) {
+		t.Errorf("ssh = %q", ssh)
+	}
+	pem, _ := os.ReadFile(public)
+	if pkix := mustRun(t, ssh, "convert", "-to", "pkix"); pkix != string(pem) {
+		t.Errorf("SSH -> PKIX = %s", pkix)
+	}
+	if out := mustRun(t, "", "convert", "-in", private, "-to", "jwk", "-public"); strings.Contains(out, `"d"`) {
+		t.Errorf("-public leaked the private key: %s", out)
+	}
+	if out := mustRun(t, "", "convert", "-in", public, "-to", "pkcs1"); !strings.Contains(out, "RSA PUBLIC KEY") {
+		t.Errorf("A public key must stay public: %s", out)
+	}
+	if status, _, _ := jsencryptCmd(t, "", "convert", "-in", public, "-to", "pkcs8"); status != 1 {
+		t.Errorf("PKCS#8 from a public key: exit %d", status)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	_, public := newKeyFiles(t)
+	key := loadLibraryKey(t, public)
+	out := mustRun(t, "", "inspect", "-in", public)
+	fingerprint, _ := key.FingerprintSHA256()
+	sshFingerprint, _ := key.FingerprintSSH()
+	for _, want := range []string{"RSA public key", "1024 bits", "65537", fingerprint, sshFingerprint, "Warning:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("inspect output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"frobnicate"},
+		{"encrypt"},
+		{"encrypt", "-key", "k", "extra"},
+		{"convert", "-in", "k"},
+		{"keygen", "-bits", "many"},
+	}
+	for _, args := range tests {
+		if status, _, _ := jsencryptCmd(t, "", args...); status != 2 {
+			t.Errorf("jsencrypt %v: exit %d, want 2", args, status)
+		}
+	}
+	if status, _, stderr := jsencryptCmd(t, "", "inspect", "-in", "/nonexistent"); status != 1 || stderr == "" {
+		t.Errorf("Missing file: exit %d, %q", status, stderr)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	private, public := newKeyFiles(t)
+	otherPrivate, otherPublic := newKeyFiles(t)
+	dir := t.TempDir()
+	plain, encrypted := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.enc.yaml")
+	if err := os.WriteFile(plain, []byte("db:\n  password: hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRun(t, "", "secrets", "encrypt", "-key", public, "-key", otherPublic, "-in", plain, "-out", encrypted)
+	data, _ := os.ReadFile(encrypted)
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), "password: ENC[") {
+		t.Errorf("Encrypted file:\n%s", data)
+	}
+	for _, key := range []string{private, otherPrivate} {
+		if out := mustRun(t, "", "secrets", "decrypt", "-key", key, "-in", encrypted); out != "db:\n  password: hunter2\n" {
+			t.Errorf("decrypt with %s = %q", key, out)
+		}
+	}
+	if out := mustRun(t, string(data), "secrets", "decrypt", "-key", private, "-format", "yaml"); out != "db:\n  password: hunter2\n" {
+		t.Errorf("decrypt from standard input = %q", out)
+	}
+
+	for _, args := range [][]string{
+		{"secrets"},
+		{"secrets", "rotate"},
+		{"secrets", "encrypt", "-in", plain},
+		{"secrets", "encrypt", "-key", public},
+	} {
+		if status, _, _ := jsencryptCmd(t, "", args...); status != 2 {
+			t.Errorf("jsencrypt %v: exit %d, want 2", args, status)
+		}
+	}
+	if status, _, stderr := jsencryptCmd(t, "", "secrets", "decrypt", "-key", public, "-in", encrypted); status != 1 || !strings.Contains(stderr, "private key") {
+		t.Errorf("Decrypt with a public key: exit %d: %s", status, stderr)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/secrets"
+)
+
+// keyList is a repeatable -key flag.
+type keyList []string
+
+func (k *keyList) String() string { return strings.Join(*k, ",") }
+
+func (k *keyList) Set(path string) error {
+	*k = append(*k, path)
+	return nil
+}
+
+// runSecrets encrypts or decrypts the values of a JSON, YAML or dotenv
+// file with the secrets package:
+//
+//	jsencrypt secrets encrypt -key alice.pub -key bob.pub -in config.yaml -out config.enc.yaml
+//	jsencrypt secrets decrypt -key alice.pem -in config.enc.yaml
+func runSecrets(e *env, args []string) error {
+	if len(args) == 0 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Fprintln(e.stderr, "usage: jsencrypt secrets encrypt|decrypt -key file [-key file ...] [flags]")
+		return errUsage
+	}
+	op := args[0]
+	fs := e.newFlagSet("secrets " + op)
+	var keyPaths keyList
+	fs.Var(&keyPaths, "key", "key file, repeatable: a recipient's public key to encrypt, a private key to decrypt")
+	passphrase := fs.String("passphrase", "", "passphrase of .ppk and PKCS#12 keys")
+	in := fs.String("in", "", "input file (default standard input)")
+	out := fs.String("out", "", "output file (default standard output)")
+	formatName := fs.String("format", "", "json, yaml or dotenv (default from the -in file name)")
+	if err := e.parse(fs, args[1:]); err != nil {
+		return err
+	}
+	if err := e.requireFlag(fs, "key", keyPaths.String()); err != nil {
+		return err
+	}
+
+	var format secrets.Format
+	var err error
+	switch {
+	case *formatName != "":
+		format, err = secrets.ParseFormat(*formatName)
+	case *in == "" || *in == "-":
+		return e.requireFlag(fs, "format", "")
+	default:
+		format, err = secrets.FormatFromPath(*in)
+	}
+	if err != nil {
+		return err
+	}
+	for _, path := range keyPaths {
+		if path == "-" && (*in == "" || *in == "-") {
+			return errors.New("the key and the input cannot both be read from standard input")
+		}
+	}
+
+	keys := make([]*jsencrypt.JSEncrypt, 0, len(keyPaths))
+	defer func() {
+		for _, key := range keys {
+			key.Close()
+		}
+	}()
+	for _, path := range keyPaths {
+		key, err := e.loadKey(path, *passphrase)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	input, err := e.readInput(*in)
+	if err != nil {
+		return err
+	}
+
+	if op == "encrypt" {
+		output, err := secrets.Encrypt(input, format, keys...)
+		if err != nil {
+			return err
+		}
+		return e.writeOutput(*out, output, false)
+	}
+
+	ring := jsencrypt.NewKeyring()
+	for _, key := range keys {
+		if err := requirePrivateKey(key, "decryption"); err != nil {
+			return err
+		}
+		if _, err := ring.Add(key); err != nil {
+			return err
+		}
+	}
+	output, err := (&secrets.Decrypter{Keys: ring}).Decrypt(input, format)
+	if err != nil {
+		return err
+	}
+	return e.writeOutput(*out, output, true)
+}
@@ -0,0 +1,249 @@
+package jsencrypt
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoColumnKeys is returned by EncryptedString and EncryptedBytes when no
+// keyring has been registered with RegisterColumnKeyring.
+var ErrNoColumnKeys = errors.New("no column keyring registered")
+
+var columnKeys struct {
+	mu   sync.RWMutex
+	ring *Keyring
+}
+
+// RegisterColumnKeyring sets the keyring used by EncryptedString and
+// EncryptedBytes. Values are encrypted with the active key and decrypted
+// with the key named in the stored value, so keys can be rotated while old
+// rows remain readable. Call it once during startup, before any query.
+func RegisterColumnKeyring(ring *Keyring) {
+	columnKeys.mu.Lock()
+	defer columnKeys.mu.Unlock()
+	columnKeys.ring = ring
+}
+
+// RegisterColumnKey registers a keyring holding only key.
+func RegisterColumnKey(key *JSEncrypt) error {
+	ring := NewKeyring()
+	if _, err := ring.Add(key); err != nil {
+		return err
+	}
+	RegisterColumnKeyring(ring)
+	return nil
+}
+
+func columnKeyring() (*Keyring, error) {
+	columnKeys.mu.RLock()
+	defer columnKeys.mu.RUnlock()
+	if columnKeys.ring == nil {
+		return nil, ErrNoColumnKeys
+	}
+	return columnKeys.ring, nil
+}
+
+// EncryptedString is a string stored encrypted in a database column. It
+// implements driver.Valuer and sql.Scanner, so it can be used directly as a
+// query argument and scan destination:
+//
+//	db.Exec("INSERT INTO users (email, ssn) VALUES (?, ?)", email, jsencrypt.EncryptedString(ssn))
+//	var ssn jsencrypt.EncryptedString
+//	row.Scan(&ssn)
+//
+// The column holds text of the form
+//
+//	<kid>.<wrapped key>.<nonce>.<ciphertext>
+//
+// with base64url segments: the value is encrypted with AES-256-GCM under a
+// fresh content key wrapped with RSA-OAEP-SHA256 for the active key of the
+// registered keyring, and the key ID is authenticated. Values are not
+// limited by the RSA key size. Encryption is randomized, so encrypted
+// columns cannot be compared or indexed.
+//
+// A stored value is not bound to its row or column: copied into another
+// row, it decrypts there too. Use a Column with an AAD naming the row and
+// column to prevent that.
+//
+// The empty string is stored encrypted; NULL scans as the empty string.
+type EncryptedString string
+
+// Value implements driver.Valuer.
+func (s EncryptedString) Value() (driver.Value, error) {
+	return Column{}.seal([]byte(s))
+}
+
+// Scan implements sql.Scanner.
+func (s *EncryptedString) Scan(src any) error {
+	return Column{}.String(s).Scan(src)
+}
+
+// EncryptedBytes is a byte slice stored encrypted in a database column, in
+// the same text format as EncryptedString. A nil EncryptedBytes is stored as
+// NULL and NULL scans as nil.
+type EncryptedBytes []byte
+
+// Value implements driver.Valuer.
+func (b EncryptedBytes) Value() (driver.Value, error) {
+	return Column{}.Bytes(&b).Value()
+}
+
+// Scan implements sql.Scanner.
+func (b *EncryptedBytes) Scan(src any) error {
+	return Column{}.Bytes(b).Scan(src)
+}
+
+// Column encrypts the values of one database column with its own keyring
+// and additional authenticated data. Wrap the query argument or scan
+// destination with String or Bytes:
+//
+//	ssn := jsencrypt.Column{AAD: "users.ssn:" + userID}
+//	db.Exec("UPDATE users SET ssn = ? WHERE id = ?", ssn.String(&value), userID)
+//	row.Scan(ssn.String(&value))
+//
+// The AAD is authenticated with the value but not stored, so a value only
+// decrypts with the AAD it was encrypted with; naming the table, column
+// and row stops values from being copied between them. The empty AAD is
+// the one EncryptedString and EncryptedBytes use on their own.
+type Column struct {
+	// Keys encrypts and decrypts the column. Default: the keyring
+	// registered with RegisterColumnKeyring.
+	Keys *Keyring
+	// AAD is the additional authenticated data of every value.
+	AAD string
+}
+
+// String returns a query argument and scan destination for s.
+func (c Column) String(s *EncryptedString) *ColumnValue {
+	return &ColumnValue{column: c, str: s}
+}
+
+// Bytes returns a query argument and scan destination for b.
+func (c Column) Bytes(b *EncryptedBytes) *ColumnValue {
+	return &ColumnValue{column: c, bytes: b}
+}
+
+// ColumnValue is an EncryptedString or EncryptedBytes bound to a Column.
+// It implements driver.Valuer and sql.Scanner.
+type ColumnValue struct {
+	column Column
+	str    *EncryptedString
+	bytes  *EncryptedBytes
+}
+
+// Value implements driver.Valuer.
+// A nil pointer is stored as NULL.
+func (v *ColumnValue) Value() (driver.Value, error) {
+	switch {
+	case v.str != nil:
+		return v.column.seal([]byte(*v.str))
+	case v.bytes == nil || *v.bytes == nil:
+		return nil, nil
+	}
+	return v.column.seal(*v.bytes)
+}
+
+// Scan implements sql.Scanner. Scanning into a nil pointer fails.
+func (v *ColumnValue) Scan(src any) error {
+	if v.str == nil && v.bytes == nil {
+		return errors.New("jsencrypt: cannot scan into a nil column value")
+	}
+	if v.str != nil {
+		plaintext, err := v.column.open(src, "EncryptedString")
+		if err != nil {
+			return err
+		}
+		*v.str = EncryptedString(plaintext)
+		return nil
+	}
+	if src == nil {
+		*v.bytes = nil
+		return nil
+	}
+	plaintext, err := v.column.open(src, "EncryptedBytes")
+	if err != nil {
+		return err
+	}
+	*v.bytes = plaintext
+	return nil
+}
+
+func (c Column) keyring() (*Keyring, error) {
+	if c.Keys != nil {
+		return c.Keys, nil
+	}
+	return columnKeyring()
+}
+
+// aad returns the data authenticated with a value: the key ID, followed by
+// the column's AAD if it has one. Key IDs never contain the separator.
+func (c Column) aad(kid string) []byte {
+	if c.AAD == "" {
+		return []byte(kid)
+	}
+	return []byte(kid + keyIDSeparator + c.AAD)
+}
+
+// seal encrypts a column value for the active key.
+func (c Column) seal(plaintext []byte) (driver.Value, error) {
+	ring, err := c.keyring()
+	if err != nil {
+		return nil, err
+	}
+	kid, key, err := ring.activeKey()
+	if err != nil {
+		return nil, err
+	}
+	contentKey, nonce, ciphertext, err := sealContent(plaintext, c.aad(kid))
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(contentKey)
+	wrapped, err := key.WrapContentKey(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return kid + keyIDSeparator + b64url.EncodeToString(wrapped) + keyIDSeparator +
+		b64url.EncodeToString(nonce) + keyIDSeparator + b64url.EncodeToString(ciphertext), nil
+}
+
+// open decrypts a column value produced by seal. NULL decrypts to an empty
+// value.
+func (c Column) open(src any, typeName string) ([]byte, error) {
+	var stored string
+	switch src := src.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		stored = src
+	case []byte:
+		stored = string(src)
+	default:
+		return nil, fmt.Errorf("jsencrypt: cannot scan %T into %s", src, typeName)
+	}
+
+	ring, err := c.keyring()
+	if err != nil {
+		return nil, err
+	}
+	parts, err := splitCompact(stored, 4)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ring.privateKey(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	segments, err := decodeSegments(parts[1:])
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := key.UnwrapContentKey(segments[0])
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(contentKey)
+	return openContent(contentKey, segments[1], segments[2], c.aad(parts[0]))
+}
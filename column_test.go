@@ -0,0 +1,302 @@
+package jsencrypt
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver is a one-table in-memory database: every Exec appends its
+// arguments as a row, every Query returns all rows.
+type fakeDriver struct {
+	mu   sync.Mutex
+	rows [][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.rows = append(s.d.rows, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	return &fakeRows{rows: append([][]driver.Value(nil), s.d.rows...)}, nil
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"name", "secret"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var fakeDB = &fakeDriver{}
+
+func init() {
+	sql.Register("jsencrypt-fake", fakeDB)
+}
+
+func openFakeDB(t *testing.T) *sql.DB {
+	fakeDB.mu.Lock()
+	fakeDB.rows = nil
+	fakeDB.mu.Unlock()
+	db, err := sql.Open("jsencrypt-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		RegisterColumnKeyring(nil)
+	})
+	return db
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	db := openFakeDB(t)
+	key := newTestKey(t, testPrivateKeys[3])
+	if err := RegisterColumnKey(key); err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := key.KeyID()
+
+	long := strings.Repeat("a long note ", 100)
+	inserts := []struct {
+		name   string
+		secret any
+	}{
+		{"string", EncryptedString("123-45-6789")},
+		{"empty", EncryptedString("")},
+		{"long", EncryptedString(long)},
+		{"bytes", EncryptedBytes{0, 1, 2, 255}},
+		{"null", EncryptedBytes(nil)},
+	}
+	for _, row := range inserts {
+		if _, err := db.Exec("INSERT INTO t VALUES (?, ?)", row.name, row.secret); err != nil {
+			t.Fatalf("%s: %v", row.name, err)
+		}
+	}
+
+	for i, row := range fakeDB.rows {
+		stored, _ := row[1].(string)
+		if inserts[i].name == "null" {
+			if row[1] != nil {
+				t.Errorf("nil EncryptedBytes should be stored as NULL, got %v", row[1])
+			}
+			continue
+		}
+		if !strings.HasPrefix(stored, kid+".") || strings.Count(stored, ".") != 3 || strings.Contains(stored, "123-45") {
+			t.Errorf("%s: stored %q", inserts[i].name, stored)
+		}
+	}
+
+	rows, err := db.Query("SELECT name, secret FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[string]string{}
+	for rows.Next() {
+		var name string
+		var secret EncryptedBytes
+		if err := rows.Scan(&name, &secret); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if secret == nil {
+			name += " (nil)"
+		}
+		got[name] = string(secret)
+	}
+	want := map[string]string{"string": "123-45-6789", "empty": "", "long": long, "bytes": "\x00\x01\x02\xff", "null (nil)": ""}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s: scanned %q, want %q", name, got[name], value)
+		}
+	}
+}
+
+func TestEncryptedColumns_Rotation(t *testing.T) {
+	db := openFakeDB(t)
+	oldKey, newKey := newTestKey(t, testPrivateKeys[3]), newTestKey(t, testPrivateKeys[4])
+	ring := NewKeyring()
+	ring.Add(oldKey)
+	RegisterColumnKeyring(ring)
+	if _, err := db.Exec("INSERT", "old", EncryptedString("before rotation")); err != nil {
+		t.Fatal(err)
+	}
+	newKID, _ := ring.Add(newKey)
+	ring.SetActive(newKID)
+	if _, err := db.Exec("INSERT", "new", EncryptedString("after rotation")); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var scanned []string
+	for rows.Next() {
+		var name string
+		var secret EncryptedString
+		if err := rows.Scan(&name, &secret); err != nil {
+			t.Fatal(err)
+		}
+		scanned = append(scanned, string(secret))
+	}
+	if strings.Join(scanned, "|") != "before rotation|after rotation" {
+		t.Errorf("Scanned %q", scanned)
+	}
+}
+
+func TestEncryptedColumns_Errors(t *testing.T) {
+	RegisterColumnKeyring(nil)
+	if _, err := EncryptedString("x").Value(); !errors.Is(err, ErrNoColumnKeys) {
+		t.Errorf("Unregistered: got %v", err)
+	}
+
+	key := newTestKey(t, testPrivateKeys[3])
+	if err := RegisterColumnKey(key); err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterColumnKeyring(nil)
+	value, err := EncryptedString("secret").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := value.(string)
+	kid, rest, _ := strings.Cut(stored, ".")
+
+	var s EncryptedString
+	tests := []struct {
+		name string
+		src  any
+		want error
+	}{
+		{"unknown key", "other." + rest, ErrUnknownKeyID},
+		{"truncated", stored[:len(stored)-4], ErrDecryption},
+		{"segments", kid + ".AAAA", ErrDecryption},
+		{"plaintext", "secret", ErrDecryption},
+	}
+	for _, tt := range tests {
+		if err := s.Scan(tt.src); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if err := s.Scan(42); err == nil {
+		t.Error("Scanning an integer should fail")
+	}
+	if err := s.Scan([]byte(stored)); err != nil || s != "secret" {
+		t.Errorf("Scan([]byte) = %q, %v", s, err)
+	}
+}
+
+func TestColumn_AAD(t *testing.T) {
+	RegisterColumnKeyring(nil)
+	ring := NewKeyring()
+	if _, err := ring.Add(newTestKey(t, testPrivateKeys[3])); err != nil {
+		t.Fatal(err)
+	}
+	alice := Column{Keys: ring, AAD: "users.ssn:1"}
+	ssn := EncryptedString("123-45-6789")
+	stored, err := alice.String(&ssn).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned EncryptedString
+	if err := alice.String(&scanned).Scan(stored); err != nil || scanned != ssn {
+		t.Errorf("Scan = %q, %v", scanned, err)
+	}
+	// Alice's value copied into Bob's row, or read without the AAD.
+	bob := Column{Keys: ring, AAD: "users.ssn:2"}
+	if err := bob.String(&scanned).Scan(stored); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Value from another row: got %v, want ErrDecryption", err)
+	}
+	if err := (Column{Keys: ring}).String(&scanned).Scan(stored); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Value read without its AAD: got %v, want ErrDecryption", err)
+	}
+
+	var data, null EncryptedBytes = EncryptedBytes{1, 2, 3}, nil
+	if value, err := alice.Bytes(&null).Value(); value != nil || err != nil {
+		t.Errorf("nil EncryptedBytes = %v, %v, want NULL", value, err)
+	}
+	stored, err = alice.Bytes(&data).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scannedBytes EncryptedBytes
+	if err := alice.Bytes(&scannedBytes).Scan(stored); err != nil || string(scannedBytes) != "\x01\x02\x03" {
+		t.Errorf("Scan = %v, %v", scannedBytes, err)
+	}
+	if err := alice.Bytes(&scannedBytes).Scan(nil); err != nil || scannedBytes != nil {
+		t.Errorf("Scan(NULL) = %v, %v", scannedBytes, err)
+	}
+}
+
+func TestColumn_NilPointer(t *testing.T) {
+	ring := NewKeyring()
+	if _, err := ring.Add(newTestKey(t, testPrivateKeys[3])); err != nil {
+		t.Fatal(err)
+	}
+	column := Column{Keys: ring}
+	for name, value := range map[string]*ColumnValue{"String": column.String(nil), "Bytes": column.Bytes(nil)} {
+		if stored, err := value.Value(); stored != nil || err != nil {
+			t.Errorf("%s(nil).Value() = %v, %v, want NULL", name, stored, err)
+		}
+		if err := value.Scan("x"); err == nil {
+			t.Errorf("%s(nil).Scan should fail", name)
+		}
+	}
+}
+
+func TestEncryptedColumns_PublicKeyOnly(t *testing.T) {
+	key := newTestKey(t, testPrivateKeys[3])
+	publicPEM, _ := key.GetPublicKey()
+	public := NewJSEncrypt()
+	if err := public.SetPublicKey(publicPEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterColumnKey(public); err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterColumnKeyring(nil)
+
+	stored, err := EncryptedString("secret").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s EncryptedString
+	if err := s.Scan(stored); !errors.Is(err, ErrPublicKeyOnly) {
+		t.Errorf("Scan with a public key: got %v, want ErrPublicKeyOnly", err)
+	}
+	if public.keys().privateKey != nil {
+		t.Error("Scan must not generate a key pair in place of the public key")
+	}
+}
@@ -0,0 +1,146 @@
+package jsencrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// envelopeVersion identifies the multi-recipient envelope format.
+const envelopeVersion = 1
+
+// envelope is the JSON form of a multi-recipient message. Binary fields are
+// base64url encoded.
+type envelope struct {
+	Version    int                 `json:"v"`
+	Recipients []envelopeRecipient `json:"recipients"`
+	Nonce      string              `json:"nonce"`
+	Ciphertext string              `json:"ciphertext"`
+}
+
+type envelopeRecipient struct {
+	KeyID string `json:"kid"`
+	Key   string `json:"key"`
+}
+
+// EncryptForRecipients encrypts plaintext once for several recipients, for
+// example a user plus an escrow or compliance key. The payload is encrypted
+// with AES-256-GCM and the content key is wrapped with RSA-OAEP-SHA256 for
+// each recipient's public key. The result is a JSON envelope that any one
+// recipient can open with DecryptEnvelope.
+//
+// The list of recipient key IDs is authenticated, so recipients cannot be
+// removed from the envelope unnoticed. Recipients with the same key are
+// included once.
+func EncryptForRecipients(plaintext []byte, recipients ...*JSEncrypt) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("envelope: no recipients")
+	}
+
+	var kids []string
+	var unique []*JSEncrypt
+	for _, recipient := range recipients {
+		kid, err := recipient.KeyID()
+		if err != nil {
+			return "", err
+		}
+		if !contains(kids, kid) {
+			kids = append(kids, kid)
+			unique = append(unique, recipient)
+		}
+	}
+
+	key, nonce, ciphertext, err := sealContent(plaintext, envelopeAAD(kids))
+	if err != nil {
+		return "", err
+	}
+	defer wipeBytes(key)
+
+	env := envelope{Version: envelopeVersion}
+	for i, recipient := range unique {
+		wrapped, err := recipient.WrapContentKey(key)
+		if err != nil {
+			return "", err
+		}
+		env.Recipients = append(env.Recipients, envelopeRecipient{KeyID: kids[i], Key: b64url.EncodeToString(wrapped)})
+	}
+	env.Nonce = b64url.EncodeToString(nonce)
+	env.Ciphertext = b64url.EncodeToString(ciphertext)
+
+	out, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// DecryptEnvelope opens an envelope from EncryptForRecipients with j's
+// private key. It returns the plaintext and the key ID of the recipient
+// entry that matched, or ErrKeyIDMismatch if the envelope was not encrypted
+// for this key.
+func (j *JSEncrypt) DecryptEnvelope(envelopeJSON string) ([]byte, string, error) {
+	env, err := parseEnvelope(envelopeJSON)
+	if err != nil {
+		return nil, "", err
+	}
+	kid, err := j.KeyID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var wrappedKey string
+	for _, recipient := range env.Recipients {
+		if recipient.KeyID == kid {
+			wrappedKey = recipient.Key
+		}
+	}
+	if wrappedKey == "" {
+		return nil, "", ErrKeyIDMismatch
+	}
+
+	segments, err := decodeSegments([]string{wrappedKey, env.Nonce, env.Ciphertext})
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := j.UnwrapContentKey(segments[0])
+	if err != nil {
+		return nil, "", err
+	}
+	defer wipeBytes(key)
+	plaintext, err := openContent(key, segments[1], segments[2], envelopeAAD(env.keyIDs()))
+	if err != nil {
+		return nil, "", err
+	}
+	return plaintext, kid, nil
+}
+
+// EnvelopeRecipients returns the key IDs an envelope was encrypted for,
+// without decrypting it.
+func EnvelopeRecipients(envelopeJSON string) ([]string, error) {
+	env, err := parseEnvelope(envelopeJSON)
+	if err != nil {
+		return nil, err
+	}
+	return env.keyIDs(), nil
+}
+
+func parseEnvelope(envelopeJSON string) (*envelope, error) {
+	var env envelope
+	if err := json.Unmarshal([]byte(envelopeJSON), &env); err != nil || env.Version != envelopeVersion {
+		return nil, ErrDecryption
+	}
+	return &env, nil
+}
+
+func (e *envelope) keyIDs() []string {
+	kids := make([]string, len(e.Recipients))
+	for i, recipient := range e.Recipients {
+		kids[i] = recipient.KeyID
+	}
+	return kids
+}
+
+// envelopeAAD authenticates the version and the ordered recipient list.
+func envelopeAAD(kids []string) []byte {
+	return []byte("jsencrypt envelope v1." + strings.Join(kids, "."))
+}
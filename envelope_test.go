@@ -0,0 +1,80 @@
+package jsencrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEncryptForRecipients(t *testing.T) {
+	user := newTestKey(t, exampleTestKeys.privateKey)
+	escrow := newTestKey(t, testPrivateKeys[3])
+	outsider := NewJSEncrypt()
+	if _, err := outsider.GetPrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	userPublic := NewJSEncrypt()
+	if err := userPublic.SetPublicKey(exampleTestKeys.publicKey); err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := EncryptForRecipients([]byte("shared secret"), userPublic, escrow, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kids, err := EnvelopeRecipients(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userKID, _ := user.KeyID()
+	escrowKID, _ := escrow.KeyID()
+	if len(kids) != 2 || kids[0] != userKID || kids[1] != escrowKID {
+		t.Errorf("Expected recipients [%s %s] without duplicates, got %v", userKID, escrowKID, kids)
+	}
+
+	for name, recipient := range map[string]*JSEncrypt{"user": user, "escrow": escrow} {
+		plaintext, kid, err := recipient.DecryptEnvelope(envelope)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(plaintext) != "shared secret" {
+			t.Errorf("%s: plaintext = %q", name, plaintext)
+		}
+		if want, _ := recipient.KeyID(); kid != want {
+			t.Errorf("%s: matched key ID %s, want %s", name, kid, want)
+		}
+	}
+
+	if _, _, err := outsider.DecryptEnvelope(envelope); !errors.Is(err, ErrKeyIDMismatch) {
+		t.Errorf("Expected ErrKeyIDMismatch for a non-recipient, got %v", err)
+	}
+}
+
+func TestDecryptEnvelope_RecipientListIsAuthenticated(t *testing.T) {
+	user := newTestKey(t, exampleTestKeys.privateKey)
+	escrow := newTestKey(t, testPrivateKeys[3])
+	envelopeJSON, err := EncryptForRecipients([]byte("audited"), user, escrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Strip the escrow recipient.
+	var env envelope
+	if err := json.Unmarshal([]byte(envelopeJSON), &env); err != nil {
+		t.Fatal(err)
+	}
+	env.Recipients = env.Recipients[:1]
+	stripped, _ := json.Marshal(env)
+	if _, _, err := user.DecryptEnvelope(string(stripped)); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Removing a recipient must be detected, got %v", err)
+	}
+
+	if _, _, err := user.DecryptEnvelope("{not json"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for malformed JSON, got %v", err)
+	}
+	if _, err := EncryptForRecipients([]byte("x")); err == nil {
+		t.Error("Expected an error without recipients")
+	}
+}
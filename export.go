@@ -0,0 +1,151 @@
+package jsencrypt
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// KeyFormat selects the ASN.1 structure used when exporting a key.
+type KeyFormat int
+
+const (
+	// FormatDefault is PKCS#1 for private keys and PKIX for public keys,
+	// the formats produced by GetPrivateKey and GetPublicKey.
+	FormatDefault KeyFormat = iota
+	// FormatPKCS1 is "RSA PRIVATE KEY" / "RSA PUBLIC KEY" (RFC 8017).
+	FormatPKCS1
+	// FormatPKCS8 is "PRIVATE KEY" (RFC 5208). Private keys only.
+	FormatPKCS8
+	// FormatPKIX is "PUBLIC KEY" (SubjectPublicKeyInfo, RFC 5280). Public keys only.
+	FormatPKIX
+)
+
+// KeyEncoding selects how the DER bytes of an exported key are written.
+type KeyEncoding int
+
+const (
+	// EncodingPEM writes a PEM block with BEGIN/END lines.
+	EncodingPEM KeyEncoding = iota
+	// EncodingDER writes the raw DER bytes.
+	EncodingDER
+	// EncodingBase64 writes the DER bytes as base64 without PEM headers,
+	// like getPrivateKeyB64/getPublicKeyB64 in JSEncrypt.
+	EncodingBase64
+)
+
+// ExportOptions configures ExportPrivateKey and ExportPublicKey.
+type ExportOptions struct {
+	Format   KeyFormat
+	Encoding KeyEncoding
+	// LineWidth is the number of base64 characters per line. For PEM the
+	// default is 64; for EncodingBase64 the default is a single line.
+	LineWidth int
+}
+
+// ExportPrivateKey returns the private key in the requested format and
+// encoding, generating a key pair if none is set.
+func (j *JSEncrypt) ExportPrivateKey(opts ExportOptions) ([]byte, error) {
+	priv, release, err := j.getKey()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var der []byte
+	var pemType string
+	switch opts.Format {
+	case FormatDefault, FormatPKCS1:
+		der, pemType = x509.MarshalPKCS1PrivateKey(priv), "RSA PRIVATE KEY"
+	case FormatPKCS8:
+		if der, err = x509.MarshalPKCS8PrivateKey(priv); err != nil {
+			return nil, err
+		}
+		pemType = "PRIVATE KEY"
+	default:
+		return nil, errors.New("unsupported private key format")
+	}
+	return encodeKey(der, pemType, opts)
+}
+
+// ExportPublicKey returns the public key in the requested format and
+// encoding, generating a key pair if none is set.
+func (j *JSEncrypt) ExportPublicKey(opts ExportOptions) ([]byte, error) {
+	pub, err := j.ensurePublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var der []byte
+	var pemType string
+	switch opts.Format {
+	case FormatDefault, FormatPKIX:
+		if der, err = x509.MarshalPKIXPublicKey(pub); err != nil {
+			return nil, err
+		}
+		pemType = "PUBLIC KEY"
+	case FormatPKCS1:
+		der, pemType = x509.MarshalPKCS1PublicKey(pub), "RSA PUBLIC KEY"
+	default:
+		return nil, errors.New("unsupported public key format")
+	}
+	return encodeKey(der, pemType, opts)
+}
+
+// GetPrivateKeyB64 returns the PKCS#1 private key as base64 without PEM
+// headers or line breaks.
+func (j *JSEncrypt) GetPrivateKeyB64() (string, error) {
+	out, err := j.ExportPrivateKey(ExportOptions{Encoding: EncodingBase64})
+	return string(out), err
+}
+
+// GetPublicKeyB64 returns the PKIX public key as base64 without PEM headers
+// or line breaks.
+func (j *JSEncrypt) GetPublicKeyB64() (string, error) {
+	out, err := j.ExportPublicKey(ExportOptions{Encoding: EncodingBase64})
+	return string(out), err
+}
+
+func encodeKey(der []byte, pemType string, opts ExportOptions) ([]byte, error) {
+	if opts.LineWidth < 0 {
+		return nil, errors.New("line width must not be negative")
+	}
+
+	switch opts.Encoding {
+	case EncodingDER:
+		return der, nil
+	case EncodingBase64:
+		return []byte(wrapBase64(der, opts.LineWidth, "")), nil
+	case EncodingPEM:
+		width := opts.LineWidth
+		if width == 0 {
+			width = 64
+		}
+		var b strings.Builder
+		b.WriteString("-----BEGIN " + pemType + "-----\n")
+		b.WriteString(wrapBase64(der, width, "\n"))
+		b.WriteString("-----END " + pemType + "-----\n")
+		return []byte(b.String()), nil
+	}
+	return nil, errors.New("unsupported key encoding")
+}
+
+// wrapBase64 encodes data and splits it into lines of width characters,
+// each followed by eol. A width of zero produces a single line.
+func wrapBase64(data []byte, width int, eol string) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if width == 0 {
+		return encoded + eol
+	}
+
+	var b strings.Builder
+	for len(encoded) > width {
+		b.WriteString(encoded[:width])
+		b.WriteString("\n")
+		encoded = encoded[width:]
+	}
+	b.WriteString(encoded)
+	b.WriteString(eol)
+	return b.String()
+}
@@ -0,0 +1,378 @@
+package jsencrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrFieldSignature is returned by FieldEncryptor.Decrypt for a signed field
+// whose value does not match its signature.
+var ErrFieldSignature = errors.New("field signature mismatch")
+
+// fieldTag is the struct tag read by FieldEncryptor.
+const fieldTag = "jsencrypt"
+
+// FieldError reports the struct field that FieldEncryptor failed on. Path
+// names the field from the top-level value, e.g. "Cards[1].Number".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return "field " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldEncryptor encrypts and signs struct fields declared with struct
+// tags, so a document can be protected before it is stored:
+//
+//	type Customer struct {
+//		Name           string
+//		SSN            string `jsencrypt:"encrypt"`
+//		Cards          []Card
+//		Limit          int `jsencrypt:"sign"`
+//		LimitSignature string
+//	}
+//
+// A field tagged "encrypt" must hold strings: a string, or pointers, slices,
+// arrays and map values of strings. Each non-empty string is replaced by its
+// Encrypt output (base64 PKCS#1 v1.5, compatible with JSEncrypt), so it is
+// limited by the key size like Encrypt. Empty strings are left empty.
+//
+// A field tagged "sign" keeps its value; a signature over the field name and
+// its JSON encoding is stored in a string field named by the signature
+// option, by default the field name with "Signature" appended:
+//
+//	Limit    int    `jsencrypt:"sign,signature=LimitSig"`
+//
+// Signatures made by Encrypt do not identify the record they belong to, so
+// a signed value and its signature can be copied into another record of
+// the same type and still verify. Use EncryptContext and DecryptContext
+// with a record identifier, such as a primary key, to prevent that.
+// Encrypted values are never bound to their record; to bind one, place it
+// inside a signed field, whose signature covers its encrypted form.
+//
+// Untagged fields are walked through pointers, interfaces, structs,
+// slices, arrays and maps to find tagged fields in nested values. Fields
+// tagged "-" and unexported fields are skipped. Values reached through the
+// same pointer twice are processed once.
+type FieldEncryptor struct {
+	// Key encrypts and signs with its public and private key.
+	Key *JSEncrypt
+}
+
+// NewFieldEncryptor returns a FieldEncryptor that uses key.
+func NewFieldEncryptor(key *JSEncrypt) *FieldEncryptor {
+	return &FieldEncryptor{Key: key}
+}
+
+// Encrypt encrypts and signs the tagged fields of the value v points to, in
+// place. Signatures cover nested fields in their encrypted form. Calling
+// Encrypt twice encrypts the fields twice.
+func (f *FieldEncryptor) Encrypt(v any) error {
+	return f.walk(v, true, "")
+}
+
+// Decrypt verifies and decrypts the tagged fields of the value v points to,
+// in place. A field whose signature does not verify yields a FieldError
+// wrapping ErrFieldSignature.
+func (f *FieldEncryptor) Decrypt(v any) error {
+	return f.walk(v, false, "")
+}
+
+// EncryptContext is like Encrypt, but the signatures also cover context,
+// typically the ID of the record v is stored as. They verify only with
+// DecryptContext and the same context. An empty context is the same as
+// calling Encrypt.
+func (f *FieldEncryptor) EncryptContext(v any, context string) error {
+	return f.walk(v, true, context)
+}
+
+// DecryptContext is like Decrypt for values encrypted with EncryptContext.
+// Signatures made with a different context yield ErrFieldSignature.
+func (f *FieldEncryptor) DecryptContext(v any, context string) error {
+	return f.walk(v, false, context)
+}
+
+func (f *FieldEncryptor) walk(v any, encrypt bool, context string) error {
+	if f.Key == nil {
+		return ErrNoKey
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("FieldEncryptor: need a non-nil pointer, got %T", v)
+	}
+	w := &fieldWalker{key: f.Key, encrypt: encrypt, context: context, seen: make(map[seenPointer]bool)}
+	return w.walk(rv, "")
+}
+
+// seenPointer identifies a pointed-to value. The type is part of the key
+// because a struct and its first field share an address.
+type seenPointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+type fieldWalker struct {
+	key     *JSEncrypt
+	encrypt bool
+	context string
+	seen    map[seenPointer]bool
+}
+
+// visit reports whether the value behind pointer p is reached for the first
+// time.
+func (w *fieldWalker) visit(p reflect.Value) bool {
+	id := seenPointer{p.Pointer(), p.Type()}
+	if w.seen[id] {
+		return false
+	}
+	w.seen[id] = true
+	return true
+}
+
+// walk looks for tagged fields inside v.
+func (w *fieldWalker) walk(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || !w.visit(v) {
+			return nil
+		}
+		return w.walk(v.Elem(), path)
+	case reflect.Interface:
+		return w.walkCopy(v, path, w.walk)
+	case reflect.Struct:
+		return w.walkStruct(v, path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.walk(v.Index(i), indexPath(path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return w.walkMap(v, path, w.walk)
+	}
+	return nil
+}
+
+// transform encrypts or decrypts the strings inside a field tagged
+// "encrypt".
+func (w *fieldWalker) transform(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return nil
+		}
+		var out string
+		var err error
+		if w.encrypt {
+			out, err = w.key.Encrypt(v.String())
+		} else {
+			out, err = w.key.Decrypt(v.String())
+		}
+		if err != nil {
+			return &FieldError{Path: path, Err: err}
+		}
+		v.SetString(out)
+	case reflect.Pointer:
+		if v.IsNil() || !w.visit(v) {
+			return nil
+		}
+		return w.transform(v.Elem(), path)
+	case reflect.Interface:
+		return w.walkCopy(v, path, w.transform)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &FieldError{Path: path, Err: fmt.Errorf("cannot encrypt %s, use a string", v.Type())}
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := w.transform(v.Index(i), indexPath(path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return w.walkMap(v, path, w.transform)
+	default:
+		return &FieldError{Path: path, Err: fmt.Errorf("cannot encrypt %s", v.Type())}
+	}
+	return nil
+}
+
+// walkCopy applies fn to a settable copy of the value held by an interface
+// and stores the result back.
+func (w *fieldWalker) walkCopy(v reflect.Value, path string, fn func(reflect.Value, string) error) error {
+	if v.IsNil() {
+		return nil
+	}
+	elem := v.Elem()
+	if elem.Kind() == reflect.Pointer {
+		return fn(elem, path)
+	}
+	if !v.CanSet() {
+		return nil
+	}
+	copied := reflect.New(elem.Type()).Elem()
+	copied.Set(elem)
+	if err := fn(copied, path); err != nil {
+		return err
+	}
+	v.Set(copied)
+	return nil
+}
+
+// walkMap applies fn to a settable copy of every map value and stores the
+// results back, since map values are not addressable.
+func (w *fieldWalker) walkMap(v reflect.Value, path string, fn func(reflect.Value, string) error) error {
+	iter := v.MapRange()
+	for iter.Next() {
+		copied := reflect.New(v.Type().Elem()).Elem()
+		copied.Set(iter.Value())
+		if err := fn(copied, fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+			return err
+		}
+		v.SetMapIndex(iter.Key(), copied)
+	}
+	return nil
+}
+
+// walkStruct handles the fields of a struct. When encrypting, nested values
+// are encrypted before the struct's own fields are signed; when decrypting,
+// signatures are verified before anything is decrypted.
+func (w *fieldWalker) walkStruct(v reflect.Value, path string) error {
+	t := v.Type()
+	var signed []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		mode, _, err := parseFieldTag(field)
+		if err != nil {
+			return &FieldError{Path: fieldPath(path, field.Name), Err: err}
+		}
+		if mode == "sign" {
+			signed = append(signed, i)
+		}
+	}
+	if !w.encrypt {
+		if err := w.signFields(v, path, signed); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		mode, _, _ := parseFieldTag(field)
+		if mode == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		var err error
+		if mode == "encrypt" {
+			err = w.transform(v.Field(i), fieldPath(path, field.Name))
+		} else {
+			err = w.walk(v.Field(i), fieldPath(path, field.Name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.encrypt {
+		return w.signFields(v, path, signed)
+	}
+	return nil
+}
+
+// signFields signs, or verifies the signatures of, the given fields of a
+// struct.
+func (w *fieldWalker) signFields(v reflect.Value, path string, fields []int) error {
+	for _, i := range fields {
+		field := v.Type().Field(i)
+		_, sigName, _ := parseFieldTag(field)
+		fail := func(err error) error {
+			return &FieldError{Path: fieldPath(path, field.Name), Err: err}
+		}
+
+		sigField, ok := v.Type().FieldByName(sigName)
+		if !ok || sigField.Type.Kind() != reflect.String || !sigField.IsExported() || len(sigField.Index) != 1 {
+			return fail(fmt.Errorf("signature field %s must be an exported string field", sigName))
+		}
+		value, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			return fail(err)
+		}
+		// JSON escapes NUL, so the separators are unambiguous, and
+		// signatures without a context keep their original form.
+		data := field.Name + "\x00" + string(value)
+		if w.context != "" {
+			data += "\x00" + w.context
+		}
+		sig := v.FieldByIndex(sigField.Index)
+
+		if w.encrypt {
+			signature, err := w.key.Sign(data)
+			if err != nil {
+				return fail(err)
+			}
+			sig.SetString(signature)
+			continue
+		}
+		if sig.Len() == 0 {
+			return fail(ErrFieldSignature)
+		}
+		ok, err = w.key.Verify(data, sig.String())
+		if err != nil || !ok {
+			return fail(ErrFieldSignature)
+		}
+	}
+	return nil
+}
+
+// parseFieldTag returns the mode ("encrypt", "sign", "-" or "") and, for
+// "sign", the name of the signature field.
+func parseFieldTag(field reflect.StructField) (mode, signature string, err error) {
+	tag, ok := field.Tag.Lookup(fieldTag)
+	if !ok {
+		return "", "", nil
+	}
+	mode, options, _ := strings.Cut(tag, ",")
+	switch mode {
+	case "-", "encrypt":
+		if options != "" {
+			return "", "", fmt.Errorf("unexpected options in tag %q", tag)
+		}
+	case "sign":
+		signature = field.Name + "Signature"
+		for _, option := range strings.Split(options, ",") {
+			name, value, _ := strings.Cut(option, "=")
+			switch {
+			case option == "":
+			case name == "signature" && value != "":
+				signature = value
+			default:
+				return "", "", fmt.Errorf("unknown option %q in tag %q", option, tag)
+			}
+		}
+	default:
+		return "", "", fmt.Errorf("unknown tag %q", tag)
+	}
+	if mode != "-" && !field.IsExported() {
+		return "", "", errors.New("unexported fields cannot be encrypted or signed")
+	}
+	return mode, signature, nil
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
@@ -0,0 +1,207 @@
+package jsencrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testCard struct {
+	Holder string
+	Number string `jsencrypt:"encrypt"`
+}
+
+type testCustomer struct {
+	Name           string
+	SSN            string            `jsencrypt:"encrypt"`
+	Phone          *string           `jsencrypt:"encrypt"`
+	Aliases        []string          `jsencrypt:"encrypt"`
+	Notes          map[string]string `jsencrypt:"encrypt"`
+	Optional       string            `jsencrypt:"encrypt"`
+	Cards          []testCard
+	Primary        *testCard
+	ByLabel        map[string]testCard
+	Extra          any
+	Limit          int `jsencrypt:"sign"`
+	LimitSignature string
+	Tier           string `jsencrypt:"sign,signature=TierSig"`
+	TierSig        string
+	Cache          string `jsencrypt:"-"`
+	internal       string
+}
+
+func newTestCustomer() *testCustomer {
+	phone := "555-0100"
+	primary := &testCard{Holder: "A", Number: "4111111111111111"}
+	return &testCustomer{
+		Name:     "Alice",
+		SSN:      "123-45-6789",
+		Phone:    &phone,
+		Aliases:  []string{"al", "ally"},
+		Notes:    map[string]string{"vip": "yes"},
+		Cards:    []testCard{{Holder: "A", Number: "5500000000000004"}},
+		Primary:  primary,
+		ByLabel:  map[string]testCard{"work": {Holder: "A", Number: "340000000000009"}},
+		Extra:    testCard{Holder: "A", Number: "6011000000000004"},
+		Limit:    5000,
+		Tier:     "gold",
+		Cache:    "plain",
+		internal: "plain",
+	}
+}
+
+func TestFieldEncryptor(t *testing.T) {
+	fields := NewFieldEncryptor(newTestKey(t, testPrivateKeys[3]))
+	customer := newTestCustomer()
+	want := newTestCustomer()
+
+	if err := fields.Encrypt(customer); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := []string{customer.SSN, *customer.Phone, customer.Aliases[0], customer.Aliases[1], customer.Notes["vip"],
+		customer.Cards[0].Number, customer.Primary.Number, customer.ByLabel["work"].Number, customer.Extra.(testCard).Number}
+	for i, value := range encrypted {
+		if len(value) < 100 {
+			t.Errorf("Value %d was not encrypted: %q", i, value)
+		}
+	}
+	if customer.Name != "Alice" || customer.Cards[0].Holder != "A" || customer.Cache != "plain" || customer.internal != "plain" || customer.Optional != "" {
+		t.Errorf("Untagged fields must be left alone: %+v", customer)
+	}
+	if customer.Limit != 5000 || customer.LimitSignature == "" || customer.TierSig == "" {
+		t.Errorf("Signed fields: %+v", customer)
+	}
+
+	// Round trip through JSON, as when the document is stored.
+	stored, err := json.Marshal(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded testCustomer
+	if err := json.Unmarshal(stored, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Extra = customer.Extra
+	if err := fields.Decrypt(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.internal = "plain"
+	want.LimitSignature, want.TierSig = loaded.LimitSignature, loaded.TierSig
+	if !reflect.DeepEqual(&loaded, want) {
+		t.Errorf("Decrypted = %+v\nwant %+v", loaded, want)
+	}
+}
+
+func TestFieldEncryptor_Signatures(t *testing.T) {
+	fields := NewFieldEncryptor(newTestKey(t, testPrivateKeys[3]))
+	customer := newTestCustomer()
+	if err := fields.Encrypt(customer); err != nil {
+		t.Fatal(err)
+	}
+	original := *customer
+
+	customer.Limit = 1000000
+	var fieldErr *FieldError
+	if err := fields.Decrypt(customer); !errors.Is(err, ErrFieldSignature) || !errors.As(err, &fieldErr) || fieldErr.Path != "Limit" {
+		t.Errorf("Tampered value: got %v", err)
+	}
+
+	// A signature cannot be moved to another field with the same value.
+	swapped := original
+	swapped.Tier, swapped.TierSig = "5000", original.LimitSignature
+	if err := fields.Decrypt(&swapped); !errors.Is(err, ErrFieldSignature) {
+		t.Errorf("Moved signature: got %v", err)
+	}
+
+	stripped := original
+	stripped.LimitSignature = ""
+	if err := fields.Decrypt(&stripped); !errors.Is(err, ErrFieldSignature) {
+		t.Errorf("Missing signature: got %v", err)
+	}
+}
+
+func TestFieldEncryptor_Context(t *testing.T) {
+	type account struct {
+		Limit          int `jsencrypt:"sign"`
+		LimitSignature string
+	}
+	fields := NewFieldEncryptor(newTestKey(t, testPrivateKeys[3]))
+	alice := account{Limit: 1000000}
+	if err := fields.EncryptContext(&alice, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copying Alice's signed limit into Bob's record must not verify.
+	bob := alice
+	if err := fields.DecryptContext(&bob, "user:2"); !errors.Is(err, ErrFieldSignature) {
+		t.Errorf("Signature from another record: got %v", err)
+	}
+	if err := fields.Decrypt(&bob); !errors.Is(err, ErrFieldSignature) {
+		t.Errorf("Signature with a context verified without it: got %v", err)
+	}
+	if err := fields.DecryptContext(&alice, "user:1"); err != nil {
+		t.Errorf("DecryptContext: %v", err)
+	}
+
+	// An empty context signs like Encrypt.
+	plain := account{Limit: 5}
+	if err := fields.EncryptContext(&plain, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fields.Decrypt(&plain); err != nil {
+		t.Errorf("Decrypt after EncryptContext with no context: %v", err)
+	}
+}
+
+func TestFieldEncryptor_SharedPointer(t *testing.T) {
+	fields := NewFieldEncryptor(newTestKey(t, testPrivateKeys[3]))
+	card := &testCard{Number: "4111111111111111"}
+	doc := struct{ A, B *testCard }{card, card}
+	if err := fields.Encrypt(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := fields.Decrypt(&doc); err != nil || card.Number != "4111111111111111" {
+		t.Errorf("Shared pointer must be encrypted once: %q, %v", card.Number, err)
+	}
+}
+
+func TestFieldEncryptor_Errors(t *testing.T) {
+	fields := NewFieldEncryptor(newTestKey(t, testPrivateKeys[3]))
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not a pointer", testCard{}},
+		{"nil pointer", (*testCard)(nil)},
+		{"non-string", &struct {
+			N int `jsencrypt:"encrypt"`
+		}{1}},
+		{"bytes", &struct {
+			B []byte `jsencrypt:"encrypt"`
+		}{[]byte("x")}},
+		{"unknown tag", &struct {
+			S string `jsencrypt:"hash"`
+		}{}},
+		{"unknown option", &struct {
+			S string `jsencrypt:"sign,salt=x"`
+		}{}},
+		{"missing signature field", &struct {
+			S string `jsencrypt:"sign"`
+		}{}},
+		{"unexported", &struct {
+			s string `jsencrypt:"encrypt"`
+		}{}},
+	}
+	for _, tt := range tests {
+		if err := fields.Encrypt(tt.v); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	nested := &struct{ Cards []testCard }{[]testCard{{Number: ""}, {Number: "not base64!"}}}
+	var fieldErr *FieldError
+	if err := fields.Decrypt(nested); !errors.As(err, &fieldErr) || fieldErr.Path != "Cards[1].Number" || !errors.Is(err, ErrDecryption) {
+		t.Errorf("Decrypt error: got %v", err)
+	}
+}
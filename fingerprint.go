@@ -0,0 +1,135 @@
+package jsencrypt
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrNoKey is returned by fingerprints, KeyID and operations that inspect a
+// key when neither a private nor a public key has been set.
+var ErrNoKey = errors.New("no key set")
+
+// FingerprintSHA256 returns the lower-case hex SHA-256 digest of the DER
+// encoded SubjectPublicKeyInfo, as printed by
+// "openssl pkey -pubin -outform DER | sha256sum".
+func (j *JSEncrypt) FingerprintSHA256() (string, error) {
+	sum, err := j.spkiDigest()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FingerprintSHA256Base64 returns the SHA-256 SubjectPublicKeyInfo digest in
+// the OpenSSH display style: "SHA256:" followed by unpadded base64.
+//
+// The digest covers the SPKI DER encoding, so it differs from the value
+// printed by ssh-keygen, which hashes the SSH wire format key.
+func (j *JSEncrypt) FingerprintSHA256Base64() (string, error) {
+	sum, err := j.spkiDigest()
+	if err != nil {
+		return "", err
+	}
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// FingerprintMD5 returns the legacy colon separated hex MD5 digest of the
+// DER encoded SubjectPublicKeyInfo, e.g. "8f:2a:...".
+func (j *JSEncrypt) FingerprintMD5() (string, error) {
+	der, err := j.spki()
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(der)
+	hexSum := hex.EncodeToString(sum[:])
+
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		parts = append(parts, hexSum[i:i+2])
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// JWKThumbprint returns the RFC 7638 SHA-256 JWK thumbprint of the public
+// key, base64url encoded without padding.
+func (j *JSEncrypt) JWKThumbprint() (string, error) {
+	pub, err := j.identityKey()
+	if err != nil {
+		return "", err
+	}
+	return jwkThumbprint(pub), nil
+}
+
+// KeyID returns a stable identifier for the key pair, suitable for
+// embedding in ciphertext envelopes and signatures so the receiver can
+// select the right key. It is the RFC 7638 JWK thumbprint, so it matches
+// the "kid" commonly used for the same key in JOSE.
+func (j *JSEncrypt) KeyID() (string, error) {
+	return j.JWKThumbprint()
+}
+
+// identityKey returns the public key for fingerprints and key IDs, or
+// ErrNoKey if none is set. Unlike ensurePublicKey it never generates a key
+// pair, so an identifier always names a key the caller chose.
+func (j *JSEncrypt) identityKey() (*rsa.PublicKey, error) {
+	keys, err := j.openKeys()
+	if err != nil {
+		return nil, err
+	}
+	pub := keys.public()
+	if pub == nil {
+		return nil, ErrNoKey
+	}
+	return pub, nil
+}
+
+// ensurePublicKey returns the public key, generating a key pair if neither
+// key is set.
+func (j *JSEncrypt) ensurePublicKey() (*rsa.PublicKey, error) {
+	keys, err := j.openKeys()
+	if err != nil {
+		return nil, err
+	}
+	if keys.publicKey != nil {
+		return keys.publicKey, nil
+	}
+	priv, release, err := j.getKey()
+	if err != nil {
+		return nil, err
+	}
+	// Close leaves the public values intact.
+	release()
+	return &priv.PublicKey, nil
+}
+
+func (j *JSEncrypt) spki() ([]byte, error) {
+	pub, err := j.identityKey()
+	if err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKIXPublicKey(pub)
+}
+
+func (j *JSEncrypt) spkiDigest() ([sha256.Size]byte, error) {
+	der, err := j.spki()
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(der), nil
+}
+
+// jwkThumbprint hashes the required RSA JWK members in lexicographic order
+// with no whitespace (RFC 7638, section 3.2).
+func jwkThumbprint(pub *rsa.PublicKey) string {
+	canonical := `{"e":"` + base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()) +
+		`","kty":"RSA","n":"` + base64.RawURLEncoding.EncodeToString(pub.N.Bytes()) + `"}`
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package jsencrypt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestJSEncrypt_Fingerprints(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPublicKey(exampleTestKeys.publicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expected values computed with:
+	//   openssl pkey -pubin -outform DER | sha256sum / md5sum
+	hexSum, err := jsCrypt.FingerprintSHA256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "e51187094891e6a1cdc372185cb93859c84468a21cc9332d71d21f81f35c409f"; hexSum != want {
+		t.Errorf("FingerprintSHA256 = %s, want %s", hexSum, want)
+	}
+
+	b64Sum, err := jsCrypt.FingerprintSHA256Base64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SHA256:5RGHCUiR5qHNw3IYXLk4WchEaKIcyTMtcdIfgfNcQJ8"; b64Sum != want {
+		t.Errorf("FingerprintSHA256Base64 = %s, want %s", b64Sum, want)
+	}
+
+	md5Sum, err := jsCrypt.FingerprintMD5()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "78:86:94:33:3d:61:dc:1c:f5:75:de:d0:d8:85:08:7d"; md5Sum != want {
+		t.Errorf("FingerprintMD5 = %s, want %s", md5Sum, want)
+	}
+
+	// The private key must produce the same identifiers as its public half.
+	priv := NewJSEncrypt()
+	if err := priv.SetPrivateKey(exampleTestKeys.privateKey); err != nil {
+		t.Fatal(err)
+	}
+	pubID, err := jsCrypt.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privID, err := priv.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubID != privID {
+		t.Errorf("KeyID differs between private and public key: %s vs %s", privID, pubID)
+	}
+}
+
+func TestJSEncrypt_FingerprintsNoKey(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	identifiers := map[string]func() (string, error){
+		"KeyID":                   jsCrypt.KeyID,
+		"JWKThumbprint":           jsCrypt.JWKThumbprint,
+		"FingerprintSHA256":       jsCrypt.FingerprintSHA256,
+		"FingerprintSHA256Base64": jsCrypt.FingerprintSHA256Base64,
+		"FingerprintMD5":          jsCrypt.FingerprintMD5,
+	}
+	for name, identifier := range identifiers {
+		if id, err := identifier(); !errors.Is(err, ErrNoKey) {
+			t.Errorf("%s of an empty instance = %q, %v, want ErrNoKey", name, id, err)
+		}
+	}
+	if jsCrypt.keys().public() != nil {
+		t.Error("An identifier must not generate a key pair")
+	}
+}
+
+// Example from RFC 7638, section 3.1.
+func TestJWKThumbprintRFC7638(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsCrypt := NewJSEncrypt()
+	jsCrypt.publicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	thumbprint, err := jsCrypt.JWKThumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != want {
+		t.Errorf("JWKThumbprint = %s, want %s", thumbprint, want)
+	}
+}
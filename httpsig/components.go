@@ -0,0 +1,195 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// signatureBase builds the signature base (RFC 9421, section 2.5): one line
+// per covered component followed by the @signature-params line.
+func signatureBase(req *http.Request, items []item, signatureParams string) (string, error) {
+	var b strings.Builder
+	for _, it := range items {
+		name := it.value.(string)
+		value, err := componentValue(req, name)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(serializeBareItem(name))
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(signatureParams)
+	return b.String(), nil
+}
+
+// componentValue returns the canonical value of a derived component or
+// header field.
+func componentValue(req *http.Request, name string) (string, error) {
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@authority":
+		return authority(req), nil
+	case "@scheme":
+		return scheme(req), nil
+	case "@target-uri":
+		return scheme(req) + "://" + authority(req) + requestTarget(req), nil
+	case "@request-target":
+		return requestTarget(req), nil
+	case "@path":
+		return path(req), nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(name, "@") {
+		return "", ErrMalformed
+	}
+
+	// Header.Values returns the header's own slice, so trim a copy.
+	values := append([]string(nil), req.Header.Values(name)...)
+	if len(values) == 0 {
+		return "", ErrMissingComponent
+	}
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+func scheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// authority is the lower-case host, without the port if it is the default
+// for the scheme.
+func authority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+	switch s := scheme(req); {
+	case s == "http" && strings.HasSuffix(host, ":80"):
+		host = strings.TrimSuffix(host, ":80")
+	case s == "https" && strings.HasSuffix(host, ":443"):
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+func path(req *http.Request) string {
+	if p := req.URL.EscapedPath(); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func requestTarget(req *http.Request) string {
+	if req.URL.RawQuery != "" {
+		return path(req) + "?" + req.URL.RawQuery
+	}
+	return path(req)
+}
+
+// SetContentDigest sets the Content-Digest field (RFC 9530) of req to the
+// SHA-256 digest of its body. The body is read and replaced.
+func SetContentDigest(req *http.Request) error {
+	body, err := readBody(req, -1)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("Content-Digest", "sha-256="+serializeBareItem(sum[:]))
+	return nil
+}
+
+// VerifyContentDigest checks the sha-256 and sha-512 entries of the
+// Content-Digest field against the body of req. At least one of them must
+// be present. The body is read and replaced; bodies larger than
+// DefaultMaxBodySize fail with ErrBodyTooLarge.
+func VerifyContentDigest(req *http.Request) error {
+	return verifyContentDigest(req, DefaultMaxBodySize)
+}
+
+func verifyContentDigest(req *http.Request, maxBodySize int64) error {
+	field := strings.Join(req.Header.Values("Content-Digest"), ", ")
+	if field == "" {
+		return ErrMissingComponent
+	}
+	digests, err := parseDictionary(field)
+	if err != nil {
+		return ErrMalformed
+	}
+	body, err := readBody(req, maxBodySize)
+	if err != nil {
+		return err
+	}
+
+	checked := false
+	for _, d := range digests {
+		var sum []byte
+		switch d.key {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		got, ok := d.item.value.([]byte)
+		if !ok || d.isList || subtle.ConstantTimeCompare(got, sum) != 1 {
+			return ErrDigest
+		}
+		checked = true
+	}
+	if !checked {
+		return ErrDigest
+	}
+	return nil
+}
+
+// readBody reads the whole body and replaces it with an identical reader.
+// A body longer than limit fails with ErrBodyTooLarge and is left readable
+// from the start; a negative limit reads any size.
+func readBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	r := io.Reader(req.Body)
+	if limit >= 0 {
+		r = io.LimitReader(req.Body, limit+1)
+	}
+	body, err := io.ReadAll(r)
+	if err == nil && limit >= 0 && int64(len(body)) > limit {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, ErrBodyTooLarge
+	}
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
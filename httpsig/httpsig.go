@@ -0,0 +1,385 @@
+// Package httpsig signs and verifies HTTP requests with HTTP Message
+// Signatures (RFC 9421) using the RSA keys of a jsencrypt.JSEncrypt.
+//
+// The rsa-v1_5-sha256 and rsa-pss-sha512 algorithms are supported. A
+// signature covers derived components such as @method, @authority and
+// @path, and any request header fields. When content-digest is covered, the
+// Content-Digest header (RFC 9530) is computed when signing and checked
+// against the body when verifying.
+package httpsig
+
+import (
+	"crypto"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+var (
+	// ErrNoSignature is returned for a request without a signature.
+	ErrNoSignature = errors.New("httpsig: request is not signed")
+	// ErrMalformed is returned for unparsable Signature-Input or Signature
+	// fields, or unsupported component identifiers.
+	ErrMalformed = errors.New("httpsig: malformed signature")
+	// ErrAlgorithm is returned for a signature using an algorithm that is
+	// not allowed.
+	ErrAlgorithm = errors.New("httpsig: algorithm not allowed")
+	// ErrMissingComponent is returned when a required component is not
+	// covered by the signature, or a covered component is absent from the
+	// request.
+	ErrMissingComponent = errors.New("httpsig: missing component")
+	// ErrExpired is returned for a signature that is too old, expired, or
+	// created in the future.
+	ErrExpired = errors.New("httpsig: signature expired")
+	// ErrDigest is returned when the Content-Digest does not match the body.
+	ErrDigest = errors.New("httpsig: content digest mismatch")
+	// ErrSignature is returned when the signature does not verify.
+	ErrSignature = errors.New("httpsig: invalid signature")
+	// ErrBodyTooLarge is returned when a body to be checked against the
+	// Content-Digest field exceeds the size limit.
+	ErrBodyTooLarge = errors.New("httpsig: body too large")
+)
+
+// DefaultMaxBodySize is the default limit on the body read to check the
+// Content-Digest field: 10 MiB.
+const DefaultMaxBodySize = 10 << 20
+
+// Algorithm is an HTTP signature algorithm name (RFC 9421, section 3.3).
+type Algorithm string
+
+const (
+	RSAv15SHA256 Algorithm = "rsa-v1_5-sha256"
+	RSAPSSSHA512 Algorithm = "rsa-pss-sha512"
+)
+
+// scheme returns the RSA signature scheme for alg. rsa-pss-sha512 uses a
+// 64 byte salt.
+func (alg Algorithm) scheme() (jsencrypt.Scheme, bool) {
+	switch alg {
+	case RSAv15SHA256:
+		return jsencrypt.SchemePKCS1v15SHA256, true
+	case RSAPSSSHA512:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPSS, Hash: crypto.SHA512}, true
+	}
+	return jsencrypt.Scheme{}, false
+}
+
+const (
+	defaultLabel  = "sig1"
+	defaultMaxAge = 5 * time.Minute
+)
+
+// DefaultComponents are the components covered when Signer.Components is
+// empty.
+var DefaultComponents = []string{"@method", "@authority", "@path"}
+
+// Signer adds Signature-Input and Signature fields to requests.
+type Signer struct {
+	// Key signs requests.
+	Key *jsencrypt.JSEncrypt
+	// Algorithm defaults to rsa-pss-sha512.
+	Algorithm Algorithm
+	// KeyID is the "keyid" parameter. Default: the key's KeyID.
+	KeyID string
+	// Label names the signature in the fields. Default: "sig1".
+	Label string
+	// Components lists the covered components in order: derived components
+	// starting with "@" and lower-case header field names. Default:
+	// DefaultComponents.
+	Components []string
+	// Expires, if set, adds an "expires" parameter this long after
+	// "created".
+	Expires time.Duration
+	// Nonce and Tag, if set, add the "nonce" and "tag" parameters.
+	Nonce string
+	Tag   string
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+// Sign signs req, adding the Signature-Input and Signature fields. If
+// content-digest is covered and req has no Content-Digest field, a SHA-256
+// digest of the body is added first.
+func (s *Signer) Sign(req *http.Request) error {
+	alg := s.Algorithm
+	if alg == "" {
+		alg = RSAPSSSHA512
+	}
+	scheme, ok := alg.scheme()
+	if !ok {
+		return ErrAlgorithm
+	}
+	keyID := s.KeyID
+	if keyID == "" {
+		kid, err := s.Key.KeyID()
+		if err != nil {
+			return err
+		}
+		keyID = kid
+	}
+	label := s.Label
+	if label == "" {
+		label = defaultLabel
+	}
+	components := s.Components
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	items := make([]item, len(components))
+	for i, name := range components {
+		name = strings.ToLower(name)
+		if name == "content-digest" && req.Header.Get("Content-Digest") == "" {
+			if err := SetContentDigest(req); err != nil {
+				return err
+			}
+		}
+		items[i] = item{value: name}
+	}
+	created := now().Unix()
+	params := []param{{"created", created}}
+	if s.Expires > 0 {
+		params = append(params, param{"expires", created + int64(s.Expires/time.Second)})
+	}
+	params = append(params, param{"keyid", keyID}, param{"alg", string(alg)})
+	if s.Nonce != "" {
+		params = append(params, param{"nonce", s.Nonce})
+	}
+	if s.Tag != "" {
+		params = append(params, param{"tag", s.Tag})
+	}
+
+	signatureParams := serializeInnerList(items, params)
+	base, err := signatureBase(req, items, signatureParams)
+	if err != nil {
+		return err
+	}
+	signature, err := s.Key.SignWith([]byte(base), scheme)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Signature-Input", label+"="+signatureParams)
+	req.Header.Add("Signature", label+"="+serializeBareItem(signature))
+	return nil
+}
+
+// KeySet looks up verification keys by key ID. *jsencrypt.Keyring
+// implements it.
+type KeySet interface {
+	Get(kid string) (*jsencrypt.JSEncrypt, bool)
+}
+
+// Verifier checks signatures on incoming requests.
+type Verifier struct {
+	// Keys selects the verification key by the "keyid" parameter.
+	// Signatures without a "keyid" are verified with Key.
+	Keys KeySet
+	// Key verifies signatures when Keys is nil or there is no "keyid".
+	Key *jsencrypt.JSEncrypt
+	// Algorithms lists the accepted algorithms. Default: both. A signature
+	// without an "alg" parameter is tried with each.
+	Algorithms []Algorithm
+	// Label selects the signature to verify. Default: the first one.
+	Label string
+	// RequiredComponents must all be covered by the signature.
+	RequiredComponents []string
+	// MaxAge is how long after "created" a signature is accepted; the
+	// "created" parameter is required. Default: 5 minutes.
+	MaxAge time.Duration
+	// ClockSkew is the leeway allowed for "created" and "expires".
+	ClockSkew time.Duration
+	// MaxBodySize limits the body read when content-digest is covered;
+	// larger bodies fail with ErrBodyTooLarge. Default: DefaultMaxBodySize.
+	MaxBodySize int64
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+// Verify checks a signature on req and returns its "keyid" parameter. If
+// content-digest is covered, the body is read to check it and replaced so
+// the handler can still read it.
+func (v *Verifier) Verify(req *http.Request) (string, error) {
+	inputs, signatures := req.Header.Values("Signature-Input"), req.Header.Values("Signature")
+	if len(inputs) == 0 || len(signatures) == 0 {
+		return "", ErrNoSignature
+	}
+	inputDict, err := parseDictionary(strings.Join(inputs, ", "))
+	if err != nil {
+		return "", ErrMalformed
+	}
+	signatureDict, err := parseDictionary(strings.Join(signatures, ", "))
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	label := v.Label
+	if label == "" && len(inputDict) > 0 {
+		label = inputDict[0].key
+	}
+	input, ok := lookup(inputDict, label)
+	if !ok || !input.isList {
+		return "", ErrNoSignature
+	}
+	sigMember, ok := lookup(signatureDict, label)
+	if !ok || sigMember.isList {
+		return "", ErrNoSignature
+	}
+	signature, ok := sigMember.item.value.([]byte)
+	if !ok {
+		return "", ErrMalformed
+	}
+
+	covered := make([]string, len(input.list))
+	for i, it := range input.list {
+		name, ok := it.value.(string)
+		if !ok || len(it.params) > 0 || name != strings.ToLower(name) {
+			return "", ErrMalformed
+		}
+		covered[i] = name
+	}
+	for _, required := range v.RequiredComponents {
+		if !contains(covered, strings.ToLower(required)) {
+			return "", ErrMissingComponent
+		}
+	}
+
+	var keyID string
+	var alg Algorithm
+	var created, expires int64
+	for _, p := range input.listParams {
+		var ok bool
+		switch p.key {
+		case "keyid":
+			keyID, ok = p.value.(string)
+		case "alg":
+			var s string
+			s, ok = p.value.(string)
+			alg = Algorithm(s)
+		case "created":
+			created, ok = p.value.(int64)
+		case "expires":
+			expires, ok = p.value.(int64)
+		default:
+			ok = true
+		}
+		if !ok {
+			return "", ErrMalformed
+		}
+	}
+	if err := v.checkTime(created, expires); err != nil {
+		return "", err
+	}
+
+	var algorithms []Algorithm
+	if alg != "" {
+		if !v.allowed(alg) {
+			return "", ErrAlgorithm
+		}
+		algorithms = []Algorithm{alg}
+	} else if algorithms = v.Algorithms; len(algorithms) == 0 {
+		algorithms = []Algorithm{RSAv15SHA256, RSAPSSSHA512}
+	}
+	key, err := v.key(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	if contains(covered, "content-digest") {
+		maxBodySize := v.MaxBodySize
+		if maxBodySize <= 0 {
+			maxBodySize = DefaultMaxBodySize
+		}
+		if err := verifyContentDigest(req, maxBodySize); err != nil {
+			return "", err
+		}
+	}
+	base, err := signatureBase(req, input.list, serializeInnerList(input.list, input.listParams))
+	if err != nil {
+		return "", err
+	}
+	for _, alg := range algorithms {
+		scheme, ok := alg.scheme()
+		if !ok {
+			return "", ErrAlgorithm
+		}
+		err := key.VerifyWith([]byte(base), signature, scheme)
+		if err == nil {
+			return keyID, nil
+		}
+		if errors.Is(err, jsencrypt.ErrPolicyViolation) {
+			return "", err
+		}
+	}
+	return "", ErrSignature
+}
+
+func (v *Verifier) checkTime(created, expires int64) error {
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	current := now()
+
+	if created == 0 {
+		return ErrExpired
+	}
+	createdAt := time.Unix(created, 0)
+	if current.Sub(createdAt) > maxAge || createdAt.Sub(current) > v.ClockSkew {
+		return ErrExpired
+	}
+	if expires != 0 && !current.Before(time.Unix(expires, 0).Add(v.ClockSkew)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (v *Verifier) allowed(alg Algorithm) bool {
+	if len(v.Algorithms) == 0 {
+		_, ok := alg.scheme()
+		return ok
+	}
+	return contains(v.Algorithms, alg)
+}
+
+func (v *Verifier) key(keyID string) (*jsencrypt.JSEncrypt, error) {
+	if keyID == "" || v.Keys == nil {
+		if v.Key == nil {
+			return nil, jsencrypt.ErrUnknownKeyID
+		}
+		return v.Key, nil
+	}
+	key, ok := v.Keys.Get(keyID)
+	if !ok {
+		return nil, jsencrypt.ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+func lookup(members []member, key string) (member, bool) {
+	for _, m := range members {
+		if m.key == key {
+			return m, true
+		}
+	}
+	return member{}, false
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
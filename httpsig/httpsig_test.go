@@ -0,0 +1,250 @@
+package httpsig
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+// exampleRequest is the request used throughout RFC 9421.
+func exampleRequest() *http.Request {
+	req := httptest.NewRequest("POST", "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	req.Header.Set("Content-Length", "18")
+	return req
+}
+
+// Signature base from RFC 9421, section 2.5.
+func TestSignatureBase_RFC9421(t *testing.T) {
+	input := `sig1=("@method" "@authority" "@path" "content-digest" "content-length" "content-type");created=1618884473;keyid="test-key-rsa-pss"`
+	members, err := parseDictionary(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := signatureBase(exampleRequest(), members[0].list, serializeInnerList(members[0].list, members[0].listParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"@method": POST
+"@authority": example.com
+"@path": /foo
+"content-digest": sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:
+"content-length": 18
+"content-type": application/json
+"@signature-params": ("@method" "@authority" "@path" "content-digest" "content-length" "content-type");created=1618884473;keyid="test-key-rsa-pss"`
+	if base != want {
+		t.Errorf("Signature base:\n%s\nwant:\n%s", base, want)
+	}
+}
+
+func TestComponentValues(t *testing.T) {
+	req := httptest.NewRequest("GET", "https://Example.COM:443/a%20b?x=1&y", nil)
+	req.Header.Add("X-Multi", " one ")
+	req.Header.Add("X-Multi", "two")
+	tests := map[string]string{
+		"@method":         "GET",
+		"@authority":      "example.com",
+		"@scheme":         "https",
+		"@path":           "/a%20b",
+		"@query":          "?x=1&y",
+		"@request-target": "/a%20b?x=1&y",
+		"@target-uri":     "https://example.com/a%20b?x=1&y",
+		"x-multi":         "one, two",
+	}
+	for name, want := range tests {
+		if got, err := componentValue(req, name); err != nil || got != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if got := req.Header.Values("X-Multi"); got[0] != " one " {
+		t.Errorf("componentValue modified the request header: %q", got)
+	}
+	if _, err := componentValue(req, "x-missing"); !errors.Is(err, ErrMissingComponent) {
+		t.Errorf("Missing header: got %v", err)
+	}
+	if _, err := componentValue(req, "@unknown"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Unknown derived component: got %v", err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	kid, _ := key.KeyID()
+	now := time.Unix(1700000000, 0)
+
+	for _, alg := range []Algorithm{RSAv15SHA256, RSAPSSSHA512} {
+		req := httptest.NewRequest("POST", "https://partner.example/webhooks/order?id=7", strings.NewReader(`{"order":7}`))
+		req.Header.Set("Content-Type", "application/json")
+		signer := &Signer{
+			Key:        key,
+			Algorithm:  alg,
+			Components: []string{"@method", "@authority", "@path", "@query", "Content-Type", "content-digest"},
+			Expires:    time.Minute,
+			Nonce:      "n-1",
+			Now:        func() time.Time { return now },
+		}
+		if err := signer.Sign(req); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if got := req.Header.Get("Content-Digest"); got != "sha-256=:i8us5Khb/WVSZNUGY9EAAJKCTZQh1Ba2hUAqihjqMtM=:" {
+			t.Errorf("%s: Content-Digest = %q", alg, got)
+		}
+		wantInput := `sig1=("@method" "@authority" "@path" "@query" "content-type" "content-digest");created=1700000000;expires=1700000060;keyid="` + kid + `";alg="` + string(alg) + `";nonce="n-1"`
+		if got := req.Header.Get("Signature-Input"); got != wantInput {
+			t.Errorf("%s: Signature-Input = %s", alg, got)
+		}
+
+		verifier := &Verifier{
+			Key:                key,
+			RequiredComponents: []string{"@method", "@path", "content-digest"},
+			Now:                func() time.Time { return now.Add(30 * time.Second) },
+		}
+		gotKID, err := verifier.Verify(req)
+		if err != nil || gotKID != kid {
+			t.Fatalf("%s: Verify = %q, %v", alg, gotKID, err)
+		}
+		if body, _ := io.ReadAll(req.Body); string(body) != `{"order":7}` {
+			t.Errorf("%s: body not preserved after verification: %q", alg, body)
+		}
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	now := time.Unix(1700000000, 0)
+	sign := func(signer Signer, modify func(*http.Request)) *http.Request {
+		req := httptest.NewRequest("POST", "https://partner.example/hook", strings.NewReader("payload"))
+		req.Header.Set("X-Event", "paid")
+		signer.Key = key
+		signer.Now = func() time.Time { return now }
+		if err := signer.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		if modify != nil {
+			modify(req)
+		}
+		return req
+	}
+	full := Signer{Components: []string{"@method", "@path", "x-event", "content-digest"}, Expires: time.Minute}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		verifier Verifier
+		want     error
+	}{
+		{"unsigned", httptest.NewRequest("GET", "/", nil), Verifier{}, ErrNoSignature},
+		{"modified header", sign(full, func(r *http.Request) { r.Header.Set("X-Event", "refunded") }), Verifier{}, ErrSignature},
+		{"modified path", sign(full, func(r *http.Request) { r.URL.Path = "/other" }), Verifier{}, ErrSignature},
+		{"modified body", sign(full, func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader("forged")) }), Verifier{}, ErrDigest},
+		{"body too large", sign(full, nil), Verifier{MaxBodySize: 4}, ErrBodyTooLarge},
+		{"removed header", sign(full, func(r *http.Request) { r.Header.Del("X-Event") }), Verifier{}, ErrMissingComponent},
+		{"required component", sign(Signer{}, nil), Verifier{RequiredComponents: []string{"content-digest"}}, ErrMissingComponent},
+		{"algorithm not allowed", sign(Signer{Algorithm: RSAv15SHA256}, nil), Verifier{Algorithms: []Algorithm{RSAPSSSHA512}}, ErrAlgorithm},
+		{"expired", sign(full, nil), Verifier{Now: func() time.Time { return now.Add(2 * time.Minute) }}, ErrExpired},
+		{"too old", sign(Signer{}, nil), Verifier{MaxAge: time.Minute, Now: func() time.Time { return now.Add(2 * time.Minute) }}, ErrExpired},
+		{"created in future", sign(Signer{}, nil), Verifier{Now: func() time.Time { return now.Add(-time.Minute) }}, ErrExpired},
+		{"unknown label", sign(Signer{}, nil), Verifier{Label: "sig2"}, ErrNoSignature},
+		{"unknown key", sign(Signer{KeyID: "other"}, nil), Verifier{Keys: jsencrypt.NewKeyring()}, jsencrypt.ErrUnknownKeyID},
+		{"malformed input", sign(Signer{}, func(r *http.Request) { r.Header.Set("Signature-Input", `sig1=("@method"`) }), Verifier{}, ErrMalformed},
+		{"component parameters", sign(Signer{}, func(r *http.Request) {
+			r.Header.Set("Signature-Input", `sig1=("@method";req);created=1700000000`)
+		}), Verifier{}, ErrMalformed},
+	}
+	for _, tt := range tests {
+		tt.verifier.Key = key
+		if tt.verifier.Now == nil {
+			tt.verifier.Now = func() time.Time { return now }
+		}
+		if _, err := tt.verifier.Verify(tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerify_MultipleSignatures(t *testing.T) {
+	partner, proxy := testkeys.Key(t, 0), testkeys.Key(t, 1)
+	keyring := jsencrypt.NewKeyring()
+	partnerKID, _ := keyring.Add(partner)
+	proxyKID, _ := keyring.Add(proxy)
+
+	req := httptest.NewRequest("GET", "https://api.example/items", nil)
+	if err := (&Signer{Key: partner}).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Signer{Key: proxy, Label: "proxy", Algorithm: RSAv15SHA256}).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+
+	for label, want := range map[string]string{"": partnerKID, "sig1": partnerKID, "proxy": proxyKID} {
+		kid, err := (&Verifier{Keys: keyring, Label: label}).Verify(req)
+		if err != nil || kid != want {
+			t.Errorf("Label %q: Verify = %q, %v; want %q", label, kid, err, want)
+		}
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	req := exampleRequest()
+	if err := VerifyContentDigest(req); err != nil {
+		t.Errorf("RFC 9421 example digest: %v", err)
+	}
+	req.Header.Set("Content-Digest", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, unixsum=:AAA=:")
+	if err := VerifyContentDigest(req); err != nil {
+		t.Errorf("sha-256 digest with an unknown algorithm: %v", err)
+	}
+	req.Header.Set("Content-Digest", "md5=:AAA=:")
+	if err := VerifyContentDigest(req); !errors.Is(err, ErrDigest) {
+		t.Errorf("Only unsupported digests: got %v, want ErrDigest", err)
+	}
+
+	// An oversized body is rejected without being lost.
+	req = exampleRequest()
+	if err := verifyContentDigest(req, 4); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Oversized body: got %v, want ErrBodyTooLarge", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"hello": "world"}` {
+		t.Errorf("Body after the size check = %q", body)
+	}
+
+	req = exampleRequest()
+	req.Header.Del("Content-Digest")
+	if err := SetContentDigest(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Content-Digest"); got != "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:" {
+		t.Errorf("Content-Digest = %s", got)
+	}
+}
+
+func TestParseDictionary(t *testing.T) {
+	members, err := parseDictionary(`a=("x" "y";p=1);n=-5;t=tok/en;b=?0, b=:AQI=:,  c;flag`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 || members[0].key != "a" || members[1].key != "b" || members[2].key != "c" {
+		t.Fatalf("Unexpected members %+v", members)
+	}
+	a := members[0]
+	if got := serializeInnerList(a.list, a.listParams); got != `("x" "y";p=1);n=-5;t=tok/en;b=?0` {
+		t.Errorf("Round trip = %s", got)
+	}
+	if b, ok := members[1].item.value.([]byte); !ok || len(b) != 2 {
+		t.Errorf("Duplicate key should keep the last value, got %+v", members[1])
+	}
+
+	for _, bad := range []string{`A=1`, `a=`, `a=1,`, `a=("x"`, `a="unterminated`, `a=:!!:`, `a=1 b=2`, `a=12345678901234567`} {
+		if _, err := parseDictionary(bad); err == nil {
+			t.Errorf("parseDictionary(%q) should fail", bad)
+		}
+	}
+}
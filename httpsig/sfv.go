@@ -0,0 +1,293 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of Structured Field Values (RFC 8941)
+// used by the Signature-Input and Signature fields: dictionaries whose
+// members are inner lists or byte sequences, with integer, string, token,
+// boolean and byte sequence parameters.
+
+// token is an unquoted Structured Field token.
+type token string
+
+type param struct {
+	key   string
+	value any // int64, string, token, bool or []byte
+}
+
+type item struct {
+	value  any
+	params []param
+}
+
+type member struct {
+	key        string
+	item       item   // a bare item, unless isList
+	isList     bool   // the member is an inner list
+	list       []item // the items of an inner list
+	listParams []param
+}
+
+var errSyntax = errors.New("httpsig: invalid structured field")
+
+type sfParser struct {
+	s string
+	i int
+}
+
+// parseDictionary parses a Structured Field dictionary. A key that appears
+// more than once keeps its last value, in its first position.
+func parseDictionary(s string) ([]member, error) {
+	p := &sfParser{s: s}
+	p.skipSP()
+	var members []member
+	index := map[string]int{}
+	for p.i < len(p.s) {
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		m := member{key: key}
+		if p.peek() == '=' {
+			p.i++
+			if p.peek() == '(' {
+				m.isList = true
+				if m.list, err = p.innerList(); err != nil {
+					return nil, err
+				}
+				if m.listParams, err = p.params(); err != nil {
+					return nil, err
+				}
+			} else if m.item, err = p.item(); err != nil {
+				return nil, err
+			}
+		} else {
+			m.item.value = true
+			if m.item.params, err = p.params(); err != nil {
+				return nil, err
+			}
+		}
+		if i, ok := index[key]; ok {
+			members[i] = m
+		} else {
+			index[key] = len(members)
+			members = append(members, m)
+		}
+
+		p.skipOWS()
+		if p.i == len(p.s) {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, errSyntax
+		}
+		p.i++
+		p.skipOWS()
+		if p.i == len(p.s) {
+			return nil, errSyntax
+		}
+	}
+	return members, nil
+}
+
+func (p *sfParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *sfParser) skipSP() {
+	for p.peek() == ' ' {
+		p.i++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.i++
+	}
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z' || c == '*') {
+		return "", errSyntax
+	}
+	for c := p.peek(); c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || c == '*'; c = p.peek() {
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) innerList() ([]item, error) {
+	p.i++ // '('
+	var items []item
+	for {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.i++
+			return items, nil
+		}
+		it, err := p.item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, errSyntax
+		}
+	}
+}
+
+func (p *sfParser) item() (item, error) {
+	value, err := p.bareItem()
+	if err != nil {
+		return item{}, err
+	}
+	params, err := p.params()
+	return item{value: value, params: params}, err
+}
+
+func (p *sfParser) params() ([]param, error) {
+	var params []param
+	for p.peek() == ';' {
+		p.i++
+		p.skipSP()
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.peek() == '=' {
+			p.i++
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, param{key: key, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) bareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.i
+		p.i++
+		for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+			p.i++
+		}
+		if p.i-start > 16 {
+			return nil, errSyntax
+		}
+		n, err := strconv.ParseInt(p.s[start:p.i], 10, 64)
+		if err != nil {
+			return nil, errSyntax
+		}
+		return n, nil
+	case c == '"':
+		return p.string()
+	case c == ':':
+		end := strings.IndexByte(p.s[p.i+1:], ':')
+		if end < 0 {
+			return nil, errSyntax
+		}
+		b, err := base64.StdEncoding.DecodeString(p.s[p.i+1 : p.i+1+end])
+		if err != nil {
+			return nil, errSyntax
+		}
+		p.i += end + 2
+		return b, nil
+	case c == '?':
+		if p.i+1 < len(p.s) && (p.s[p.i+1] == '0' || p.s[p.i+1] == '1') {
+			p.i += 2
+			return p.s[p.i-1] == '1', nil
+		}
+		return nil, errSyntax
+	case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '*':
+		start := p.i
+		for c := p.peek(); c > ' ' && c < 0x7f && strings.IndexByte(`"(),;<=>?@[\]{}`, c) < 0; c = p.peek() {
+			p.i++
+		}
+		return token(p.s[start:p.i]), nil
+	}
+	return nil, errSyntax
+}
+
+func (p *sfParser) string() (string, error) {
+	var b strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		c := p.s[p.i]
+		switch {
+		case c == '\\':
+			p.i++
+			if p.i == len(p.s) || (p.s[p.i] != '"' && p.s[p.i] != '\\') {
+				return "", errSyntax
+			}
+			b.WriteByte(p.s[p.i])
+		case c == '"':
+			p.i++
+			return b.String(), nil
+		case c < ' ' || c > '~':
+			return "", errSyntax
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errSyntax
+}
+
+// serializeInnerList writes an inner list with parameters.
+func serializeInnerList(items []item, params []param) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, it := range items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(serializeBareItem(it.value))
+		b.WriteString(serializeParams(it.params))
+	}
+	b.WriteByte(')')
+	b.WriteString(serializeParams(params))
+	return b.String()
+}
+
+func serializeParams(params []param) string {
+	var b strings.Builder
+	for _, p := range params {
+		b.WriteByte(';')
+		b.WriteString(p.key)
+		if v, ok := p.value.(bool); !ok || !v {
+			b.WriteByte('=')
+			b.WriteString(serializeBareItem(p.value))
+		}
+	}
+	return b.String()
+}
+
+func serializeBareItem(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case token:
+		return string(v)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+	return ""
+}
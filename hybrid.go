@@ -0,0 +1,114 @@
+package jsencrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// The hybrid scheme used by SealFor and the multi-recipient envelope: the
+// payload is encrypted with a fresh AES-256-GCM content key, which is
+// wrapped for each recipient with RSA-OAEP-SHA256. OAEP and GCM are both
+// allowed by StrictPolicy, and the payload size is not limited by the RSA
+// modulus.
+
+const hybridKeySize = 32
+
+// hybridKeyWrap is the RSA scheme used to wrap content keys.
+var hybridKeyWrap = SchemeOAEPSHA256
+
+// ErrKeyIDMismatch is returned when a token or envelope is not addressed to
+// the key trying to open it, or was not signed by the expected sender.
+var ErrKeyIDMismatch = errors.New("key id does not match")
+
+// b64url encodes the segments of compact tokens.
+var b64url = base64.RawURLEncoding
+
+// sealContent encrypts plaintext under a fresh content key. aad is
+// authenticated but not encrypted.
+func sealContent(plaintext, aad []byte) (key, nonce, ciphertext []byte, err error) {
+	key = make([]byte, hybridKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := NewContentCipher(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, nil, err
+	}
+	return key, nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+// openContent reverses sealContent. Every failure is ErrDecryption.
+func openContent(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := NewContentCipher(key)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plaintext, nil
+}
+
+// NewContentCipher returns the AES-256-GCM cipher of the hybrid scheme for
+// a 32-byte content key. Packages building their own formats on the scheme,
+// such as secrets, use it with WrapContentKey and UnwrapContentKey.
+func NewContentCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != hybridKeySize {
+		return nil, errors.New("content key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// WrapContentKey encrypts a content key for j with RSA-OAEP-SHA256.
+func (j *JSEncrypt) WrapContentKey(key []byte) ([]byte, error) {
+	return j.EncryptWith(key, hybridKeyWrap)
+}
+
+// UnwrapContentKey decrypts a content key wrapped for j. A key that is not
+// 32 bytes long is reported as ErrDecryption.
+func (j *JSEncrypt) UnwrapContentKey(wrapped []byte) ([]byte, error) {
+	key, err := j.DecryptWith(wrapped, hybridKeyWrap)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != hybridKeySize {
+		return nil, ErrDecryption
+	}
+	return key, nil
+}
+
+// splitCompact splits a compact token into exactly n dot separated
+// segments, returning ErrDecryption if it has a different number.
+func splitCompact(token string, n int) ([]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != n {
+		return nil, ErrDecryption
+	}
+	return parts, nil
+}
+
+// decodeSegments base64url decodes every segment.
+func decodeSegments(parts []string) ([][]byte, error) {
+	out := make([][]byte, len(parts))
+	for i, part := range parts {
+		decoded, err := b64url.DecodeString(part)
+		if err != nil {
+			return nil, ErrDecryption
+		}
+		out[i] = decoded
+	}
+	return out, nil
+}
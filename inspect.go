@@ -0,0 +1,139 @@
+package jsencrypt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyMismatch is returned by Validate when the public key set with
+// SetPublicKey does not belong to the private key set with SetPrivateKey.
+var ErrKeyMismatch = errors.New("public key does not match private key")
+
+// KeyReport describes the key material held by a JSEncrypt instance.
+type KeyReport struct {
+	HasPrivateKey  bool
+	HasPublicKey   bool
+	Bits           int // modulus size in bits
+	PublicExponent int
+	// Consistent reports whether the private key passes
+	// rsa.PrivateKey.Validate and matches the public key. It is always true
+	// when only a public key is set.
+	Consistent bool
+	// HasCRT reports whether the CRT values (dP, dQ, qInv) are present.
+	HasCRT bool
+	// Primes is the number of prime factors of the modulus; more than two
+	// means a multi-prime key. Zero when no private key is set.
+	Primes int
+	// Warnings lists policy concerns, such as small moduli or exponents.
+	Warnings []string
+}
+
+// Inspect returns a report on the current key without generating one.
+func (j *JSEncrypt) Inspect() (*KeyReport, error) {
+	keys, release, err := j.useKeys()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	pub := keys.public()
+	if pub == nil {
+		return nil, ErrNoKey
+	}
+
+	report := &KeyReport{
+		HasPrivateKey:  keys.privateKey != nil,
+		HasPublicKey:   true,
+		Bits:           pub.N.BitLen(),
+		PublicExponent: pub.E,
+		Consistent:     true,
+	}
+
+	if report.Bits < 2048 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("modulus is %d bits; at least 2048 bits is recommended", report.Bits))
+	}
+	if pub.E == 3 {
+		report.Warnings = append(report.Warnings, "public exponent 3 is vulnerable to attacks on unpadded or poorly padded messages")
+	} else if pub.E < 65537 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("public exponent %d is smaller than the customary 65537", pub.E))
+	}
+
+	if priv := keys.privateKey; priv != nil {
+		report.Primes = len(priv.Primes)
+		report.HasCRT = priv.Precomputed.Dp != nil && priv.Precomputed.Dq != nil && priv.Precomputed.Qinv != nil
+
+		if err := priv.Validate(); err != nil {
+			report.Consistent = false
+			report.Warnings = append(report.Warnings, "private key is invalid: "+err.Error())
+		}
+		if !publicKeysEqual(pub, &priv.PublicKey) {
+			report.Consistent = false
+			report.Warnings = append(report.Warnings, "public key set with SetPublicKey does not match the private key")
+		}
+		if report.Primes > 2 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("multi-prime key with %d primes is not supported by all implementations", report.Primes))
+		}
+		if !report.HasCRT {
+			report.Warnings = append(report.Warnings, "CRT values are missing; private key operations will be slow")
+		}
+	}
+
+	return report, nil
+}
+
+// Validate checks that the private key is mathematically valid and matches
+// the public key, and precomputes CRT values when they are missing. When only
+// a public key is set, it checks that the modulus and exponent are usable.
+func (j *JSEncrypt) Validate() error {
+	keys, release, err := j.useKeys()
+	if err != nil {
+		return err
+	}
+	defer release()
+	if keys.privateKey == nil && keys.publicKey == nil {
+		return ErrNoKey
+	}
+
+	if priv := keys.privateKey; priv != nil {
+		if err := priv.Validate(); err != nil {
+			return err
+		}
+		if keys.publicKey != nil && !publicKeysEqual(keys.publicKey, &priv.PublicKey) {
+			return ErrKeyMismatch
+		}
+		if priv.Precomputed.Dp == nil {
+			j.precompute(priv)
+		}
+		return nil
+	}
+
+	if keys.publicKey.N == nil || keys.publicKey.N.Sign() <= 0 {
+		return errors.New("invalid modulus")
+	}
+	if keys.publicKey.E < 3 || keys.publicKey.E&1 == 0 {
+		return errors.New("invalid public exponent")
+	}
+	return nil
+}
+
+func publicKeysEqual(a, b *rsa.PublicKey) bool {
+	return a.E == b.E && a.N.Cmp(b.N) == 0
+}
+
+// precompute installs a copy of priv with CRT values. Other goroutines may
+// be using priv from an earlier snapshot, so it is never modified in place.
+// The copy is only installed if priv is still the current key.
+func (j *JSEncrypt) precompute(priv *rsa.PrivateKey) {
+	precomputed := *priv
+	precomputed.Precompute()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed || j.privateKey != priv {
+		return
+	}
+	j.privateKey = &precomputed
+	if j.publicKey == &priv.PublicKey {
+		j.publicKey = &precomputed.PublicKey
+	}
+}
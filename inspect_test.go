@@ -0,0 +1,162 @@
+package jsencrypt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSEncrypt_Inspect(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	if _, err := jsCrypt.Inspect(); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Expected ErrNoKey for an empty instance, got %v", err)
+	}
+
+	if err := jsCrypt.SetPrivateKey(exampleTestKeys.privateKey); err != nil {
+		t.Fatal(err)
+	}
+	report, err := jsCrypt.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.HasPrivateKey || !report.HasPublicKey {
+		t.Error("Report should show both private and public key")
+	}
+	if report.Bits != 2048 || report.PublicExponent != 65537 {
+		t.Errorf("Unexpected key parameters: %d bits, e=%d", report.Bits, report.PublicExponent)
+	}
+	if !report.Consistent || !report.HasCRT || report.Primes != 2 {
+		t.Errorf("Unexpected report for a valid key: %+v", report)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", report.Warnings)
+	}
+	if err := jsCrypt.Validate(); err != nil {
+		t.Errorf("Validate failed for a valid key: %v", err)
+	}
+}
+
+func TestJSEncrypt_InspectMismatchedKeys(t *testing.T) {
+	other := NewJSEncrypt()
+	otherPub, err := other.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPrivateKey(exampleTestKeys.privateKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsCrypt.SetPublicKey(otherPub); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := jsCrypt.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Consistent {
+		t.Error("Mismatched public and private keys should not be reported as consistent")
+	}
+	if !hasWarning(report, "does not match") {
+		t.Errorf("Expected mismatch warning, got %v", report.Warnings)
+	}
+	// Bits describe the public key that Encrypt would use (1024-bit default).
+	if !hasWarning(report, "1024 bits") {
+		t.Errorf("Expected small modulus warning, got %v", report.Warnings)
+	}
+	if err := jsCrypt.Validate(); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+}
+
+func TestJSEncrypt_InspectWeakParameters(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	jsCrypt.publicKey = &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), 2047), E: 3}
+	report, err := jsCrypt.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(report, "exponent 3") {
+		t.Errorf("Expected exponent 3 warning, got %v", report.Warnings)
+	}
+
+	multi, err := rsa.GenerateMultiPrimeKey(rand.Reader, 3, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi.Precomputed = rsa.PrecomputedValues{}
+	jsCrypt = NewJSEncrypt()
+	jsCrypt.privateKey = multi
+	report, err = jsCrypt.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Primes != 3 || report.HasCRT {
+		t.Errorf("Unexpected report for multi-prime key without CRT values: %+v", report)
+	}
+	if !hasWarning(report, "multi-prime") || !hasWarning(report, "CRT") {
+		t.Errorf("Expected multi-prime and CRT warnings, got %v", report.Warnings)
+	}
+
+	// Validate fills in the missing CRT values.
+	if err := jsCrypt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ = jsCrypt.Inspect(); !report.HasCRT {
+		t.Error("Validate should have precomputed CRT values")
+	}
+}
+
+func TestJSEncrypt_ValidateConcurrent(t *testing.T) {
+	// Validate must not precompute CRT values on a key that concurrent
+	// operations are using. Run with -race.
+	priv := *newTestKey(t, testPrivateKeys[3]).privateKey
+	priv.Precomputed = rsa.PrecomputedValues{}
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.setPrivateKey(&priv); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := jsCrypt.Encrypt("concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := jsCrypt.Validate(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if plaintext, err := jsCrypt.Decrypt(ciphertext); err != nil || plaintext != "concurrent" {
+				t.Errorf("Decrypt = %q, %v", plaintext, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if priv.Precomputed.Dp != nil {
+		t.Error("Validate modified the shared key")
+	}
+	if report, _ := jsCrypt.Inspect(); !report.HasCRT {
+		t.Error("Validate should have installed a key with CRT values")
+	}
+}
+
+func hasWarning(report *KeyReport, substr string) bool {
+	for _, w := range report.Warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
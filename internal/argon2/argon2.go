@@ -0,0 +1,260 @@
+// Package argon2 implements the Argon2 memory-hard key derivation function
+// (RFC 9106, version 0x13) for reading passphrase protected key files.
+//
+// It is a compact, single-threaded implementation intended for the small
+// parameter sets used by key file formats, not for password hashing servers.
+package argon2
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Mode selects the Argon2 variant.
+type Mode uint32
+
+const (
+	Argon2d  Mode = 0
+	Argon2i  Mode = 1
+	Argon2id Mode = 2
+)
+
+const (
+	version    = 0x13
+	syncPoints = 4
+	blockWords = 128 // 1 KiB blocks of 64-bit words
+)
+
+type block [blockWords]uint64
+
+// Key derives a keyLen byte key from password and salt. memory is given in
+// KiB and threads is the degree of parallelism (number of lanes).
+func Key(mode Mode, password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	return deriveKey(mode, password, salt, nil, nil, time, memory, uint32(threads), keyLen)
+}
+
+func deriveKey(mode Mode, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) []byte {
+	if time < 1 {
+		panic("argon2: number of rounds too small")
+	}
+	if threads < 1 {
+		panic("argon2: parallelism degree too low")
+	}
+
+	h0 := initHash(mode, password, salt, secret, data, time, memory, threads, keyLen)
+
+	if memory < 2*syncPoints*threads {
+		memory = 2 * syncPoints * threads
+	}
+	memory = memory / (syncPoints * threads) * (syncPoints * threads)
+
+	b := initBlocks(&h0, memory, threads)
+	processBlocks(b, mode, time, memory, threads)
+	return extractKey(b, memory, threads, keyLen)
+}
+
+func initHash(mode Mode, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) [blake2bSize + 8]byte {
+	var params [24]byte
+	binary.LittleEndian.PutUint32(params[0:], threads)
+	binary.LittleEndian.PutUint32(params[4:], keyLen)
+	binary.LittleEndian.PutUint32(params[8:], memory)
+	binary.LittleEndian.PutUint32(params[12:], time)
+	binary.LittleEndian.PutUint32(params[16:], version)
+	binary.LittleEndian.PutUint32(params[20:], uint32(mode))
+
+	d := newBlake2b(blake2bSize)
+	d.Write(params[:])
+	for _, v := range [][]byte{password, salt, secret, data} {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(v)))
+		d.Write(n[:])
+		d.Write(v)
+	}
+
+	var h0 [blake2bSize + 8]byte
+	d.Sum(h0[:0])
+	return h0
+}
+
+func initBlocks(h0 *[blake2bSize + 8]byte, memory, threads uint32) []block {
+	var buf [blockWords * 8]byte
+	b := make([]block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2bSize+4:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2bSize:], i)
+			blake2bLong(buf[:], h0[:])
+			for k := range b[j+i] {
+				b[j+i][k] = binary.LittleEndian.Uint64(buf[8*k:])
+			}
+		}
+	}
+	return b
+}
+
+func processBlocks(b []block, mode Mode, time, memory, threads uint32) {
+	laneLen := memory / threads
+	segLen := laneLen / syncPoints
+
+	processSegment := func(pass, slice, lane uint32) {
+		var addresses, in, zero block
+		dataIndependent := mode == Argon2i || (mode == Argon2id && pass == 0 && slice < syncPoints/2)
+		if dataIndependent {
+			in[0] = uint64(pass)
+			in[1] = uint64(lane)
+			in[2] = uint64(slice)
+			in[3] = uint64(memory)
+			in[4] = uint64(time)
+			in[5] = uint64(mode)
+		}
+
+		index := uint32(0)
+		if pass == 0 && slice == 0 {
+			index = 2 // the first two blocks of each lane are already set
+			if dataIndependent {
+				in[6]++
+				processBlock(&addresses, &in, &zero)
+				processBlock(&addresses, &addresses, &zero)
+			}
+		}
+
+		offset := lane*laneLen + slice*segLen + index
+		for index < segLen {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += laneLen // wrap to the last block of the lane
+			}
+
+			var random uint64
+			if dataIndependent {
+				if index%blockWords == 0 {
+					in[6]++
+					processBlock(&addresses, &in, &zero)
+					processBlock(&addresses, &addresses, &zero)
+				}
+				random = addresses[index%blockWords]
+			} else {
+				random = b[prev][0]
+			}
+
+			ref := indexAlpha(random, laneLen, segLen, threads, pass, slice, lane, index)
+			processBlockXOR(&b[offset], &b[prev], &b[ref])
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for pass := uint32(0); pass < time; pass++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			for lane := uint32(0); lane < threads; lane++ {
+				processSegment(pass, slice, lane)
+			}
+		}
+	}
+}
+
+func extractKey(b []block, memory, threads, keyLen uint32) []byte {
+	laneLen := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range b[(lane*laneLen)+laneLen-1] {
+			b[memory-1][i] ^= v
+		}
+	}
+
+	var buf [blockWords * 8]byte
+	for i, v := range b[memory-1] {
+		binary.LittleEndian.PutUint64(buf[8*i:], v)
+	}
+	key := make([]byte, keyLen)
+	blake2bLong(key, buf[:])
+	return key
+}
+
+// indexAlpha maps a pseudo-random value to the reference block index as
+// described in RFC 9106, section 3.4.
+func indexAlpha(random uint64, laneLen, segLen, threads, pass, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if pass == 0 && slice == 0 {
+		refLane = lane
+	}
+
+	m, s := 3*segLen, ((slice+1)%syncPoints)*segLen
+	if lane == refLane {
+		m += index
+	}
+	if pass == 0 {
+		m, s = slice*segLen, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+
+	p := random & 0xffffffff
+	p = (p * p) >> 32
+	p = (p * uint64(m)) >> 32
+	return refLane*laneLen + uint32((uint64(s)+uint64(m)-(p+1))%uint64(laneLen))
+}
+
+func processBlock(out, in1, in2 *block) {
+	processBlockGeneric(out, in1, in2, false)
+}
+
+func processBlockXOR(out, in1, in2 *block) {
+	processBlockGeneric(out, in1, in2, true)
+}
+
+// processBlockGeneric is the compression function G applied to two blocks.
+func processBlockGeneric(out, in1, in2 *block, xor bool) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	for i := 0; i < blockWords; i += 16 {
+		blamka(&t[i+0], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < blockWords/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	if xor {
+		for i := range t {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		}
+	} else {
+		for i := range t {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+// blamka is the BLAKE2b round function with the multiplication hardening
+// used by Argon2.
+func blamka(t00, t01, t02, t03, t04, t05, t06, t07, t08, t09, t10, t11, t12, t13, t14, t15 *uint64) {
+	v := [16]uint64{*t00, *t01, *t02, *t03, *t04, *t05, *t06, *t07, *t08, *t09, *t10, *t11, *t12, *t13, *t14, *t15}
+
+	gb := func(a, b, c, d int) {
+		v[a] += v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+		v[d] = bits.RotateLeft64(v[d]^v[a], -32)
+		v[c] += v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+		v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+		v[a] += v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+		v[d] = bits.RotateLeft64(v[d]^v[a], -16)
+		v[c] += v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+		v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+	}
+	gb(0, 4, 8, 12)
+	gb(1, 5, 9, 13)
+	gb(2, 6, 10, 14)
+	gb(3, 7, 11, 15)
+	gb(0, 5, 10, 15)
+	gb(1, 6, 11, 12)
+	gb(2, 7, 8, 13)
+	gb(3, 4, 9, 14)
+
+	*t00, *t01, *t02, *t03, *t04, *t05, *t06, *t07 = v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]
+	*t08, *t09, *t10, *t11, *t12, *t13, *t14, *t15 = v[8], v[9], v[10], v[11], v[12], v[13], v[14], v[15]
+}
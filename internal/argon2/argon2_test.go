@@ -0,0 +1,41 @@
+package argon2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 9106, section 5.
+func TestRFC9106Vectors(t *testing.T) {
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+
+	tests := []struct {
+		mode Mode
+		tag  string
+	}{
+		{Argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{Argon2i, "c814d9d1dc7f37aa13f0d77f2494bda1c8de6b016dd388d29952a4c4672b6ce8"},
+		{Argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	for _, tc := range tests {
+		got := deriveKey(tc.mode, password, salt, secret, data, 3, 32, 4, 32)
+		if hex.EncodeToString(got) != tc.tag {
+			t.Errorf("mode %d: got %x, want %s", tc.mode, got, tc.tag)
+		}
+	}
+}
+
+// Test vector from RFC 7693, Appendix A.
+func TestBlake2b(t *testing.T) {
+	d := newBlake2b(blake2bSize)
+	d.Write([]byte("abc"))
+	want := "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1" +
+		"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"
+	if got := hex.EncodeToString(d.Sum(nil)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,24 +1,67 @@
 package jsencrypt
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"strings"
+	"sync"
 )
 
 // JSEncrypt is a Go implementation of the JSEncrypt library.
 type JSEncrypt struct {
-	privateKey       *rsa.PrivateKey
-	publicKey        *rsa.PublicKey
+	// mu guards keyState and closed so keys can be replaced, e.g. by a
+	// Watcher, while other goroutines use the instance.
+	mu sync.RWMutex
+	keyState
+	closed bool
+	// inFlight counts operations using the private key; Close waits for
+	// them before wiping it.
+	inFlight sync.WaitGroup
+
 	DefaultKeySize   int
 	DefaultPublicExp string // Not used in Go's rsa.GenerateKey (fixed to 65537 usually), kept for API compatibility
 	Log              bool
+	// Policy, if set, restricts the keys and algorithms used by every
+	// operation. Generated keys are at least Policy.MinEncryptSignBits long.
+	Policy *Policy
+	// ImplicitRejection makes Decrypt and PKCS#1 v1.5 DecryptWith return a
+	// deterministic pseudo-random plaintext instead of an error when the
+	// padding is invalid, so they cannot be used as a padding oracle.
+	// It requires an in-memory private key.
+	ImplicitRejection bool
+}
+
+// keyState is the key material of a JSEncrypt. It is replaced as a whole so
+// that readers never observe a half updated key.
+type keyState struct {
+	privateKey   *rsa.PrivateKey
+	publicKey    *rsa.PublicKey
+	certificates []*x509.Certificate
+	signer       crypto.Signer
+	decrypter    crypto.Decrypter
+}
+
+// public returns the public key, falling back to the public half of the
+// private key. It returns nil when no key is set.
+func (k keyState) public() *rsa.PublicKey {
+	if k.publicKey != nil {
+		return k.publicKey
+	}
+	if k.privateKey != nil {
+		return &k.privateKey.PublicKey
+	}
+	return nil
+}
+
+// canDecrypt reports whether a private key or external decrypter is set.
+func (k keyState) canDecrypt() bool {
+	return k.privateKey != nil || k.decrypter != nil
 }
 
 // NewJSEncrypt creates a new JSEncrypt instance.
@@ -34,7 +77,15 @@ func (j *JSEncrypt) SetKey(keyStr string) error {
 	// Simple cleanup to handle some formatting issues if any
 	keyStr = strings.TrimSpace(keyStr)
 
-	block, _ := pem.Decode([]byte(keyStr))
+	data := []byte(keyStr)
+	defer wipeBytes(data)
+	return j.setKeyPEM(data)
+}
+
+// setKeyPEM sets the key from the first PEM block in data. The decoded DER
+// is wiped once parsed.
+func (j *JSEncrypt) setKeyPEM(data []byte) error {
+	block, _ := pem.Decode(data)
 	if block == nil {
 		// Try to handle keys without headers or just base64
 		// But for now, let's assume valid PEM or at least try to Wrap it if it looks like base64?
@@ -42,35 +93,42 @@ func (j *JSEncrypt) SetKey(keyStr string) error {
 		// If fails, we return error.
 		return errors.New("failed to parse PEM block")
 	}
+	defer wipeBytes(block.Bytes)
+	return j.setKeyDER(block.Bytes)
+}
 
+// setKeyData sets the key from PEM, or from DER when data holds no PEM block.
+func (j *JSEncrypt) setKeyData(data []byte) error {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		return j.setKeyPEM(data)
+	}
+	return j.setKeyDER(data)
+}
+
+// setKeyDER sets the key from DER, trying the private key formats first.
+func (j *JSEncrypt) setKeyDER(der []byte) error {
 	// 1. Try PKCS#1 Private Key
-	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
-		j.privateKey = priv
-		j.publicKey = &priv.PublicKey
-		return nil
+	if priv, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return j.setPrivateKey(priv)
 	}
 
 	// 2. Try PKCS#8 Private Key
-	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
 		if priv, ok := key.(*rsa.PrivateKey); ok {
-			j.privateKey = priv
-			j.publicKey = &priv.PublicKey
-			return nil
+			return j.setPrivateKey(priv)
 		}
 	}
 
 	// 3. Try PKIX Public Key
-	if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
 		if rsaPub, ok := pub.(*rsa.PublicKey); ok {
-			j.publicKey = rsaPub
-			return nil
+			return j.setPublicKey(rsaPub)
 		}
 	}
 
 	// 4. Try PKCS#1 Public Key
-	if pub, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
-		j.publicKey = pub
-		return nil
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return j.setPublicKey(pub)
 	}
 
 	return errors.New("failed to parse key")
@@ -86,34 +144,113 @@ func (j *JSEncrypt) SetPublicKey(pubKeyStr string) error {
 	return j.SetKey(pubKeyStr)
 }
 
-// getKey ensures a key exists, generating one if necessary.
-func (j *JSEncrypt) getKey() (*rsa.PrivateKey, error) {
-	if j.privateKey != nil {
-		return j.privateKey, nil
+// keys returns a consistent snapshot of the key material. It is empty once
+// the instance is closed.
+func (j *JSEncrypt) keys() keyState {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.keyState
+}
+
+// openKeys is like keys but fails with ErrClosed after Close.
+func (j *JSEncrypt) openKeys() (keyState, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.closed {
+		return keyState{}, ErrClosed
 	}
-	// Generate key
-	priv, err := rsa.GenerateKey(rand.Reader, j.DefaultKeySize)
-	if err != nil {
-		return nil, err
+	return j.keyState, nil
+}
+
+// useKeys is like openKeys for operations that read the private key. Close
+// waits for release to be called before it wipes the key.
+func (j *JSEncrypt) useKeys() (keys keyState, release func(), err error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.closed {
+		return keyState{}, nil, ErrClosed
 	}
-	j.privateKey = priv
-	j.publicKey = &priv.PublicKey
-	return priv, nil
+	j.inFlight.Add(1)
+	return j.keyState, j.inFlight.Done, nil
 }
 
-// Encrypt encrypts a string using the public key. Returns base64 encoded string.
-func (j *JSEncrypt) Encrypt(str string) (string, error) {
-	if j.publicKey == nil {
-		// Attempt to generate or ensure key?
-		// The TS library generates a key on getKey(), but Encrypt uses public components.
-		// If we don't have a public key, we might have a private key which contains it.
-		// If we have neither, the TS library generates a new pair.
-		if _, err := j.getKey(); err != nil {
-			return "", err
+// setKeys replaces all key material at once.
+func (j *JSEncrypt) setKeys(keys keyState) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+	j.keyState = keys
+	return nil
+}
+
+// setPrivateKey installs an in-memory key pair, replacing any external
+// signer, decrypter and certificates.
+func (j *JSEncrypt) setPrivateKey(priv *rsa.PrivateKey) error {
+	return j.setKeys(keyState{privateKey: priv, publicKey: &priv.PublicKey})
+}
+
+// setPublicKey sets the public key, keeping any private key already set.
+func (j *JSEncrypt) setPublicKey(pub *rsa.PublicKey) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+	j.publicKey = pub
+	return nil
+}
+
+// getKey ensures a key exists, generating one if necessary. It fails with
+// ErrExternalKey instead of generating a key when the private key is held by
+// an external signer or decrypter. As with useKeys, release must be called
+// once the key is no longer used.
+func (j *JSEncrypt) getKey() (priv *rsa.PrivateKey, release func(), err error) {
+	keys, release, err := j.useKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	if keys.privateKey != nil {
+		return keys.privateKey, release, nil
+	}
+	release()
+	if keys.signer != nil || keys.decrypter != nil {
+		return nil, nil, ErrExternalKey
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Re-check: another goroutine may have set or generated a key, or
+	// closed the instance.
+	if j.closed {
+		return nil, nil, ErrClosed
+	}
+	if j.privateKey == nil {
+		if j.signer != nil || j.decrypter != nil {
+			return nil, nil, ErrExternalKey
+		}
+		// Generate key
+		bits := j.DefaultKeySize
+		if minBits := j.Policy.minKeyBits(); bits < minBits {
+			bits = minBits
 		}
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		j.keyState = keyState{privateKey: priv, publicKey: &priv.PublicKey}
 	}
+	j.inFlight.Add(1)
+	return j.privateKey, j.inFlight.Done, nil
+}
 
-	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, j.publicKey, []byte(str))
+// Encrypt encrypts a string using the public key. Returns base64 encoded string.
+func (j *JSEncrypt) Encrypt(str string) (string, error) {
+	// The TS library generates a key on getKey(), but Encrypt uses public components.
+	// If we don't have a public key, we might have a private key which contains it.
+	// If we have neither, the TS library generates a new pair.
+	encrypted, err := j.EncryptWith([]byte(str), SchemePKCS1v15)
 	if err != nil {
 		return "", err
 	}
@@ -122,22 +259,14 @@ func (j *JSEncrypt) Encrypt(str string) (string, error) {
 
 // Decrypt decrypts a base64 encoded string using the private key.
 func (j *JSEncrypt) Decrypt(str string) (string, error) {
-	if j.privateKey == nil {
-		// If no key, generate one (though decrypting with a new key won't work for existing data,
-		// but for API consistency we generally check if key is set).
-		// However, decrypting random stuff with a new key makes no sense.
-		// But TS implementation does `this.getKey().decrypt(...)`.
-		if _, err := j.getKey(); err != nil {
-			return "", err
-		}
-	}
-
 	decoded, err := base64.StdEncoding.DecodeString(str)
 	if err != nil {
-		return "", err
+		return "", ErrDecryption
 	}
 
-	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, j.privateKey, decoded)
+	// If no key, one is generated (though decrypting with a new key won't work for existing data),
+	// because the TS implementation does `this.getKey().decrypt(...)`.
+	decrypted, err := j.DecryptWith(decoded, SchemePKCS1v15)
 	if err != nil {
 		return "", err
 	}
@@ -147,14 +276,7 @@ func (j *JSEncrypt) Decrypt(str string) (string, error) {
 // Sign signs a string using SHA256 and returns base64 encoded signature.
 // This matches SignSha256 in TS.
 func (j *JSEncrypt) Sign(str string) (string, error) {
-	if j.privateKey == nil {
-		if _, err := j.getKey(); err != nil {
-			return "", err
-		}
-	}
-
-	hashed := sha256.Sum256([]byte(str))
-	signature, err := rsa.SignPKCS1v15(rand.Reader, j.privateKey, crypto.SHA256, hashed[:])
+	signature, err := j.SignWith([]byte(str), SchemePKCS1v15SHA256)
 	if err != nil {
 		return "", err
 	}
@@ -163,56 +285,29 @@ func (j *JSEncrypt) Sign(str string) (string, error) {
 
 // Verify verifies a string against a base64 encoded signature using SHA256.
 func (j *JSEncrypt) Verify(str, signature string) (bool, error) {
-	if j.publicKey == nil {
-		if _, err := j.getKey(); err != nil {
-			return false, err
-		}
-	}
-
 	sigBytes, err := base64.StdEncoding.DecodeString(signature)
 	if err != nil {
 		return false, err
 	}
 
-	hashed := sha256.Sum256([]byte(str))
-	err = rsa.VerifyPKCS1v15(j.publicKey, crypto.SHA256, hashed[:], sigBytes)
-	if err != nil {
+	err = j.VerifyWith([]byte(str), sigBytes, SchemePKCS1v15SHA256)
+	if errors.Is(err, rsa.ErrVerification) {
 		return false, nil // Signature not valid
 	}
+	if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
 // GetPrivateKey returns the PEM encoded private key.
 func (j *JSEncrypt) GetPrivateKey() (string, error) {
-	if _, err := j.getKey(); err != nil {
-		return "", err
-	}
-
-	// Encode to PKCS#1
-	privBytes := x509.MarshalPKCS1PrivateKey(j.privateKey)
-	pemBlock := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: privBytes,
-	}
-	return string(pem.EncodeToMemory(pemBlock)), nil
+	out, err := j.ExportPrivateKey(ExportOptions{Format: FormatPKCS1, Encoding: EncodingPEM})
+	return string(out), err
 }
 
 // GetPublicKey returns the PEM encoded public key.
 func (j *JSEncrypt) GetPublicKey() (string, error) {
-	if j.publicKey == nil {
-		if _, err := j.getKey(); err != nil {
-			return "", err
-		}
-	}
-
-	// Marshal PKIX
-	pubBytes, err := x509.MarshalPKIXPublicKey(j.publicKey)
-	if err != nil {
-		return "", err
-	}
-	pemBlock := &pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: pubBytes,
-	}
-	return string(pem.EncodeToMemory(pemBlock)), nil
+	out, err := j.ExportPublicKey(ExportOptions{Format: FormatPKIX, Encoding: EncodingPEM})
+	return string(out), err
 }
@@ -0,0 +1,150 @@
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// ContentEncryption is a JWE "enc" header value (RFC 7518, section 5.1).
+type ContentEncryption string
+
+const (
+	// A128CBCHS256 is AES-128-CBC with HMAC-SHA-256 truncated to 128 bits.
+	A128CBCHS256 ContentEncryption = "A128CBC-HS256"
+	A128GCM      ContentEncryption = "A128GCM"
+	A256GCM      ContentEncryption = "A256GCM"
+)
+
+// keySize returns the content encryption key size in bytes, or 0 for an
+// unsupported algorithm.
+func (enc ContentEncryption) keySize() int {
+	switch enc {
+	case A128CBCHS256:
+		return 32
+	case A128GCM:
+		return 16
+	case A256GCM:
+		return 32
+	}
+	return 0
+}
+
+// seal encrypts plaintext under cek, authenticating aad.
+func (enc ContentEncryption) seal(cek, aad, plaintext []byte) (iv, ciphertext, tag []byte, err error) {
+	if enc == A128CBCHS256 {
+		if iv, err = randomKey(aes.BlockSize); err != nil {
+			return nil, nil, nil, err
+		}
+		ciphertext, tag, err = sealCBCHMAC(cek, iv, aad, plaintext)
+		return iv, ciphertext, tag, err
+	}
+	aead, err := newGCM(cek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if iv, err = randomKey(aead.NonceSize()); err != nil {
+		return nil, nil, nil, err
+	}
+	sealed := aead.Seal(nil, iv, plaintext, aad)
+	split := len(sealed) - aead.Overhead()
+	return iv, sealed[:split], sealed[split:], nil
+}
+
+// open reverses seal. Every failure is jsencrypt.ErrDecryption.
+func (enc ContentEncryption) open(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if enc == A128CBCHS256 {
+		return openCBCHMAC(cek, iv, ciphertext, tag, aad)
+	}
+	aead, err := newGCM(cek)
+	if err != nil || len(iv) != aead.NonceSize() || len(tag) != aead.Overhead() {
+		return nil, jsencrypt.ErrDecryption
+	}
+	plaintext, err := aead.Open(nil, iv, append(ciphertext[:len(ciphertext):len(ciphertext)], tag...), aad)
+	if err != nil {
+		return nil, jsencrypt.ErrDecryption
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealCBCHMAC implements AES_128_CBC_HMAC_SHA_256 (RFC 7518, section
+// 5.2.2.1). The first half of cek is the MAC key, the second half the AES
+// key.
+func sealCBCHMAC(cek, iv, aad, plaintext []byte) (ciphertext, tag []byte, err error) {
+	block, err := aes.NewCipher(cek[16:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext = make([]byte, len(plaintext)+padding)
+	copy(ciphertext, plaintext)
+	for i := len(plaintext); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return ciphertext, cbcHMACTag(cek[:16], aad, iv, ciphertext), nil
+}
+
+// openCBCHMAC checks the tag before decrypting, so invalid padding is only
+// ever seen for authentic ciphertexts.
+func openCBCHMAC(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if len(cek) != 32 || len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, jsencrypt.ErrDecryption
+	}
+	if !hmac.Equal(tag, cbcHMACTag(cek[:16], aad, iv, ciphertext)) {
+		return nil, jsencrypt.ErrDecryption
+	}
+	block, err := aes.NewCipher(cek[16:])
+	if err != nil {
+		return nil, jsencrypt.ErrDecryption
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, jsencrypt.ErrDecryption
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, jsencrypt.ErrDecryption
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// cbcHMACTag is the first half of HMAC-SHA-256 over AAD || IV ||
+// ciphertext || AL, where AL is the AAD length in bits as a 64-bit
+// big-endian integer.
+func cbcHMACTag(macKey, aad, iv, ciphertext []byte) []byte {
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(len(aad))*8)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	mac.Write(al[:])
+	return mac.Sum(nil)[:16]
+}
+
+func randomKey(size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
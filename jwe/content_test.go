@@ -0,0 +1,66 @@
+package jwe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// Test vector from RFC 7518, appendix B.1.
+func TestCBCHMAC_RFC7518(t *testing.T) {
+	cek, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	iv, _ := hex.DecodeString("1af38c2dc2b96ffdd86694092341bc04")
+	plaintext := []byte("A cipher system must not be required to be secret, and it must be able to fall into the hands of the enemy without inconvenience")
+	aad := []byte("The second principle of Auguste Kerckhoffs")
+
+	ciphertext, tag, err := sealCBCHMAC(cek, iv, aad, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(ciphertext[:16]); got != "c80edfa32ddf39d5ef00c0b468834279" {
+		t.Errorf("Ciphertext starts with %s", got)
+	}
+	if got := hex.EncodeToString(tag); got != "652c3fa36b0a7c5b3219fab3a30bc1c4" {
+		t.Errorf("Tag = %s", got)
+	}
+
+	opened, err := openCBCHMAC(cek, iv, ciphertext, tag, aad)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("openCBCHMAC = %q, %v", opened, err)
+	}
+}
+
+func TestContentEncryption_Tamper(t *testing.T) {
+	for _, enc := range []ContentEncryption{A128CBCHS256, A128GCM, A256GCM} {
+		cek, _ := randomKey(enc.keySize())
+		aad := []byte("protected")
+		iv, ciphertext, tag, err := enc.seal(cek, aad, []byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext, err := enc.open(cek, iv, ciphertext, tag, aad); err != nil || string(plaintext) != "secret" {
+			t.Fatalf("%s: open = %q, %v", enc, plaintext, err)
+		}
+
+		flip := func(b []byte) []byte {
+			c := append([]byte(nil), b...)
+			c[0] ^= 1
+			return c
+		}
+		cases := map[string]func() ([]byte, error){
+			"iv":         func() ([]byte, error) { return enc.open(cek, flip(iv), ciphertext, tag, aad) },
+			"ciphertext": func() ([]byte, error) { return enc.open(cek, iv, flip(ciphertext), tag, aad) },
+			"tag":        func() ([]byte, error) { return enc.open(cek, iv, ciphertext, flip(tag), aad) },
+			"aad":        func() ([]byte, error) { return enc.open(cek, iv, ciphertext, tag, flip(aad)) },
+			"short tag":  func() ([]byte, error) { return enc.open(cek, iv, ciphertext, tag[:8], aad) },
+		}
+		for name, open := range cases {
+			if _, err := open(); !errors.Is(err, jsencrypt.ErrDecryption) {
+				t.Errorf("%s: modified %s: got %v, want ErrDecryption", enc, name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package jwe encrypts and decrypts JSON Web Encryption tokens (RFC 7516)
+// in the compact serialization with the RSA keys of a jsencrypt.JSEncrypt.
+//
+// The key management algorithms RSA1_5, RSA-OAEP and RSA-OAEP-256 are
+// supported with the content encryption algorithms A128CBC-HS256, A128GCM
+// and A256GCM. Compression ("zip") and critical header extensions are not.
+package jwe
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+var (
+	// ErrMalformed is returned for a token that is not a well formed
+	// compact JWE.
+	ErrMalformed = errors.New("jwe: malformed token")
+	// ErrAlgorithm is returned for a token whose "alg" or "enc" is not
+	// allowed.
+	ErrAlgorithm = errors.New("jwe: algorithm not allowed")
+	// ErrUnsupported is returned for a token using compression or critical
+	// header extensions.
+	ErrUnsupported = errors.New("jwe: unsupported header parameter")
+)
+
+// KeyAlgorithm is a JWE "alg" header value (RFC 7518, section 4.1).
+type KeyAlgorithm string
+
+const (
+	// RSA15 is RSAES-PKCS1-v1_5 ("RSA1_5"). It is only accepted by a
+	// Decrypter that lists it explicitly.
+	RSA15      KeyAlgorithm = "RSA1_5"
+	RSAOAEP    KeyAlgorithm = "RSA-OAEP"
+	RSAOAEP256 KeyAlgorithm = "RSA-OAEP-256"
+)
+
+// scheme returns the RSA encryption scheme for alg.
+func (alg KeyAlgorithm) scheme() (jsencrypt.Scheme, bool) {
+	switch alg {
+	case RSA15:
+		return jsencrypt.SchemePKCS1v15, true
+	case RSAOAEP:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingOAEP, Hash: crypto.SHA1}, true
+	case RSAOAEP256:
+		return jsencrypt.SchemeOAEPSHA256, true
+	}
+	return jsencrypt.Scheme{}, false
+}
+
+// Header is the JOSE protected header of a token.
+type Header struct {
+	Algorithm  KeyAlgorithm      `json:"alg"`
+	Encryption ContentEncryption `json:"enc"`
+	KeyID      string            `json:"kid,omitempty"`
+	Type       string            `json:"typ,omitempty"`
+	// ContentType is "JWT" for a nested JWT.
+	ContentType string `json:"cty,omitempty"`
+
+	Zip  string   `json:"zip,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Encrypt encrypts plaintext for recipient's public key. The header carries
+// the recipient's KeyID as "kid".
+func Encrypt(recipient *jsencrypt.JSEncrypt, plaintext []byte, alg KeyAlgorithm, enc ContentEncryption) (string, error) {
+	return EncryptWithHeader(recipient, plaintext, Header{Algorithm: alg, Encryption: enc})
+}
+
+// EncryptWithHeader is like Encrypt but takes the whole protected header,
+// for example to set "cty". The "kid" defaults to the recipient's KeyID.
+func EncryptWithHeader(recipient *jsencrypt.JSEncrypt, plaintext []byte, h Header) (string, error) {
+	scheme, ok := h.Algorithm.scheme()
+	if !ok || h.Encryption.keySize() == 0 {
+		return "", ErrAlgorithm
+	}
+	if h.Zip != "" || len(h.Crit) > 0 {
+		return "", ErrUnsupported
+	}
+	if h.KeyID == "" {
+		kid, err := recipient.KeyID()
+		if err != nil {
+			return "", err
+		}
+		h.KeyID = kid
+	}
+	rawHeader, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+
+	cek, err := randomKey(h.Encryption.keySize())
+	if err != nil {
+		return "", err
+	}
+	encryptedKey, err := recipient.EncryptWith(cek, scheme)
+	if err != nil {
+		return "", err
+	}
+	protected := b64.EncodeToString(rawHeader)
+	iv, ciphertext, tag, err := h.Encryption.seal(cek, []byte(protected), plaintext)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		protected,
+		b64.EncodeToString(encryptedKey),
+		b64.EncodeToString(iv),
+		b64.EncodeToString(ciphertext),
+		b64.EncodeToString(tag),
+	}, "."), nil
+}
+
+// KeySet looks up decryption keys by key ID. *jsencrypt.Keyring implements
+// it.
+type KeySet interface {
+	Get(kid string) (*jsencrypt.JSEncrypt, bool)
+}
+
+// Decrypter decrypts tokens with a private key.
+type Decrypter struct {
+	// Keys selects the private key by the token's "kid" header. Tokens
+	// without a "kid" are decrypted with Key.
+	Keys KeySet
+	// Key decrypts tokens when Keys is nil or the token has no "kid".
+	Key *jsencrypt.JSEncrypt
+	// Algorithms lists the accepted "alg" values. Default: RSA-OAEP and
+	// RSA-OAEP-256. RSA1_5 must be listed explicitly.
+	Algorithms []KeyAlgorithm
+	// Encryptions lists the accepted "enc" values. Default: all supported.
+	Encryptions []ContentEncryption
+}
+
+// Decrypt decrypts a compact JWE and returns the plaintext and the
+// protected header. Failures caused by the encrypted key or the ciphertext
+// all return jsencrypt.ErrDecryption.
+//
+// For RSA1_5 a key that fails to decrypt is replaced with a random one, as
+// RFC 7516 section 11.5 requires, so that the failure is only reported once
+// content decryption fails and cannot be told apart from a bad tag.
+func (d *Decrypter) Decrypt(token string) ([]byte, *Header, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, nil, ErrMalformed
+	}
+	segments := make([][]byte, len(parts))
+	for i, part := range parts {
+		decoded, err := b64.DecodeString(part)
+		if err != nil {
+			return nil, nil, ErrMalformed
+		}
+		segments[i] = decoded
+	}
+
+	var h Header
+	if err := json.Unmarshal(segments[0], &h); err != nil {
+		return nil, nil, ErrMalformed
+	}
+	if h.Zip != "" || len(h.Crit) > 0 {
+		return nil, nil, ErrUnsupported
+	}
+	scheme, ok := h.Algorithm.scheme()
+	if !ok || !d.allowedAlgorithm(h.Algorithm) || h.Encryption.keySize() == 0 || !d.allowedEncryption(h.Encryption) {
+		return nil, nil, ErrAlgorithm
+	}
+	key, err := d.key(h.KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cek []byte
+	if h.Algorithm == RSA15 {
+		cek, err = key.DecryptSessionKey(base64.StdEncoding.EncodeToString(segments[1]), h.Encryption.keySize())
+	} else {
+		cek, err = key.DecryptWith(segments[1], scheme)
+		if err == nil && len(cek) != h.Encryption.keySize() {
+			err = jsencrypt.ErrDecryption
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := h.Encryption.open(cek, segments[2], segments[3], segments[4], []byte(parts[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, &h, nil
+}
+
+func (d *Decrypter) allowedAlgorithm(alg KeyAlgorithm) bool {
+	if len(d.Algorithms) == 0 {
+		return alg == RSAOAEP || alg == RSAOAEP256
+	}
+	for _, a := range d.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decrypter) allowedEncryption(enc ContentEncryption) bool {
+	if len(d.Encryptions) == 0 {
+		return true
+	}
+	for _, e := range d.Encryptions {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decrypter) key(kid string) (*jsencrypt.JSEncrypt, error) {
+	if kid == "" || d.Keys == nil {
+		if d.Key == nil {
+			return nil, jsencrypt.ErrUnknownKeyID
+		}
+		return d.Key, nil
+	}
+	key, ok := d.Keys.Get(kid)
+	if !ok {
+		return nil, jsencrypt.ErrUnknownKeyID
+	}
+	return key, nil
+}
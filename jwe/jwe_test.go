@@ -0,0 +1,150 @@
+package jwe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+// withHeader replaces the protected header of a token.
+func withHeader(token, header string) string {
+	parts := strings.SplitN(token, ".", 2)
+	return b64.EncodeToString([]byte(header)) + "." + parts[1]
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	recipient := testkeys.PublicOnly(t, key)
+	kid, _ := key.KeyID()
+	decrypter := &Decrypter{Key: key, Algorithms: []KeyAlgorithm{RSA15, RSAOAEP, RSAOAEP256}}
+
+	for _, alg := range decrypter.Algorithms {
+		for _, enc := range []ContentEncryption{A128CBCHS256, A128GCM, A256GCM} {
+			token, err := Encrypt(recipient, []byte("card number"), alg, enc)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", alg, enc, err)
+			}
+			if n := strings.Count(token, "."); n != 4 {
+				t.Fatalf("%s/%s: expected 5 segments, got %d", alg, enc, n+1)
+			}
+
+			plaintext, header, err := decrypter.Decrypt(token)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", alg, enc, err)
+			}
+			if string(plaintext) != "card number" {
+				t.Errorf("%s/%s: got %q", alg, enc, plaintext)
+			}
+			if header.Algorithm != alg || header.Encryption != enc || header.KeyID != kid {
+				t.Errorf("%s/%s: unexpected header %+v", alg, enc, header)
+			}
+		}
+	}
+
+	if _, err := Encrypt(recipient, nil, "dir", A256GCM); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("Encrypt with alg dir: got %v, want ErrAlgorithm", err)
+	}
+	if _, err := Encrypt(recipient, nil, RSAOAEP, "A192CBC-HS384"); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("Encrypt with unsupported enc: got %v, want ErrAlgorithm", err)
+	}
+}
+
+func TestDecrypt_HeaderValidation(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	token, err := EncryptWithHeader(key, []byte("nested"), Header{Algorithm: RSAOAEP256, Encryption: A256GCM, ContentType: "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, header, err := (&Decrypter{Key: key}).Decrypt(token)
+	if err != nil || header.ContentType != "JWT" {
+		t.Fatalf("Decrypt = %+v, %v", header, err)
+	}
+
+	rsa15, err := Encrypt(key, []byte("legacy"), RSA15, A128CBCHS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		token     string
+		decrypter Decrypter
+		want      error
+	}{
+		{"RSA1_5 not allowed by default", rsa15, Decrypter{}, ErrAlgorithm},
+		{"enc not allowed", token, Decrypter{Encryptions: []ContentEncryption{A128CBCHS256}}, ErrAlgorithm},
+		{"unknown alg", withHeader(token, `{"alg":"dir","enc":"A256GCM"}`), Decrypter{}, ErrAlgorithm},
+		{"compressed", withHeader(token, `{"alg":"RSA-OAEP-256","enc":"A256GCM","zip":"DEF"}`), Decrypter{}, ErrUnsupported},
+		{"critical", withHeader(token, `{"alg":"RSA-OAEP-256","enc":"A256GCM","crit":["x"],"x":1}`), Decrypter{}, ErrUnsupported},
+		// The protected header is authenticated as additional data.
+		{"modified header", withHeader(token, `{"alg":"RSA-OAEP-256","enc":"A256GCM"}`), Decrypter{}, jsencrypt.ErrDecryption},
+		{"wrong enc", withHeader(token, `{"alg":"RSA-OAEP-256","enc":"A128GCM"}`), Decrypter{}, jsencrypt.ErrDecryption},
+		{"malformed", "a.b.c.d", Decrypter{}, ErrMalformed},
+		{"bad base64", "!.b.c.d.e", Decrypter{}, ErrMalformed},
+		{"bad json", withHeader(token, `alg`), Decrypter{}, ErrMalformed},
+	}
+	for _, tt := range tests {
+		tt.decrypter.Key = key
+		if _, _, err := tt.decrypter.Decrypt(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDecrypt_RSA15InvalidKey(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	decrypter := &Decrypter{Key: key, Algorithms: []KeyAlgorithm{RSA15}}
+	token, err := Encrypt(key, []byte("legacy"), RSA15, A128CBCHS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A corrupted encrypted key is reported the same way as a bad tag.
+	parts := strings.Split(token, ".")
+	encryptedKey, _ := b64.DecodeString(parts[1])
+	encryptedKey[len(encryptedKey)-1] ^= 1
+	parts[1] = b64.EncodeToString(encryptedKey)
+	if _, _, err := decrypter.Decrypt(strings.Join(parts, ".")); !errors.Is(err, jsencrypt.ErrDecryption) {
+		t.Errorf("Corrupted RSA1_5 key: got %v, want ErrDecryption", err)
+	}
+
+	parts = strings.Split(token, ".")
+	parts[4] = b64.EncodeToString(make([]byte, 16))
+	if _, _, err := decrypter.Decrypt(strings.Join(parts, ".")); !errors.Is(err, jsencrypt.ErrDecryption) {
+		t.Errorf("Bad tag: got %v, want ErrDecryption", err)
+	}
+}
+
+func TestDecrypt_Keyring(t *testing.T) {
+	oldKey, newKey := testkeys.Key(t, 0), testkeys.Key(t, 1)
+	keyring := jsencrypt.NewKeyring()
+	for _, key := range []*jsencrypt.JSEncrypt{oldKey, newKey} {
+		if _, err := keyring.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decrypter := &Decrypter{Keys: keyring}
+
+	for _, key := range []*jsencrypt.JSEncrypt{oldKey, newKey} {
+		token, err := Encrypt(testkeys.PublicOnly(t, key), []byte("rotated"), RSAOAEP, A256GCM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext, _, err := decrypter.Decrypt(token); err != nil || string(plaintext) != "rotated" {
+			t.Errorf("Decrypt with keyring = %q, %v", plaintext, err)
+		}
+	}
+
+	other, err := Encrypt(testkeys.PublicOnly(t, testkeys.Key(t, 2)), []byte("x"), RSAOAEP, A256GCM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := decrypter.Decrypt(other); !errors.Is(err, jsencrypt.ErrUnknownKeyID) {
+		t.Errorf("Unknown kid: got %v, want ErrUnknownKeyID", err)
+	}
+	if _, _, err := (&Decrypter{Key: oldKey}).Decrypt(other); !errors.Is(err, jsencrypt.ErrDecryption) {
+		t.Errorf("Wrong key: got %v, want ErrDecryption", err)
+	}
+}
@@ -0,0 +1,152 @@
+package jsencrypt
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// jwk is the JSON form of an RSA JSON Web Key (RFC 7517, RFC 7518 section
+// 6.3). All integers are unsigned big-endian, base64url encoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	Dp  string `json:"dp,omitempty"`
+	Dq  string `json:"dq,omitempty"`
+	Qi  string `json:"qi,omitempty"`
+	// Oth lists additional primes, which are not supported.
+	Oth json.RawMessage `json:"oth,omitempty"`
+}
+
+// ExportPublicJWK returns the public key as a JWK. The "kid" member is the
+// key's KeyID.
+func (j *JSEncrypt) ExportPublicJWK() ([]byte, error) {
+	pub, err := j.ensurePublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(publicJWK(pub))
+}
+
+// ExportPrivateJWK returns the private key as a JWK, generating a key pair
+// if none is set.
+func (j *JSEncrypt) ExportPrivateJWK() ([]byte, error) {
+	priv, release, err := j.getKey()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if len(priv.Primes) != 2 {
+		return nil, errors.New("jwk: multi-prime keys are not supported")
+	}
+	p, q := priv.Primes[0], priv.Primes[1]
+	one := big.NewInt(1)
+
+	key := publicJWK(&priv.PublicKey)
+	key.D = encodeJWKInt(priv.D)
+	key.P = encodeJWKInt(p)
+	key.Q = encodeJWKInt(q)
+	key.Dp = encodeJWKInt(new(big.Int).Mod(priv.D, new(big.Int).Sub(p, one)))
+	key.Dq = encodeJWKInt(new(big.Int).Mod(priv.D, new(big.Int).Sub(q, one)))
+	key.Qi = encodeJWKInt(new(big.Int).ModInverse(q, p))
+	return json.Marshal(key)
+}
+
+// SetJWK sets the key from an RSA JWK. A JWK with a "d" member sets the
+// private key, otherwise the public key is set.
+func (j *JSEncrypt) SetJWK(data []byte) error {
+	var key jwk
+	if err := json.Unmarshal(data, &key); err != nil {
+		return errors.New("jwk: " + err.Error())
+	}
+	if key.Kty != "RSA" {
+		return errors.New("jwk: unsupported key type " + strconv.Quote(key.Kty))
+	}
+	pub, err := key.publicKey()
+	if err != nil {
+		return err
+	}
+	if key.D == "" {
+		return j.setPublicKey(pub)
+	}
+
+	priv, err := key.privateKey(pub)
+	if err != nil {
+		return err
+	}
+	return j.setPrivateKey(priv)
+}
+
+func publicJWK(pub *rsa.PublicKey) *jwk {
+	return &jwk{
+		Kty: "RSA",
+		Kid: jwkThumbprint(pub),
+		N:   encodeJWKInt(pub.N),
+		E:   encodeJWKInt(big.NewInt(int64(pub.E))),
+	}
+}
+
+func (k *jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := decodeJWKInt("n", k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeJWKInt("e", k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || e.Bit(0) == 0 {
+		return nil, errors.New("jwk: invalid public exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k *jwk) privateKey(pub *rsa.PublicKey) (*rsa.PrivateKey, error) {
+	if len(k.Oth) > 0 {
+		return nil, errors.New("jwk: multi-prime keys are not supported")
+	}
+	d, err := decodeJWKInt("d", k.D)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeJWKInt("p", k.P)
+	if err != nil {
+		return nil, err
+	}
+	q, err := decodeJWKInt("q", k.Q)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CRT values are recomputed rather than trusted.
+	priv := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+	if err := priv.Validate(); err != nil {
+		return nil, errors.New("jwk: " + err.Error())
+	}
+	priv.Precompute()
+	return priv, nil
+}
+
+func encodeJWKInt(x *big.Int) string {
+	return b64url.EncodeToString(x.Bytes())
+}
+
+func decodeJWKInt(name, s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("jwk: missing member " + strconv.Quote(name))
+	}
+	b, err := b64url.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("jwk: invalid member " + strconv.Quote(name))
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,112 @@
+package jsencrypt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJWK_RoundTrip(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	kid, err := jsCrypt.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateJWK, err := jsCrypt.ExportPrivateJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members map[string]string
+	if err := json.Unmarshal(privateJWK, &members); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kty", "kid", "n", "e", "d", "p", "q", "dp", "dq", "qi"} {
+		if members[name] == "" {
+			t.Errorf("Private JWK is missing %q", name)
+		}
+	}
+	if members["kid"] != kid || members["e"] != "AQAB" {
+		t.Errorf("Unexpected kid %q or e %q", members["kid"], members["e"])
+	}
+	priv := jsCrypt.privateKey
+	if members["dp"] != encodeJWKInt(priv.Precomputed.Dp) || members["qi"] != encodeJWKInt(priv.Precomputed.Qinv) {
+		t.Error("CRT members do not match the key")
+	}
+
+	imported := NewJSEncrypt()
+	if err := imported.SetJWK(privateJWK); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := jsCrypt.GetPrivateKey()
+	got, _ := imported.GetPrivateKey()
+	if got != want {
+		t.Error("Private key changed in a JWK round trip")
+	}
+
+	publicJWK, err := jsCrypt.ExportPublicJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(publicJWK), `"d"`) {
+		t.Error("Public JWK must not contain private members")
+	}
+	verifier := NewJSEncrypt()
+	if err := verifier.SetJWK(publicJWK); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := jsCrypt.Sign("jwk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := verifier.Verify("jwk", signature); !ok || err != nil {
+		t.Errorf("Verify with imported public JWK = %v, %v", ok, err)
+	}
+}
+
+// Public key from RFC 7638, section 3.1.
+func TestJWK_SetRFC7638Key(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	err := jsCrypt.SetJWK([]byte(`{"kty":"RSA","alg":"RS256","kid":"2011-04-29","e":"AQAB",` +
+		`"n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid, _ := jsCrypt.KeyID(); kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("KeyID = %s", kid)
+	}
+}
+
+func TestJWK_Invalid(t *testing.T) {
+	publicJWK, err := newTestKey(t, testPrivateKeys[3]).ExportPublicJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherJWK, err := newTestKey(t, exampleTestKeys.privateKey).ExportPrivateJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other map[string]string
+	if err := json.Unmarshal(otherJWK, &other); err != nil {
+		t.Fatal(err)
+	}
+	// A private exponent that does not belong to the modulus.
+	mismatched := strings.TrimSuffix(string(publicJWK), "}") +
+		`,"d":"` + other["d"] + `","p":"` + other["p"] + `","q":"` + other["q"] + `"}`
+
+	tests := map[string]string{
+		"not json":      `{`,
+		"ec key":        `{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}`,
+		"missing n":     `{"kty":"RSA","e":"AQAB"}`,
+		"bad base64":    `{"kty":"RSA","n":"!!","e":"AQAB"}`,
+		"even exponent": `{"kty":"RSA","n":"AQAB","e":"Ag"}`,
+		"missing prime": `{"kty":"RSA","n":"AQAB","e":"AQAB","d":"AQ"}`,
+		"multi prime":   `{"kty":"RSA","n":"AQAB","e":"AQAB","d":"AQ","p":"AQ","q":"AQ","oth":[]}`,
+		"mismatched d":  mismatched,
+	}
+	for name, data := range tests {
+		if err := NewJSEncrypt().SetJWK([]byte(data)); err == nil {
+			t.Errorf("%s: SetJWK should fail", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// JWKS is a JSON Web Key Set (RFC 7517, section 5) of RSA verification
+// keys, indexed by key ID. It implements KeySet.
+type JWKS struct {
+	keys map[string]jwksEntry
+}
+
+type jwksEntry struct {
+	key *jsencrypt.JSEncrypt
+	alg Algorithm
+}
+
+// ParseJWKS parses a JWK Set document such as an identity provider's
+// jwks_uri. Keys of other types and keys marked "use":"enc" are skipped.
+// Keys without a "kid" are indexed by their RFC 7638 thumbprint.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var doc struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.New("jwks: " + err.Error())
+	}
+
+	set := &JWKS{keys: make(map[string]jwksEntry)}
+	for _, raw := range doc.Keys {
+		var meta struct {
+			Kty string    `json:"kty"`
+			Kid string    `json:"kid"`
+			Use string    `json:"use"`
+			Alg Algorithm `json:"alg"`
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, errors.New("jwks: " + err.Error())
+		}
+		if meta.Kty != "RSA" || (meta.Use != "" && meta.Use != "sig") {
+			continue
+		}
+
+		key := jsencrypt.NewJSEncrypt()
+		if err := key.SetJWK(raw); err != nil {
+			return nil, err
+		}
+		kid := meta.Kid
+		if kid == "" {
+			var err error
+			if kid, err = key.KeyID(); err != nil {
+				return nil, err
+			}
+		}
+		if _, dup := set.keys[kid]; dup {
+			return nil, errors.New("jwks: duplicate key id " + kid)
+		}
+		set.keys[kid] = jwksEntry{key: key, alg: meta.Alg}
+	}
+	return set, nil
+}
+
+// Get returns the key with the given key ID.
+func (s *JWKS) Get(kid string) (*jsencrypt.JSEncrypt, bool) {
+	entry, ok := s.keys[kid]
+	return entry.key, ok
+}
+
+// KeyIDs returns the key IDs in the set, sorted.
+func (s *JWKS) KeyIDs() []string {
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+// algorithm returns the "alg" declared for a key, if any.
+func (s *JWKS) algorithm(kid string) Algorithm {
+	return s.keys[kid].alg
+}
@@ -0,0 +1,99 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+// jwksDocument builds a JWK Set from public keys, adding extra members to
+// each key.
+func jwksDocument(t *testing.T, extra []string, keys ...*jsencrypt.JSEncrypt) []byte {
+	entries := make([]string, len(keys))
+	for i, key := range keys {
+		jwk, err := key.ExportPublicJWK()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[i] = string(jwk)
+		if extra[i] != "" {
+			entries[i] = strings.TrimSuffix(entries[i], "}") + "," + extra[i] + "}"
+		}
+	}
+	return []byte(`{"keys":[` + strings.Join(entries, ",") + `]}`)
+}
+
+func TestJWKS_SelectsKeyByKid(t *testing.T) {
+	first, second, encryption := testkeys.Key(t, 0), testkeys.Key(t, 1), testkeys.Key(t, 2)
+	set, err := ParseJWKS(jwksDocument(t, []string{`"alg":"RS256","use":"sig"`, `"alg":"PS256"`, `"use":"enc"`}, first, second, encryption))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kids := set.KeyIDs(); len(kids) != 2 {
+		t.Fatalf("Expected the encryption key to be skipped, got %v", kids)
+	}
+
+	verifier := &Verifier{Keys: set, Algorithms: []Algorithm{RS256, PS256}}
+	firstToken, _ := Sign(first, RS256, Claims{Subject: "first"})
+	secondToken, _ := Sign(second, PS256, Claims{Subject: "second"})
+	for _, token := range []string{firstToken, secondToken} {
+		if err := verifier.Verify(token, nil); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+	}
+
+	// The key's declared "alg" restricts it even if the verifier allows more.
+	wrongAlg, _ := Sign(first, PS256, Claims{})
+	if err := verifier.Verify(wrongAlg, nil); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("Token with an algorithm the key does not declare: got %v, want ErrAlgorithm", err)
+	}
+
+	unknown, _ := Sign(encryption, RS256, Claims{})
+	if err := verifier.Verify(unknown, nil); !errors.Is(err, jsencrypt.ErrUnknownKeyID) {
+		t.Errorf("Token with unknown kid: got %v, want ErrUnknownKeyID", err)
+	}
+}
+
+func TestJWKS_ParseErrors(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	kid, _ := key.KeyID()
+	if _, err := ParseJWKS([]byte(`not json`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+	if _, err := ParseJWKS(jwksDocument(t, []string{"", ""}, key, key)); err == nil || !strings.Contains(err.Error(), kid) {
+		t.Errorf("Expected a duplicate key id error, got %v", err)
+	}
+	set, err := ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`))
+	if err != nil || len(set.KeyIDs()) != 0 {
+		t.Errorf("Non-RSA keys should be skipped, got %v, %v", set, err)
+	}
+}
+
+func TestVerify_Keyring(t *testing.T) {
+	oldKey, newKey := testkeys.Key(t, 0), testkeys.Key(t, 1)
+	keyring := jsencrypt.NewKeyring()
+	for _, key := range []*jsencrypt.JSEncrypt{oldKey, newKey} {
+		if _, err := keyring.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verifier := &Verifier{Keys: keyring}
+	for _, key := range []*jsencrypt.JSEncrypt{oldKey, newKey} {
+		token, err := Sign(key, RS256, Claims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.Verify(token, nil); err != nil {
+			t.Errorf("Verify with keyring failed: %v", err)
+		}
+	}
+
+	noKid := forge(`{"alg":"RS256"}`, `{}`, nil)
+	if err := verifier.Verify(noKid, nil); !errors.Is(err, jsencrypt.ErrUnknownKeyID) {
+		t.Errorf("Token without kid and no fallback key: got %v, want ErrUnknownKeyID", err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package jwt signs and verifies JSON Web Tokens (RFC 7519) in the compact
+// JWS serialization with the RSA keys of a jsencrypt.JSEncrypt.
+//
+// Only the RSA algorithms RS256, RS384, RS512, PS256, PS384 and PS512 are
+// implemented. Tokens using "none" or an HMAC algorithm are always
+// rejected, so a public key can never be misused as an HMAC secret.
+package jwt
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+var (
+	// ErrMalformed is returned for a token that is not a well formed JWS.
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrAlgorithm is returned for a token whose "alg" is not allowed.
+	ErrAlgorithm = errors.New("jwt: algorithm not allowed")
+	// ErrSignature is returned when the signature does not verify.
+	ErrSignature = errors.New("jwt: invalid signature")
+)
+
+// Algorithm is a JWS "alg" header value (RFC 7518, section 3.1).
+type Algorithm string
+
+const (
+	RS256 Algorithm = "RS256"
+	RS384 Algorithm = "RS384"
+	RS512 Algorithm = "RS512"
+	PS256 Algorithm = "PS256"
+	PS384 Algorithm = "PS384"
+	PS512 Algorithm = "PS512"
+)
+
+// scheme returns the RSA signature scheme for alg. RFC 7518 requires the PSS
+// salt to be as long as the hash, which is the Scheme default.
+func (alg Algorithm) scheme() (jsencrypt.Scheme, bool) {
+	switch alg {
+	case RS256:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPKCS1v15, Hash: crypto.SHA256}, true
+	case RS384:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPKCS1v15, Hash: crypto.SHA384}, true
+	case RS512:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPKCS1v15, Hash: crypto.SHA512}, true
+	case PS256:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPSS, Hash: crypto.SHA256}, true
+	case PS384:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPSS, Hash: crypto.SHA384}, true
+	case PS512:
+		return jsencrypt.Scheme{Padding: jsencrypt.PaddingPSS, Hash: crypto.SHA512}, true
+	}
+	return jsencrypt.Scheme{}, false
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg  Algorithm `json:"alg"`
+	Typ  string    `json:"typ,omitempty"`
+	Kid  string    `json:"kid,omitempty"`
+	Crit []string  `json:"crit,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Sign serializes claims as JSON and signs them with key's private key
+// using alg. The header carries "typ":"JWT" and the key's KeyID as "kid".
+// claims is usually a struct embedding Claims, or a map.
+func Sign(key *jsencrypt.JSEncrypt, alg Algorithm, claims any) (string, error) {
+	scheme, ok := alg.scheme()
+	if !ok {
+		return "", ErrAlgorithm
+	}
+	kid, err := key.KeyID()
+	if err != nil {
+		return "", err
+	}
+	h, err := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+	signature, err := key.SignWith([]byte(signingInput), scheme)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(signature), nil
+}
+
+// token is a compact JWS split into its parts.
+type token struct {
+	header       header
+	payload      []byte
+	signingInput string
+	signature    []byte
+}
+
+func parse(s string) (*token, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	t := &token{payload: payload, signingInput: parts[0] + "." + parts[1], signature: signature}
+	if err := json.Unmarshal(rawHeader, &t.header); err != nil {
+		return nil, ErrMalformed
+	}
+	return t, nil
+}
@@ -0,0 +1,192 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+type testClaims struct {
+	Claims
+	Role string `json:"role"`
+}
+
+// forge builds a token with an arbitrary header and signature.
+func forge(header, payload string, signature []byte) string {
+	return b64.EncodeToString([]byte(header)) + "." + b64.EncodeToString([]byte(payload)) + "." + b64.EncodeToString(signature)
+}
+
+func TestSignVerify(t *testing.T) {
+	// PS512 needs a modulus of at least 1040 bits; the test keys have 2048.
+	key := testkeys.Key(t, 0)
+	verifier := &Verifier{
+		Key:        testkeys.PublicOnly(t, key),
+		Algorithms: []Algorithm{RS256, RS384, RS512, PS256, PS384, PS512},
+	}
+
+	for _, alg := range verifier.Algorithms {
+		now := time.Now()
+		token, err := Sign(key, alg, testClaims{
+			Claims: Claims{Subject: "alice", ExpiresAt: NewNumericDate(now.Add(time.Hour)), IssuedAt: NewNumericDate(now)},
+			Role:   "admin",
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+
+		var claims testClaims
+		if err := verifier.Verify(token, &claims); err != nil {
+			t.Fatalf("%s: Verify failed: %v", alg, err)
+		}
+		if claims.Subject != "alice" || claims.Role != "admin" {
+			t.Errorf("%s: unexpected claims %+v", alg, claims)
+		}
+
+		// Any modification of the signed content must be detected.
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + b64.EncodeToString([]byte(`{"sub":"mallory","role":"admin"}`)) + "." + parts[2]
+		if err := verifier.Verify(tampered, nil); !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: tampered payload: got %v, want ErrSignature", alg, err)
+		}
+	}
+
+	token, err := Sign(key, RS256, Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := b64.DecodeString(strings.Split(token, ".")[0])
+	kid, _ := key.KeyID()
+	if want := `{"alg":"RS256","typ":"JWT","kid":"` + kid + `"}`; string(header) != want {
+		t.Errorf("Header = %s, want %s", header, want)
+	}
+
+	if _, err := Sign(key, "HS256", Claims{}); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("Sign with HS256: got %v, want ErrAlgorithm", err)
+	}
+}
+
+func TestVerify_AlgorithmConfusion(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	pem, err := key.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := key.KeyID()
+	payload := `{"sub":"admin"}`
+
+	// An attacker signs with HMAC using the public key as the secret.
+	hmacHeader := `{"alg":"HS256","typ":"JWT","kid":"` + kid + `"}`
+	mac := hmac.New(sha256.New, []byte(pem))
+	mac.Write([]byte(b64.EncodeToString([]byte(hmacHeader)) + "." + b64.EncodeToString([]byte(payload))))
+
+	psToken, err := Sign(key, PS256, Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"none":         forge(`{"alg":"none"}`, payload, nil),
+		"None":         forge(`{"alg":"None"}`, payload, nil),
+		"HS256":        forge(hmacHeader, payload, mac.Sum(nil)),
+		"not allowed":  psToken,
+		"missing alg":  forge(`{"typ":"JWT"}`, payload, nil),
+		"critical ext": forge(`{"alg":"RS256","crit":["exp"],"exp":1}`, payload, nil),
+	}
+	for _, allowed := range [][]Algorithm{nil, {"none", "HS256", RS256}} {
+		verifier := &Verifier{Key: testkeys.PublicOnly(t, key), Algorithms: allowed}
+		for name, token := range tokens {
+			if err := verifier.Verify(token, nil); err == nil {
+				t.Errorf("%s token accepted with Algorithms %v", name, allowed)
+			}
+		}
+		if err := verifier.Verify(tokens["HS256"], nil); !errors.Is(err, ErrAlgorithm) {
+			t.Errorf("HS256 token: got %v, want ErrAlgorithm", err)
+		}
+	}
+}
+
+func TestVerify_Claims(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	now := time.Unix(1700000000, 0)
+	at := func(d time.Duration) *NumericDate { return NewNumericDate(now.Add(d)) }
+
+	tests := []struct {
+		name     string
+		claims   Claims
+		verifier Verifier
+		want     error
+	}{
+		{"valid", Claims{ExpiresAt: at(time.Minute), NotBefore: at(-time.Minute), IssuedAt: at(0)}, Verifier{}, nil},
+		{"expired", Claims{ExpiresAt: at(-time.Second)}, Verifier{}, ErrExpired},
+		{"expires now", Claims{ExpiresAt: at(0)}, Verifier{}, ErrExpired},
+		{"expired within skew", Claims{ExpiresAt: at(-10 * time.Second)}, Verifier{ClockSkew: time.Minute}, nil},
+		{"not yet valid", Claims{NotBefore: at(time.Minute)}, Verifier{}, ErrNotYetValid},
+		{"nbf within skew", Claims{NotBefore: at(10 * time.Second)}, Verifier{ClockSkew: time.Minute}, nil},
+		{"issued in future", Claims{IssuedAt: at(time.Hour)}, Verifier{ClockSkew: time.Minute}, ErrNotYetValid},
+		{"missing exp", Claims{}, Verifier{RequireExpiry: true}, ErrMissingExpiry},
+		{"issuer", Claims{Issuer: "https://idp"}, Verifier{Issuer: "https://idp"}, nil},
+		{"wrong issuer", Claims{Issuer: "https://evil"}, Verifier{Issuer: "https://idp"}, ErrIssuer},
+		{"audience list", Claims{Audience: Audience{"web", "api"}}, Verifier{Audience: "api"}, nil},
+		{"wrong audience", Claims{Audience: Audience{"web"}}, Verifier{Audience: "api"}, ErrAudience},
+		{"missing audience", Claims{}, Verifier{Audience: "api"}, ErrAudience},
+	}
+	for _, tt := range tests {
+		token, err := Sign(key, RS256, tt.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.verifier.Key = key
+		tt.verifier.Now = func() time.Time { return now }
+		if err := tt.verifier.Verify(token, nil); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Fractional NumericDate values are valid JSON numbers.
+	token, err := Sign(key, RS256, map[string]any{"exp": 1700000000.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &Verifier{Key: key, Now: func() time.Time { return now }}
+	if err := verifier.Verify(token, nil); err != nil {
+		t.Errorf("Fractional exp: %v", err)
+	}
+	token, err = Sign(key, RS256, map[string]any{"exp": "tomorrow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.Verify(token, nil); !errors.Is(err, ErrMalformed) {
+		t.Errorf("String exp: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	verifier := &Verifier{Key: testkeys.Key(t, 0)}
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!!.e30.", forge("not json", "{}", nil)} {
+		if err := verifier.Verify(token, nil); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Verify(%q) = %v, want ErrMalformed", token, err)
+		}
+	}
+}
+
+func TestAudience_JSON(t *testing.T) {
+	for in, want := range map[string]Audience{`"api"`: {"api"}, `["a","b"]`: {"a", "b"}} {
+		var aud Audience
+		if err := json.Unmarshal([]byte(in), &aud); err != nil {
+			t.Fatal(err)
+		}
+		if len(aud) != len(want) || aud[0] != want[0] {
+			t.Errorf("Unmarshal(%s) = %v", in, aud)
+		}
+		out, _ := json.Marshal(aud)
+		if string(out) != in {
+			t.Errorf("Marshal(%v) = %s, want %s", aud, out, in)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+var (
+	// ErrExpired is returned for a token past its "exp" time.
+	ErrExpired = errors.New("jwt: token expired")
+	// ErrNotYetValid is returned for a token before its "nbf" time, or
+	// issued in the future.
+	ErrNotYetValid = errors.New("jwt: token not yet valid")
+	// ErrMissingExpiry is returned when Verifier.RequireExpiry is set and the
+	// token has no "exp" claim.
+	ErrMissingExpiry = errors.New("jwt: token has no expiry")
+	// ErrIssuer is returned when "iss" is not the expected issuer.
+	ErrIssuer = errors.New("jwt: unexpected issuer")
+	// ErrAudience is returned when "aud" does not contain the expected
+	// audience.
+	ErrAudience = errors.New("jwt: unexpected audience")
+)
+
+// KeySet looks up verification keys by key ID. *jsencrypt.Keyring and *JWKS
+// implement it.
+type KeySet interface {
+	Get(kid string) (*jsencrypt.JSEncrypt, bool)
+}
+
+// Verifier checks token signatures and claims.
+type Verifier struct {
+	// Keys selects the verification key by the token's "kid" header. Tokens
+	// without a "kid" are verified with Key.
+	Keys KeySet
+	// Key verifies tokens when Keys is nil or the token has no "kid".
+	Key *jsencrypt.JSEncrypt
+	// Algorithms lists the accepted "alg" values. Default: RS256 only.
+	// Algorithms other than the RSA ones in this package are never accepted.
+	Algorithms []Algorithm
+	// Issuer, if set, must equal the "iss" claim.
+	Issuer string
+	// Audience, if set, must be one of the "aud" claim values.
+	Audience string
+	// RequireExpiry rejects tokens without an "exp" claim.
+	RequireExpiry bool
+	// ClockSkew is the leeway allowed when checking "exp", "nbf" and "iat".
+	ClockSkew time.Duration
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the signature and registered claims of a compact JWS token
+// and, if claims is not nil, unmarshals the payload into it. The error
+// identifies the first check that failed.
+func (v *Verifier) Verify(tokenString string, claims any) error {
+	t, err := parse(tokenString)
+	if err != nil {
+		return err
+	}
+	if len(t.header.Crit) > 0 {
+		// No header extensions are understood (RFC 7515, section 4.1.11).
+		return ErrMalformed
+	}
+	scheme, ok := t.header.Alg.scheme()
+	if !ok || !v.allowed(t.header.Alg) {
+		return ErrAlgorithm
+	}
+
+	key, err := v.key(t.header)
+	if err != nil {
+		return err
+	}
+	if err := key.VerifyWith([]byte(t.signingInput), t.signature, scheme); err != nil {
+		if errors.Is(err, jsencrypt.ErrPolicyViolation) {
+			return err
+		}
+		return ErrSignature
+	}
+
+	var registered Claims
+	if err := json.Unmarshal(t.payload, &registered); err != nil {
+		return ErrMalformed
+	}
+	if err := v.validate(&registered); err != nil {
+		return err
+	}
+	if claims != nil {
+		if err := json.Unmarshal(t.payload, claims); err != nil {
+			return ErrMalformed
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) allowed(alg Algorithm) bool {
+	if len(v.Algorithms) == 0 {
+		return alg == RS256
+	}
+	for _, a := range v.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// key selects the verification key for a token header. A key from a JWKS
+// that declares an "alg" is only used with that algorithm.
+func (v *Verifier) key(h header) (*jsencrypt.JSEncrypt, error) {
+	if h.Kid == "" || v.Keys == nil {
+		if v.Key == nil {
+			return nil, jsencrypt.ErrUnknownKeyID
+		}
+		return v.Key, nil
+	}
+	if set, ok := v.Keys.(*JWKS); ok {
+		if alg := set.algorithm(h.Kid); alg != "" && alg != h.Alg {
+			return nil, ErrAlgorithm
+		}
+	}
+	key, ok := v.Keys.Get(h.Kid)
+	if !ok {
+		return nil, jsencrypt.ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	current := now()
+
+	if c.ExpiresAt == nil {
+		if v.RequireExpiry {
+			return ErrMissingExpiry
+		}
+	} else if !current.Before(c.ExpiresAt.Add(v.ClockSkew)) {
+		return ErrExpired
+	}
+	if c.NotBefore != nil && current.Add(v.ClockSkew).Before(c.NotBefore.Time) {
+		return ErrNotYetValid
+	}
+	if c.IssuedAt != nil && current.Add(v.ClockSkew).Before(c.IssuedAt.Time) {
+		return ErrNotYetValid
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" && !c.Audience.Contains(v.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
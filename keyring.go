@@ -0,0 +1,260 @@
+package jsencrypt
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownKeyID is returned when a ciphertext or signature names a key
+	// that is not in the keyring.
+	ErrUnknownKeyID = errors.New("keyring: unknown key id")
+	// ErrNoActiveKey is returned by Encrypt and Sign on an empty keyring.
+	ErrNoActiveKey = errors.New("keyring: no active key")
+	// ErrPublicKeyOnly is returned by Decrypt and Sign when the selected key
+	// was added without its private half. The key is left alone: a
+	// JSEncrypt asked to decrypt or sign without a private key would
+	// generate a new key pair in place of the public key.
+	ErrPublicKeyOnly = errors.New("keyring: private key required, only a public key is set")
+)
+
+// keyIDSeparator separates the key ID from the base64 payload. It appears in
+// neither base64url key IDs nor standard base64, so parsing is unambiguous.
+const keyIDSeparator = "."
+
+// Keyring holds several keys indexed by KeyID so keys can be rotated without
+// breaking existing ciphertexts and signatures. New ciphertexts and
+// signatures are produced with the active key and carry its key ID as a
+// prefix ("<kid>.<base64>"); Decrypt and Verify use the prefix to select the
+// key.
+//
+// A Keyring is safe for concurrent use.
+type Keyring struct {
+	mu     sync.RWMutex
+	keys   map[string]*JSEncrypt
+	order  []string
+	active string
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*JSEncrypt)}
+}
+
+// Add adds a key and returns its key ID. The first key added becomes the
+// active key. Adding a key that is already present replaces it, which can be
+// used to attach the private half of a key added earlier as public only.
+func (k *Keyring) Add(key *JSEncrypt) (string, error) {
+	pub := key.keys().public()
+	if pub == nil {
+		return "", ErrNoKey
+	}
+	kid := jwkThumbprint(pub)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[kid]; !ok {
+		k.order = append(k.order, kid)
+	}
+	k.keys[kid] = key
+	if k.active == "" {
+		k.active = kid
+	}
+	return kid, nil
+}
+
+// Remove removes a key. Removing the active key leaves the keyring without
+// one until SetActive is called.
+func (k *Keyring) Remove(kid string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[kid]; !ok {
+		return
+	}
+	delete(k.keys, kid)
+	for i, id := range k.order {
+		if id == kid {
+			k.order = append(k.order[:i], k.order[i+1:]...)
+			break
+		}
+	}
+	if k.active == kid {
+		k.active = ""
+	}
+}
+
+// SetActive selects the key used by Encrypt and Sign.
+func (k *Keyring) SetActive(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[kid]; !ok {
+		return ErrUnknownKeyID
+	}
+	k.active = kid
+	return nil
+}
+
+// ActiveKeyID returns the ID of the active key, or "" if there is none.
+func (k *Keyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Get returns the key with the given ID.
+func (k *Keyring) Get(kid string) (*JSEncrypt, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// KeyIDs returns the IDs of all keys in the order they were added.
+func (k *Keyring) KeyIDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]string(nil), k.order...)
+}
+
+// Encrypt encrypts plaintext with the active key and returns
+// "<kid>.<base64 ciphertext>".
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	kid, key, err := k.activeKey()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := key.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return kid + keyIDSeparator + ciphertext, nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt. Ciphertexts without a
+// key ID, as produced by JSEncrypt.Encrypt, are tried against every private
+// key in the keyring in turn.
+//
+// PKCS#1 v1.5 padding only detects a wrong key probabilistically, so trial
+// decryption should be limited to migrating legacy data. Keys with
+// ImplicitRejection set are never tried: they return a synthetic plaintext
+// instead of failing, so the first one tried would always "succeed".
+// Untagged ciphertexts for such keys must be decrypted with the key itself.
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	kid, payload, tagged := splitKeyID(ciphertext)
+	if tagged {
+		key, err := k.privateKey(kid)
+		if err != nil {
+			return "", err
+		}
+		return key.Decrypt(payload)
+	}
+
+	for _, key := range k.trialKeys(true) {
+		if plaintext, err := key.Decrypt(payload); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", errors.New("keyring: no key could decrypt the ciphertext")
+}
+
+// Sign signs data with the active key and returns
+// "<kid>.<base64 signature>".
+func (k *Keyring) Sign(data string) (string, error) {
+	kid, key, err := k.activeKey()
+	if err != nil {
+		return "", err
+	}
+	if !hasPrivateKey(key) {
+		return "", ErrPublicKeyOnly
+	}
+	signature, err := key.Sign(data)
+	if err != nil {
+		return "", err
+	}
+	return kid + keyIDSeparator + signature, nil
+}
+
+// Verify checks a signature produced by Sign. Signatures without a key ID
+// are accepted if any key in the keyring verifies them.
+func (k *Keyring) Verify(data, signature string) (bool, error) {
+	kid, payload, tagged := splitKeyID(signature)
+	if tagged {
+		key, ok := k.Get(kid)
+		if !ok {
+			return false, ErrUnknownKeyID
+		}
+		return key.Verify(data, payload)
+	}
+
+	for _, key := range k.trialKeys(false) {
+		if valid, err := key.Verify(data, payload); err == nil && valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// replace swaps in the keys and active key of other, which must not be
+// shared.
+func (k *Keyring) replace(other *Keyring) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys, k.order, k.active = other.keys, other.order, other.active
+}
+
+func (k *Keyring) activeKey() (string, *JSEncrypt, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.active == "" {
+		return "", nil, ErrNoActiveKey
+	}
+	return k.active, k.keys[k.active], nil
+}
+
+// privateKey returns the key with the given ID for decryption, failing with
+// ErrPublicKeyOnly if it has no private key.
+func (k *Keyring) privateKey(kid string) (*JSEncrypt, error) {
+	key, ok := k.Get(kid)
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if !hasPrivateKey(key) {
+		return nil, ErrPublicKeyOnly
+	}
+	return key, nil
+}
+
+// hasPrivateKey reports whether key holds a private key, in memory or
+// behind an external signer or decrypter.
+func hasPrivateKey(key *JSEncrypt) bool {
+	keys := key.keys()
+	return keys.privateKey != nil || keys.signer != nil || keys.decrypter != nil
+}
+
+// trialKeys returns the keys to try for untagged input, newest first so
+// recently rotated keys are tried before old ones. With decrypt only keys
+// able to decrypt, and to report a failure, are returned.
+func (k *Keyring) trialKeys(decrypt bool) []*JSEncrypt {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var keys []*JSEncrypt
+	for i := len(k.order) - 1; i >= 0; i-- {
+		key := k.keys[k.order[i]]
+		if decrypt && (!key.keys().canDecrypt() || key.ImplicitRejection) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// splitKeyID splits "<kid>.<payload>" into its parts. tagged is false when
+// the input has no key ID prefix.
+func splitKeyID(s string) (kid, payload string, tagged bool) {
+	kid, payload, tagged = strings.Cut(s, keyIDSeparator)
+	if !tagged {
+		return "", s, false
+	}
+	return kid, payload, true
+}
@@ -0,0 +1,231 @@
+package jsencrypt
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestKey(t *testing.T, privateKey string) *JSEncrypt {
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPrivateKey(privateKey); err != nil {
+		t.Fatal(err)
+	}
+	return jsCrypt
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	keyring := NewKeyring()
+	if _, err := keyring.Encrypt("msg"); !errors.Is(err, ErrNoActiveKey) {
+		t.Fatalf("Expected ErrNoActiveKey, got %v", err)
+	}
+	if _, err := keyring.Add(NewJSEncrypt()); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Adding an empty instance should fail with ErrNoKey, got %v", err)
+	}
+
+	oldKID, err := keyring.Add(newTestKey(t, testPrivateKeys[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCiphertext, err := keyring.Encrypt("encrypted before rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSignature, err := keyring.Sign("signed before rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(oldCiphertext, oldKID+".") || !strings.HasPrefix(oldSignature, oldKID+".") {
+		t.Errorf("Output should be prefixed with the key ID %s", oldKID)
+	}
+
+	// Rotate to a new key.
+	newKID, err := keyring.Add(newTestKey(t, exampleTestKeys.privateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.ActiveKeyID() != oldKID {
+		t.Error("Adding a key must not change the active key")
+	}
+	if err := keyring.SetActive(newKID); err != nil {
+		t.Fatal(err)
+	}
+	newCiphertext, err := keyring.Encrypt("encrypted after rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(newCiphertext, newKID+".") {
+		t.Errorf("Expected new ciphertext to use key %s", newKID)
+	}
+
+	for ciphertext, want := range map[string]string{
+		oldCiphertext: "encrypted before rotation",
+		newCiphertext: "encrypted after rotation",
+	} {
+		if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != want {
+			t.Errorf("Decrypt() = %q, %v; want %q", plaintext, err, want)
+		}
+	}
+	if valid, err := keyring.Verify("signed before rotation", oldSignature); err != nil || !valid {
+		t.Errorf("Old signature should still verify: %v", err)
+	}
+	if valid, _ := keyring.Verify("tampered", oldSignature); valid {
+		t.Error("Tampered data should not verify")
+	}
+
+	// Retiring the old key makes its output undecryptable.
+	keyring.Remove(oldKID)
+	if _, err := keyring.Decrypt(oldCiphertext); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Expected ErrUnknownKeyID after removal, got %v", err)
+	}
+	if ids := keyring.KeyIDs(); len(ids) != 1 || ids[0] != newKID {
+		t.Errorf("KeyIDs() = %v, want [%s]", ids, newKID)
+	}
+	if err := keyring.SetActive(oldKID); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Expected ErrUnknownKeyID, got %v", err)
+	}
+}
+
+func TestKeyring_LegacyInput(t *testing.T) {
+	legacy := newTestKey(t, testPrivateKeys[3])
+	ciphertext, err := legacy.Encrypt("no key id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := legacy.Sign("no key id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := NewKeyring()
+	for _, key := range []*JSEncrypt{legacy, newTestKey(t, exampleTestKeys.privateKey)} {
+		if _, err := keyring.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != "no key id" {
+		t.Errorf("Trial decryption failed: %q, %v", plaintext, err)
+	}
+	if valid, err := keyring.Verify("no key id", signature); err != nil || !valid {
+		t.Errorf("Trial verification failed: %v", err)
+	}
+
+	other := NewKeyring()
+	if _, err := other.Add(newTestKey(t, exampleTestKeys.privateKey)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Decrypt(ciphertext); err == nil {
+		t.Error("Decrypting with an unrelated keyring should fail")
+	}
+}
+
+func TestKeyring_TrialDecryptionSkipsImplicitRejection(t *testing.T) {
+	legacy := newTestKey(t, testPrivateKeys[3])
+	ciphertext, err := legacy.Encrypt("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := NewKeyring()
+	if _, err := keyring.Add(legacy); err != nil {
+		t.Fatal(err)
+	}
+	newer := newTestKey(t, exampleTestKeys.privateKey)
+	newer.ImplicitRejection = true
+	newKID, err := keyring.Add(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The newer key would return a synthetic plaintext; it must be skipped
+	// rather than win the trial.
+	if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != "legacy" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+	keyring.Remove(keyring.KeyIDs()[0])
+	if err := keyring.SetActive(newKID); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := keyring.Decrypt(ciphertext); err == nil {
+		t.Errorf("Trial decryption with an implicit rejection key returned %q", plaintext)
+	}
+
+	// Tagged ciphertexts still use implicit rejection.
+	tagged, err := keyring.Encrypt("tagged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := keyring.Decrypt(tagged); err != nil || plaintext != "tagged" {
+		t.Errorf("Decrypt(tagged) = %q, %v", plaintext, err)
+	}
+}
+
+func TestKeyring_PublicOnlyKey(t *testing.T) {
+	private := newTestKey(t, testPrivateKeys[3])
+	publicPEM, err := private.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := NewJSEncrypt()
+	if err := public.SetPublicKey(publicPEM); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := NewKeyring()
+	kid, err := keyring.Add(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := keyring.Encrypt("public only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither operation may generate a key pair in place of the public key.
+	if _, err := keyring.Decrypt(ciphertext); !errors.Is(err, ErrPublicKeyOnly) {
+		t.Errorf("Decrypt with a public-only key = %v, want ErrPublicKeyOnly", err)
+	}
+	if _, err := keyring.Sign("public only"); !errors.Is(err, ErrPublicKeyOnly) {
+		t.Errorf("Sign with a public-only key = %v, want ErrPublicKeyOnly", err)
+	}
+	if id, err := public.KeyID(); err != nil || id != kid {
+		t.Errorf("The public key was replaced: key ID %q, %v", id, err)
+	}
+
+	// Attaching the private half makes both work.
+	if _, err := keyring.Add(private); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != "public only" {
+		t.Errorf("Decrypt = %q, %v", plaintext, err)
+	}
+	if _, err := keyring.Sign("public only"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyring_Concurrent(t *testing.T) {
+	keyring := NewKeyring()
+	kid, err := keyring.Add(newTestKey(t, testPrivateKeys[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ciphertext, err := keyring.Encrypt("concurrent")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != "concurrent" {
+				t.Errorf("Decrypt() = %q, %v", plaintext, err)
+			}
+			_ = keyring.SetActive(kid)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,147 @@
+package jsencrypt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// keyFileExtensions are the file name extensions LoadKeyDir and WatchKeyDir
+// treat as keys. Other files, such as certificates or READMEs, are ignored.
+var keyFileExtensions = []string{".pem", ".key", ".pub", ".der"}
+
+// LoadKeyFile reads a PEM or DER encoded key from path. Any format accepted
+// by SetKey may be used.
+func LoadKeyFile(path string) (*JSEncrypt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j := NewJSEncrypt()
+	if err := j.setKeyData(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return j, nil
+}
+
+// LoadKeyFromEnv reads a key from the environment variable name. The value
+// may be PEM, with real newlines or the literal two character sequence \n as
+// many deployment tools produce, or base64 encoded PEM or DER.
+func LoadKeyFromEnv(name string) (*JSEncrypt, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	var data []byte
+	if strings.Contains(value, "-----BEGIN") {
+		data = []byte(strings.ReplaceAll(value, `\n`, "\n"))
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %s: neither PEM nor base64", name)
+		}
+		data = decoded
+	}
+
+	j := NewJSEncrypt()
+	if err := j.setKeyData(data); err != nil {
+		return nil, fmt.Errorf("environment variable %s: %w", name, err)
+	}
+	return j, nil
+}
+
+// LoadKeyDir loads every key file (*.pem, *.key, *.pub and *.der) in dir
+// into a Keyring. Hidden files are skipped. The key from the most recently
+// modified file becomes the active key, so a secrets agent can rotate keys by
+// writing a new file next to the old ones.
+func LoadKeyDir(dir string) (*Keyring, error) {
+	files, err := keyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return keyringFromFiles(files)
+}
+
+// keyringFromFiles builds a Keyring from key files sorted oldest first; the
+// last one becomes the active key.
+func keyringFromFiles(files []keyFile) (*Keyring, error) {
+	ring := NewKeyring()
+	var active string
+	for _, file := range files {
+		key := NewJSEncrypt()
+		if err := key.setKeyData(file.data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+		// Don't let a public key file replace the private key it belongs to.
+		kid := jwkThumbprint(key.keys().public())
+		if existing, ok := ring.Get(kid); ok && existing.keys().canDecrypt() && !key.keys().canDecrypt() {
+			active = kid
+			continue
+		}
+		if _, err := ring.Add(key); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+		active = kid
+	}
+	if active != "" {
+		if err := ring.SetActive(active); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+type keyFile struct {
+	path string
+	data []byte
+}
+
+// keyFiles returns the key files in dir with their contents, oldest first.
+func keyFiles(dir string) ([]keyFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		keyFile
+		modTime int64
+	}
+	var candidates []candidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !contains(keyFileExtensions, strings.ToLower(filepath.Ext(name))) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		// Stat follows symlinks, as used by Kubernetes secret volumes.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate{keyFile{path, data}, info.ModTime().UnixNano()})
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].modTime != candidates[b].modTime {
+			return candidates[a].modTime < candidates[b].modTime
+		}
+		return candidates[a].path < candidates[b].path
+	})
+	files := make([]keyFile, len(candidates))
+	for i, c := range candidates {
+		files[i] = c.keyFile
+	}
+	return files, nil
+}
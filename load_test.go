@@ -0,0 +1,116 @@
+package jsencrypt
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeyFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	pemPath := filepath.Join(dir, "key.pem")
+	writeKeyFile(t, pemPath, exampleTestKeys.privateKey, time.Now())
+
+	jsCrypt, err := LoadKeyFile(pemPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsCrypt.privateKey == nil {
+		t.Fatal("Expected a private key")
+	}
+
+	block, _ := pem.Decode([]byte(exampleTestKeys.publicKey))
+	derPath := filepath.Join(dir, "key.der")
+	writeKeyFile(t, derPath, string(block.Bytes), time.Now())
+	pub, err := LoadKeyFile(derPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !publicKeysEqual(pub.publicKey, jsCrypt.publicKey) {
+		t.Error("DER public key does not match the PEM private key")
+	}
+
+	if _, err := LoadKeyFile(filepath.Join(dir, "missing.pem")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+	writeKeyFile(t, filepath.Join(dir, "bad.pem"), "not a key", time.Now())
+	if _, err := LoadKeyFile(filepath.Join(dir, "bad.pem")); err == nil || !strings.Contains(err.Error(), "bad.pem") {
+		t.Errorf("Expected an error naming the file, got %v", err)
+	}
+}
+
+func TestLoadKeyFromEnv(t *testing.T) {
+	want := newTestKey(t, exampleTestKeys.privateKey)
+
+	for name, value := range map[string]string{
+		"pem":         exampleTestKeys.privateKey,
+		"escaped pem": strings.ReplaceAll(strings.TrimSpace(exampleTestKeys.privateKey), "\n", `\n`),
+		"base64 pem":  base64.StdEncoding.EncodeToString([]byte(exampleTestKeys.privateKey)),
+	} {
+		t.Setenv("JSENCRYPT_TEST_KEY", value)
+		jsCrypt, err := LoadKeyFromEnv("JSENCRYPT_TEST_KEY")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if jsCrypt.privateKey == nil || !publicKeysEqual(jsCrypt.publicKey, want.publicKey) {
+			t.Errorf("%s: loaded the wrong key", name)
+		}
+	}
+
+	t.Setenv("JSENCRYPT_TEST_KEY", "")
+	if _, err := LoadKeyFromEnv("JSENCRYPT_TEST_KEY"); err == nil {
+		t.Error("Expected an error for an empty variable")
+	}
+	t.Setenv("JSENCRYPT_TEST_KEY", "%%%")
+	if _, err := LoadKeyFromEnv("JSENCRYPT_TEST_KEY"); err == nil {
+		t.Error("Expected an error for a value that is neither PEM nor base64")
+	}
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeKeyFile(t, filepath.Join(dir, "2023.pem"), testPrivateKeys[3], now.Add(-2*time.Hour))
+	writeKeyFile(t, filepath.Join(dir, "2024.key"), exampleTestKeys.privateKey, now.Add(-time.Hour))
+	// The public half is newer than the private key, but must not replace it.
+	writeKeyFile(t, filepath.Join(dir, "2024.pub"), exampleTestKeys.publicKey, now)
+	writeKeyFile(t, filepath.Join(dir, ".hidden.pem"), "ignored", now)
+	writeKeyFile(t, filepath.Join(dir, "README.md"), "ignored", now)
+	if err := os.Mkdir(filepath.Join(dir, "sub.pem"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := LoadKeyDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := ring.KeyIDs(); len(ids) != 2 {
+		t.Fatalf("Expected 2 keys, got %v", ids)
+	}
+	want, _ := newTestKey(t, exampleTestKeys.privateKey).KeyID()
+	if ring.ActiveKeyID() != want {
+		t.Errorf("Expected the newest key to be active")
+	}
+	ciphertext, err := ring.Encrypt("from dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := ring.Decrypt(ciphertext); err != nil || plaintext != "from dir" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+}
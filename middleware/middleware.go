@@ -0,0 +1,454 @@
+// Package middleware provides net/http middleware for the canonical
+// JSEncrypt setup: a page fetches the server's public key, encrypts fields
+// such as a password in the browser, and the server decrypts them before
+// the application handler runs.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+const (
+	// DefaultPublicKeyPath serves the public key as PEM.
+	DefaultPublicKeyPath = "/jsencrypt/public-key.pem"
+	// DefaultJWKSPath serves the public keys as a JWK Set.
+	DefaultJWKSPath = "/jsencrypt/jwks.json"
+	// KeyIDHeader carries the key ID of the key served as PEM. Pages send
+	// "<key ID>.<ciphertext>" so the field is decrypted with that key.
+	KeyIDHeader = "JSEncrypt-Key-Id"
+
+	defaultMaxBodyBytes = 1 << 20
+	defaultMaxMemory    = 32 << 20
+)
+
+// FieldError reports a designated field that could not be decrypted.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return "middleware: decrypting field " + strconv.Quote(e.Field) + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Decryptor serves the public key and decrypts designated request fields.
+type Decryptor struct {
+	// Key decrypts fields and provides the served public key.
+	Key *jsencrypt.JSEncrypt
+	// Keys, if set, is used instead of Key: the active key is served as PEM
+	// and every key in the JWK Set. A field sent as "<key ID>.<ciphertext>"
+	// is decrypted with the named key, so pages loaded before a rotation
+	// keep working. A field without a key ID is decrypted with the active
+	// key or, with RejectInvalidPadding, tried against every key at the
+	// cost of one RSA decryption per key.
+	Keys *jsencrypt.Keyring
+	// RejectInvalidPadding makes a field with invalid PKCS#1 v1.5 padding
+	// fail the request through OnError. By default fields are decrypted
+	// with implicit rejection (JSEncrypt.DecryptImplicit): such a field
+	// decrypts to a pseudo-random value that the handler rejects like any
+	// other wrong input, so responses do not reveal whether the padding was
+	// valid, which would make the server a Bleichenbacher padding oracle.
+	// Implicit rejection needs an in-memory private key; keys held by an
+	// external decrypter require RejectInvalidPadding.
+	RejectInvalidPadding bool
+	// Fields lists the fields to decrypt: form field names, or JSON object
+	// members, with dots separating nested members ("user.password").
+	// Missing fields are left alone.
+	Fields []string
+	// PublicKeyPath and JWKSPath are where the public key is served.
+	// Defaults: DefaultPublicKeyPath and DefaultJWKSPath. Set to "-" to
+	// disable an endpoint.
+	PublicKeyPath string
+	JWKSPath      string
+	// MaxBodyBytes limits the size of request bodies that are decrypted.
+	// Default: 1 MiB.
+	MaxBodyBytes int64
+	// OnError writes the response when a field cannot be decrypted or the
+	// body cannot be parsed. Default: 400 Bad Request.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Wrap returns a handler that serves the key endpoints and decrypts the
+// designated fields of form (urlencoded and multipart) and JSON request
+// bodies before calling next. Only body fields are decrypted, never query
+// parameters, and the body is rewritten with the plaintext for handlers that
+// read it directly.
+func (d *Decryptor) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case d.path(d.PublicKeyPath, DefaultPublicKeyPath):
+			d.servePEM(w, r)
+			return
+		case d.path(d.JWKSPath, DefaultJWKSPath):
+			d.serveJWKS(w, r)
+			return
+		}
+
+		if err := d.decryptRequest(w, r); err != nil {
+			d.fail(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Decryptor) path(configured, def string) string {
+	switch configured {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return configured
+}
+
+func (d *Decryptor) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if d.OnError != nil {
+		d.OnError(w, r, err)
+		return
+	}
+	http.Error(w, "invalid encrypted field", http.StatusBadRequest)
+}
+
+// decrypt decrypts a single field value, "<key ID>.<ciphertext>" or a bare
+// ciphertext.
+func (d *Decryptor) decrypt(value string) (string, error) {
+	if d.Keys != nil && d.RejectInvalidPadding {
+		return d.Keys.Decrypt(value)
+	}
+	kid, ciphertext, tagged := strings.Cut(value, ".")
+	if !tagged {
+		ciphertext = value
+	}
+
+	var key *jsencrypt.JSEncrypt
+	switch {
+	case d.Keys != nil:
+		if !tagged {
+			kid = d.Keys.ActiveKeyID()
+		}
+		var ok bool
+		if key, ok = d.Keys.Get(kid); !ok {
+			return "", jsencrypt.ErrUnknownKeyID
+		}
+	case d.Key == nil:
+		return "", jsencrypt.ErrNoKey
+	default:
+		key = d.Key
+		if tagged {
+			if id, err := key.KeyID(); err != nil || id != kid {
+				return "", jsencrypt.ErrUnknownKeyID
+			}
+		}
+	}
+	if d.RejectInvalidPadding {
+		return key.Decrypt(ciphertext)
+	}
+	return key.DecryptImplicit(ciphertext)
+}
+
+// publicKey returns the key whose public half is served as PEM.
+func (d *Decryptor) publicKey() (*jsencrypt.JSEncrypt, error) {
+	if d.Keys != nil {
+		key, ok := d.Keys.Get(d.Keys.ActiveKeyID())
+		if !ok {
+			return nil, jsencrypt.ErrNoActiveKey
+		}
+		return key, nil
+	}
+	if d.Key == nil {
+		return nil, jsencrypt.ErrNoKey
+	}
+	return d.Key, nil
+}
+
+func (d *Decryptor) servePEM(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	key, err := d.publicKey()
+	if err != nil {
+		http.Error(w, "no key available", http.StatusServiceUnavailable)
+		return
+	}
+	pem, err := key.GetPublicKey()
+	if err != nil {
+		http.Error(w, "no key available", http.StatusServiceUnavailable)
+		return
+	}
+	kid, err := key.KeyID()
+	if err != nil {
+		http.Error(w, "no key available", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set(KeyIDHeader, kid)
+	writeKey(w, r, "application/x-pem-file", []byte(pem))
+}
+
+func (d *Decryptor) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	var keys []*jsencrypt.JSEncrypt
+	if d.Keys != nil {
+		for _, kid := range d.Keys.KeyIDs() {
+			if key, ok := d.Keys.Get(kid); ok {
+				keys = append(keys, key)
+			}
+		}
+	} else if d.Key != nil {
+		keys = append(keys, d.Key)
+	}
+
+	set := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{Keys: []json.RawMessage{}}
+	for _, key := range keys {
+		jwk, err := key.ExportPublicJWK()
+		if err != nil {
+			http.Error(w, "no key available", http.StatusServiceUnavailable)
+			return
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	body, err := json.Marshal(set)
+	if err != nil {
+		http.Error(w, "no key available", http.StatusServiceUnavailable)
+		return
+	}
+	writeKey(w, r, "application/jwk-set+json", body)
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// writeKey writes a public key response. Clients must revalidate so a
+// rotated key is picked up.
+func writeKey(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// decryptRequest replaces the designated fields of the request body with
+// their plaintext.
+func (d *Decryptor) decryptRequest(w http.ResponseWriter, r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || len(d.Fields) == 0 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	maxBytes := d.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		return d.decryptForm(r)
+	case mediaType == "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		return d.decryptMultipart(r, params["boundary"])
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		return d.decryptJSON(r)
+	}
+	return nil
+}
+
+func (d *Decryptor) decryptForm(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if err := d.decryptValues(r.PostForm); err != nil {
+		return err
+	}
+	setForm(r, r.PostForm)
+	// Replace the body too, for handlers that read it directly.
+	setBody(r, []byte(r.PostForm.Encode()))
+	return nil
+}
+
+func (d *Decryptor) decryptMultipart(r *http.Request, boundary string) error {
+	if err := r.ParseMultipartForm(defaultMaxMemory); err != nil {
+		return err
+	}
+	if err := d.decryptValues(r.MultipartForm.Value); err != nil {
+		return err
+	}
+	setForm(r, r.MultipartForm.Value)
+	body, err := encodeMultipart(r.MultipartForm, boundary)
+	if err != nil {
+		return err
+	}
+	setBody(r, body)
+	return nil
+}
+
+// decryptValues decrypts the designated fields of a form in place.
+func (d *Decryptor) decryptValues(values url.Values) error {
+	for _, field := range d.Fields {
+		for i, v := range values[field] {
+			plaintext, err := d.decrypt(v)
+			if err != nil {
+				return &FieldError{Field: field, Err: err}
+			}
+			values[field][i] = plaintext
+		}
+	}
+	return nil
+}
+
+// setForm rebuilds r.PostForm and r.Form from the decrypted body values.
+// r.Form holds the body values followed by the query parameters, as after
+// ParseForm; query parameters are never decrypted.
+func setForm(r *http.Request, body url.Values) {
+	r.PostForm = make(url.Values, len(body))
+	r.Form = make(url.Values, len(body))
+	for name, values := range body {
+		r.PostForm[name] = append([]string(nil), values...)
+		r.Form[name] = append([]string(nil), values...)
+	}
+	// ParseForm has already rejected an invalid query.
+	query, _ := url.ParseQuery(r.URL.RawQuery)
+	for name, values := range query {
+		r.Form[name] = append(r.Form[name], values...)
+	}
+}
+
+// encodeMultipart writes form back as a multipart body with the original
+// boundary, values first and then files, each sorted by field name.
+func encodeMultipart(form *multipart.Form, boundary string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(form.Value) {
+		for _, value := range form.Value[name] {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range sortedKeys(form.File) {
+		for _, fh := range form.File[name] {
+			part, err := writer.CreatePart(fh.Header)
+			if err != nil {
+				return nil, err
+			}
+			file, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(part, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *Decryptor) decryptJSON(r *http.Request) error {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	changed := false
+	for _, field := range d.Fields {
+		parent, name, ok := lookupJSON(doc, field)
+		if !ok {
+			continue
+		}
+		ciphertext, ok := parent[name].(string)
+		if !ok {
+			return &FieldError{Field: field, Err: errors.New("not a string")}
+		}
+		plaintext, err := d.decrypt(ciphertext)
+		if err != nil {
+			return &FieldError{Field: field, Err: err}
+		}
+		parent[name] = plaintext
+		changed = true
+	}
+	if changed {
+		if body, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	}
+	setBody(r, body)
+	return nil
+}
+
+// lookupJSON finds the object holding a dotted member path.
+func lookupJSON(doc any, path string) (map[string]any, string, bool) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return nil, "", false
+		}
+		doc = obj[name]
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil, "", false
+	}
+	if _, ok := obj[names[len(names)-1]]; !ok {
+		return nil, "", false
+	}
+	return obj, names[len(names)-1], true
+}
+
+func setBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
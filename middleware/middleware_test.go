@@ -0,0 +1,350 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+func encrypt(t *testing.T, key *jsencrypt.JSEncrypt, plaintext string) string {
+	ciphertext, err := key.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+// echo records what the application handler sees.
+type echo struct {
+	form url.Values
+	body string
+}
+
+func (e *echo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.body = string(body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ParseMultipartForm(1 << 20)
+	e.form = r.Form
+}
+
+func TestDecryptor_Form(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	app := &echo{}
+	handler := (&Decryptor{Key: key, Fields: []string{"password"}}).Wrap(app)
+
+	form := url.Values{"user": {"alice"}, "password": {encrypt(t, key, "s3cret+/=")}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := app.form.Get("password"); got != "s3cret+/=" {
+		t.Errorf("FormValue(password) = %q", got)
+	}
+	body, _ := url.ParseQuery(app.body)
+	if body.Get("password") != "s3cret+/=" || body.Get("user") != "alice" {
+		t.Errorf("Body seen by handler = %q", app.body)
+	}
+}
+
+func TestDecryptor_Multipart(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	app := &echo{}
+	handler := (&Decryptor{Key: key, Fields: []string{"pin"}}).Wrap(app)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("pin", encrypt(t, key, "1234"))
+	writer.WriteField("note", "plain")
+	writer.Close()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || app.form.Get("pin") != "1234" || app.form.Get("note") != "plain" {
+		t.Errorf("Status %d, form %v", rec.Code, app.form)
+	}
+}
+
+func TestDecryptor_JSON(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	app := &echo{}
+	handler := (&Decryptor{Key: key, Fields: []string{"password", "card.number", "absent.field"}}).Wrap(app)
+
+	payload := `{"user":"alice","password":"` + encrypt(t, key, "pw") + `","card":{"number":"` +
+		encrypt(t, key, "4111111111111111") + `","amount":12345678901234567890}}`
+	req := httptest.NewRequest("POST", "/pay", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Password string
+		Card     struct {
+			Number string
+			Amount json.Number
+		}
+	}
+	if err := json.Unmarshal([]byte(app.body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Password != "pw" || got.Card.Number != "4111111111111111" {
+		t.Errorf("Decrypted body = %s", app.body)
+	}
+	if got.Card.Amount != "12345678901234567890" {
+		t.Errorf("Large numbers must be preserved, got %s", got.Card.Amount)
+	}
+}
+
+func TestDecryptor_Failures(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	otherKey := testkeys.Key(t, 1)
+	app := &echo{}
+
+	var reported error
+	custom := &Decryptor{
+		Key:                  key,
+		Fields:               []string{"password"},
+		RejectInvalidPadding: true,
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			reported = err
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		},
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"wrong key", "application/x-www-form-urlencoded", "password=" + url.QueryEscape(encrypt(t, otherKey, "pw")), http.StatusBadRequest},
+		{"not base64", "application/x-www-form-urlencoded", "password=%%%", http.StatusBadRequest},
+		{"json number", "application/json", `{"password":1}`, http.StatusBadRequest},
+		{"invalid json", "application/json", `{"password":`, http.StatusBadRequest},
+		{"too large", "application/json", `{"password":"` + strings.Repeat("A", 2<<20) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		(&Decryptor{Key: key, Fields: []string{"password"}, RejectInvalidPadding: true}).Wrap(app).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	// By default invalid padding is not reported: the handler sees a
+	// pseudo-random value, exactly as for a valid ciphertext of a wrong
+	// password, so the response cannot serve as a padding oracle.
+	req := httptest.NewRequest("POST", "/login", strings.NewReader("password="+url.QueryEscape(encrypt(t, otherKey, "pw"))))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	(&Decryptor{Key: key, Fields: []string{"password"}}).Wrap(app).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || app.form.Get("password") == "pw" {
+		t.Errorf("Implicit rejection: status %d, password %q", rec.Code, app.form.Get("password"))
+	}
+
+	req = httptest.NewRequest("POST", "/login", strings.NewReader("password="+url.QueryEscape(encrypt(t, otherKey, "pw"))))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	custom.Wrap(app).ServeHTTP(rec, req)
+	var fieldErr *FieldError
+	if rec.Code != http.StatusUnprocessableEntity || !errors.As(reported, &fieldErr) || fieldErr.Field != "password" {
+		t.Errorf("Custom OnError: status %d, error %v", rec.Code, reported)
+	}
+	if !errors.Is(reported, jsencrypt.ErrDecryption) {
+		t.Errorf("FieldError should wrap ErrDecryption, got %v", reported)
+	}
+
+	// Requests without designated fields pass through untouched.
+	req = httptest.NewRequest("POST", "/other", strings.NewReader("a=b"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec = httptest.NewRecorder()
+	custom.Wrap(app).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || app.body != "a=b" {
+		t.Errorf("Pass-through: status %d, body %q", rec.Code, app.body)
+	}
+}
+
+func TestDecryptor_KeyEndpoints(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	pem, _ := key.GetPublicKey()
+	kid, _ := key.KeyID()
+	handler := (&Decryptor{Key: key, JWKSPath: "/keys"}).Wrap(http.NotFoundHandler())
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := get("GET", DefaultPublicKeyPath)
+	if rec.Code != http.StatusOK || rec.Body.String() != pem || rec.Header().Get("Content-Type") != "application/x-pem-file" {
+		t.Errorf("PEM endpoint: %d %q", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "PRIVATE") {
+		t.Fatal("The private key must never be served")
+	}
+
+	rec = get("GET", "/keys")
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("JWKS endpoint: %v (%s)", err, rec.Body)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0]["kid"] != kid || jwks.Keys[0]["d"] != "" {
+		t.Errorf("JWKS = %s", rec.Body)
+	}
+
+	if rec := get("HEAD", DefaultPublicKeyPath); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec := get("POST", DefaultPublicKeyPath); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", rec.Code)
+	}
+	if rec := get("GET", DefaultJWKSPath); rec.Code != http.StatusNotFound {
+		t.Errorf("Default JWKS path should not be served when overridden: %d", rec.Code)
+	}
+
+	disabled := (&Decryptor{Key: key, PublicKeyPath: "-"}).Wrap(http.NotFoundHandler())
+	rec = httptest.NewRecorder()
+	disabled.ServeHTTP(rec, httptest.NewRequest("GET", DefaultPublicKeyPath, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Disabled endpoint: %d", rec.Code)
+	}
+}
+
+func TestDecryptor_Keyring(t *testing.T) {
+	oldKey, newKey := testkeys.Key(t, 0), testkeys.Key(t, 1)
+	keyring := jsencrypt.NewKeyring()
+	keyring.Add(oldKey)
+	newKID, _ := keyring.Add(newKey)
+	if err := keyring.SetActive(newKID); err != nil {
+		t.Fatal(err)
+	}
+	app := &echo{}
+	handler := (&Decryptor{Keys: keyring, Fields: []string{"password"}}).Wrap(app)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", DefaultPublicKeyPath, nil))
+	if pem, _ := newKey.GetPublicKey(); rec.Body.String() != pem {
+		t.Error("The active key should be served")
+	}
+	if got := rec.Header().Get(KeyIDHeader); got != newKID {
+		t.Errorf("%s = %q, want %q", KeyIDHeader, got, newKID)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", DefaultJWKSPath, nil))
+	if n := strings.Count(rec.Body.String(), `"kty"`); n != 2 {
+		t.Errorf("JWKS should list both keys, got %d", n)
+	}
+
+	post := func(handler http.Handler, value string) (int, string) {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader("password="+url.QueryEscape(value)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, app.form.Get("password")
+	}
+
+	// A page loaded before the rotation still encrypts with the old key and
+	// names it.
+	oldKID, _ := oldKey.KeyID()
+	if code, password := post(handler, oldKID+"."+encrypt(t, oldKey, "pw")); code != http.StatusOK || password != "pw" {
+		t.Errorf("Old key: status %d, password %q", code, password)
+	}
+	if code, password := post(handler, encrypt(t, newKey, "pw")); code != http.StatusOK || password != "pw" {
+		t.Errorf("Active key without key ID: status %d, password %q", code, password)
+	}
+	if code, _ := post(handler, "unknown."+encrypt(t, newKey, "pw")); code != http.StatusBadRequest {
+		t.Errorf("Unknown key ID: status %d", code)
+	}
+
+	// Without a key ID only the active key is used, unless trial
+	// decryption is enabled with RejectInvalidPadding.
+	if _, password := post(handler, encrypt(t, oldKey, "pw")); password == "pw" {
+		t.Error("An untagged old ciphertext should not be tried against other keys by default")
+	}
+	trial := (&Decryptor{Keys: keyring, Fields: []string{"password"}, RejectInvalidPadding: true}).Wrap(app)
+	if code, password := post(trial, encrypt(t, oldKey, "pw")); code != http.StatusOK || password != "pw" {
+		t.Errorf("Trial decryption: status %d, password %q", code, password)
+	}
+}
+
+func TestDecryptor_BodyFieldsOnly(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	app := &echo{}
+	handler := (&Decryptor{Key: key, Fields: []string{"password"}}).Wrap(app)
+
+	ciphertext := encrypt(t, key, "pw")
+	query := url.Values{"password": {ciphertext}}.Encode()
+	req := httptest.NewRequest("POST", "/login?"+query, strings.NewReader(url.Values{"password": {ciphertext}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := app.form["password"]; len(got) != 2 || got[0] != "pw" || got[1] != ciphertext {
+		t.Errorf("Form[password] = %q, want the decrypted body value and the untouched query value", got)
+	}
+}
+
+func TestDecryptor_MultipartBody(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	var seen *multipart.Form
+	handler := (&Decryptor{Key: key, Fields: []string{"pin"}}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read the rewritten body directly rather than the parsed form.
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		seen = form
+	}))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("pin", encrypt(t, key, "1234"))
+	file, _ := writer.CreateFormFile("doc", "doc.txt")
+	file.Write([]byte("attachment"))
+	writer.Close()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen == nil {
+		t.Fatal("Handler did not run")
+	}
+	if got := seen.Value["pin"]; len(got) != 1 || got[0] != "1234" {
+		t.Errorf("pin in the body = %q", got)
+	}
+	if fhs := seen.File["doc"]; len(fhs) != 1 || fhs[0].Filename != "doc.txt" {
+		t.Fatalf("File parts = %v", seen.File)
+	} else if f, err := fhs[0].Open(); err != nil {
+		t.Error(err)
+	} else if data, _ := io.ReadAll(f); string(data) != "attachment" {
+		t.Errorf("File content = %q", data)
+	}
+}
@@ -0,0 +1,112 @@
+package jsencrypt
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers nonces to detect replayed messages. Implementations
+// backed by a shared cache, such as Redis SET NX with an expiry, allow
+// several servers to share one store.
+type NonceStore interface {
+	// CheckAndStore records nonce until expires and reports whether it was
+	// unseen. It must be atomic: of two concurrent calls with the same
+	// nonce, only one may report true.
+	CheckAndStore(nonce string, expires time.Time) (bool, error)
+}
+
+// MemoryNonceStore is an in-memory NonceStore holding up to a fixed number
+// of nonces. Expired nonces are dropped first; when the store is full of
+// unexpired nonces the least recently used one is evicted. A replay of an
+// evicted nonce could then go unnoticed, so from that point on the store
+// reports every nonce expiring no later than the evicted one as seen: its
+// retention window shrinks to what the capacity can hold, and messages
+// older than that are rejected rather than risk a replay. Anyone able to
+// produce sealed messages can force this by flooding the store, so size the
+// capacity above the number of messages expected within OpenOptions.MaxAge.
+//
+// A MemoryNonceStore is safe for concurrent use.
+type MemoryNonceStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List // front is most recently used
+	// horizon is the latest expiry of an evicted unexpired nonce. Nonces
+	// expiring no later than it may have been evicted and are not trusted.
+	horizon time.Time
+	now     func() time.Time
+}
+
+type nonceEntry struct {
+	nonce   string
+	expires time.Time
+}
+
+// NewMemoryNonceStore returns a store holding at most capacity nonces.
+func NewMemoryNonceStore(capacity int) *MemoryNonceStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryNonceStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// CheckAndStore implements NonceStore. It never returns an error.
+func (s *MemoryNonceStore) CheckAndStore(nonce string, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elem, ok := s.entries[nonce]; ok {
+		if entry := elem.Value.(*nonceEntry); entry.expires.After(now) {
+			s.lru.MoveToFront(elem)
+			return false, nil
+		}
+		s.remove(elem)
+	}
+	if !expires.After(s.horizon) {
+		return false, nil
+	}
+
+	if s.lru.Len() >= s.capacity {
+		s.evict(now)
+	}
+	s.entries[nonce] = s.lru.PushFront(&nonceEntry{nonce: nonce, expires: expires})
+	return true, nil
+}
+
+// Len returns the number of nonces currently held.
+func (s *MemoryNonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// evict drops all expired nonces, or the least recently used one if none
+// has expired, moving the horizon up to its expiry.
+func (s *MemoryNonceStore) evict(now time.Time) {
+	for elem := s.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if !elem.Value.(*nonceEntry).expires.After(now) {
+			s.remove(elem)
+		}
+		elem = prev
+	}
+	if s.lru.Len() >= s.capacity {
+		elem := s.lru.Back()
+		if expires := elem.Value.(*nonceEntry).expires; expires.After(s.horizon) {
+			s.horizon = expires
+		}
+		s.remove(elem)
+	}
+}
+
+func (s *MemoryNonceStore) remove(elem *list.Element) {
+	delete(s.entries, elem.Value.(*nonceEntry).nonce)
+	s.lru.Remove(elem)
+}
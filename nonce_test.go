@@ -0,0 +1,79 @@
+package jsencrypt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryNonceStore(3)
+	store.now = func() time.Time { return now }
+	expires := now.Add(time.Minute)
+
+	if fresh, _ := store.CheckAndStore("a", expires); !fresh {
+		t.Error("First use of a nonce should be fresh")
+	}
+	if fresh, _ := store.CheckAndStore("a", expires); fresh {
+		t.Error("Second use of a nonce should be a replay")
+	}
+
+	// Expired nonces are forgotten and evicted first.
+	store.CheckAndStore("short", now.Add(time.Second))
+	store.CheckAndStore("b", expires)
+	now = now.Add(2 * time.Second)
+	store.CheckAndStore("c", expires)
+	if store.Len() != 3 {
+		t.Errorf("Expected the expired nonce to be evicted, have %d entries", store.Len())
+	}
+	if fresh, _ := store.CheckAndStore("a", expires); fresh {
+		t.Error("Unexpired nonce should still be remembered")
+	}
+
+	// With no expired entries, the least recently used nonce goes: "a" was
+	// just used, so "b" is evicted.
+	store.CheckAndStore("d", expires)
+	if fresh, _ := store.CheckAndStore("a", expires); fresh {
+		t.Error("Recently used nonce should not be evicted")
+	}
+	if store.Len() != 3 {
+		t.Errorf("Store should stay at capacity, have %d entries", store.Len())
+	}
+
+	// "b" was evicted, so neither it nor any nonce expiring no later than it
+	// can be trusted to be fresh; later nonces still are.
+	if fresh, _ := store.CheckAndStore("b", expires); fresh {
+		t.Error("An evicted nonce must not be accepted again")
+	}
+	if fresh, _ := store.CheckAndStore("e", expires); fresh {
+		t.Error("A nonce inside the evicted window must be rejected")
+	}
+	if fresh, _ := store.CheckAndStore("e", expires.Add(time.Second)); !fresh {
+		t.Error("A nonce expiring after the evicted window should be fresh")
+	}
+}
+
+func TestMemoryNonceStore_Concurrent(t *testing.T) {
+	store := NewMemoryNonceStore(1000)
+	expires := time.Now().Add(time.Minute)
+
+	var fresh int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if ok, _ := store.CheckAndStore("shared", expires); ok {
+				atomic.AddInt32(&fresh, 1)
+			}
+			store.CheckAndStore(fmt.Sprint(i), expires)
+		}(i)
+	}
+	wg.Wait()
+	if fresh != 1 {
+		t.Errorf("Exactly one concurrent use should be fresh, got %d", fresh)
+	}
+}
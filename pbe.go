@@ -0,0 +1,366 @@
+package jsencrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Password based encryption used by PKCS#12 bundles and encrypted PKCS#8 keys.
+// Two families are supported: the PKCS#12 "pbeWithSHAAnd3-KeyTripleDES-CBC"
+// scheme (RFC 7292, Appendix B/C) and PBES2 with PBKDF2 (RFC 8018).
+
+var (
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA224 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 4}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// errIncorrectPassword is returned when decryption or MAC verification fails
+// in a way that is indistinguishable from a wrong password.
+var errIncorrectPassword = errors.New("pkcs12: decryption password incorrect")
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// hashForDigestOID maps a digest algorithm identifier to its constructor.
+func hashForDigestOID(oid asn1.ObjectIdentifier) (func() hash.Hash, bool) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return sha1.New, true
+	case oid.Equal(oidSHA224):
+		return sha256.New224, true
+	case oid.Equal(oidSHA256):
+		return sha256.New, true
+	case oid.Equal(oidSHA384):
+		return sha512.New384, true
+	case oid.Equal(oidSHA512):
+		return sha512.New, true
+	}
+	return nil, false
+}
+
+// hashForHMACOID maps a PBKDF2 PRF identifier to its hash constructor.
+// An absent PRF defaults to HMAC-SHA1.
+func hashForHMACOID(oid asn1.ObjectIdentifier) (func() hash.Hash, bool) {
+	switch {
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		return sha1.New, true
+	case oid.Equal(oidHMACWithSHA224):
+		return sha256.New224, true
+	case oid.Equal(oidHMACWithSHA256):
+		return sha256.New, true
+	case oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, true
+	case oid.Equal(oidHMACWithSHA512):
+		return sha512.New, true
+	}
+	return nil, false
+}
+
+// bmpPassword encodes a password as a NUL terminated big-endian UTF-16
+// string, as required by the PKCS#12 key derivation function.
+func bmpPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	out := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF implements the key derivation function from RFC 7292,
+// Appendix B.2. id selects key material (1), IV (2) or MAC key (3).
+func pkcs12KDF(h func() hash.Hash, salt, password []byte, iterations int, id byte, size int) []byte {
+	hf := h()
+	u := hf.Size()
+	v := hf.BlockSize()
+
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(src)+v-1)/v))
+		for i := range out {
+			out[i] = src[i%len(src)]
+		}
+		return out
+	}
+
+	d := bytes.Repeat([]byte{id}, v)
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		hf.Reset()
+		hf.Write(d)
+		hf.Write(in)
+		a := hf.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			hf.Reset()
+			hf.Write(a)
+			a = hf.Sum(a[:0])
+		}
+		out = append(out, a...)
+		if len(out) >= size {
+			break
+		}
+
+		b := make([]byte, v)
+		for i := range b {
+			b[i] = a[i%u]
+		}
+		// I_j = (I_j + B + 1) mod 2^(8v) for each v-byte block of I.
+		for j := 0; j < len(in); j += v {
+			carry := uint16(1)
+			for k := v - 1; k >= 0; k-- {
+				carry += uint16(in[j+k]) + uint16(b[k])
+				in[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// pbkdf2Key implements PBKDF2 from RFC 8018, section 5.2.
+func pbkdf2Key(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	hLen := prf.Size()
+	blocks := (keyLen + hLen - 1) / hLen
+
+	out := make([]byte, 0, blocks*hLen)
+	var buf [4]byte
+	for block := 1; block <= blocks; block++ {
+		buf[0], buf[1], buf[2], buf[3] = byte(block>>24), byte(block>>16), byte(block>>8), byte(block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(buf[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+// pbeDecrypt decrypts data protected with one of the supported password
+// based encryption schemes.
+func pbeDecrypt(alg pkix.AlgorithmIdentifier, password string, data []byte) ([]byte, error) {
+	block, iv, err := pbeCipher(alg, password)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("pkcs12: invalid encrypted data length")
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	return pkcs7Unpad(out, block.BlockSize())
+}
+
+// maxPBEIterations caps the key derivation and MAC iteration counts read
+// from untrusted files, so a crafted bundle cannot stall the loader. It is
+// well above the OpenSSL default of 2048 and current PBKDF2 guidance.
+const maxPBEIterations = 1 << 21
+
+func checkIterations(n int) error {
+	if n < 1 || n > maxPBEIterations {
+		return errors.New("pkcs12: iteration count " + strconv.Itoa(n) + " out of range")
+	}
+	return nil
+}
+
+// pbeEncrypt encrypts data with the given password based encryption scheme.
+func pbeEncrypt(alg pkix.AlgorithmIdentifier, password string, data []byte) ([]byte, error) {
+	block, iv, err := pbeCipher(alg, password)
+	if err != nil {
+		return nil, err
+	}
+
+	padded := pkcs7Pad(data, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	return padded, nil
+}
+
+// pbeCipher derives the block cipher and IV for a PBE algorithm identifier.
+func pbeCipher(alg pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		var params pbeParams
+		if err := unmarshalParams(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, nil, err
+		}
+		if err := checkIterations(params.Iterations); err != nil {
+			return nil, nil, err
+		}
+		pw := bmpPassword(password)
+		key := pkcs12KDF(sha1.New, params.Salt, pw, params.Iterations, 1, 24)
+		iv := pkcs12KDF(sha1.New, params.Salt, pw, params.Iterations, 2, 8)
+		block, err := des.NewTripleDESCipher(key)
+		return block, iv, err
+
+	case alg.Algorithm.Equal(oidPBES2):
+		var params pbes2Params
+		if err := unmarshalParams(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, nil, err
+		}
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, nil, errors.New("pkcs12: unsupported PBES2 key derivation function " + params.KeyDerivationFunc.Algorithm.String())
+		}
+		var kdf pbkdf2Params
+		if err := unmarshalParams(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, nil, err
+		}
+		prf, ok := hashForHMACOID(kdf.PRF.Algorithm)
+		if !ok {
+			return nil, nil, errors.New("pkcs12: unsupported PBKDF2 PRF " + kdf.PRF.Algorithm.String())
+		}
+
+		var iv []byte
+		if err := unmarshalParams(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, nil, err
+		}
+
+		var keyLen int
+		var newCipher func([]byte) (cipher.Block, error)
+		switch scheme := params.EncryptionScheme.Algorithm; {
+		case scheme.Equal(oidAES128CBC):
+			keyLen, newCipher = 16, aes.NewCipher
+		case scheme.Equal(oidAES192CBC):
+			keyLen, newCipher = 24, aes.NewCipher
+		case scheme.Equal(oidAES256CBC):
+			keyLen, newCipher = 32, aes.NewCipher
+		case scheme.Equal(oidDESEDE3CBC):
+			keyLen, newCipher = 24, des.NewTripleDESCipher
+		default:
+			return nil, nil, errors.New("pkcs12: unsupported PBES2 encryption scheme " + scheme.String())
+		}
+		if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+			return nil, nil, errors.New("pkcs12: PBKDF2 key length does not match encryption scheme")
+		}
+
+		if err := checkIterations(kdf.IterationCount); err != nil {
+			return nil, nil, err
+		}
+		key := pbkdf2Key(prf, []byte(password), kdf.Salt, kdf.IterationCount, keyLen)
+		block, err := newCipher(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(iv) != block.BlockSize() {
+			return nil, nil, errors.New("pkcs12: invalid PBES2 IV length")
+		}
+		return block, iv, nil
+	}
+	return nil, nil, errors.New("pkcs12: unsupported encryption algorithm " + alg.Algorithm.String())
+}
+
+// newPBES2Algorithm returns a PBES2 algorithm identifier using
+// PBKDF2-HMAC-SHA256 and AES-256-CBC with the given salt and IV.
+func newPBES2Algorithm(salt, iv []byte, iterations int) (pkix.AlgorithmIdentifier, error) {
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	ivBytes, err := asn1.Marshal(iv)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivBytes}},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, nil
+}
+
+func unmarshalParams(der []byte, out interface{}) error {
+	rest, err := asn1.Unmarshal(der, out)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("pkcs12: trailing data")
+	}
+	return nil
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	out := make([]byte, len(data), len(data)+n)
+	copy(out, data)
+	return append(out, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errIncorrectPassword
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize {
+		return nil, errIncorrectPassword
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, errIncorrectPassword
+		}
+	}
+	return data[:len(data)-n], nil
+}
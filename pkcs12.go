@@ -0,0 +1,455 @@
+package jsencrypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+)
+
+// PKCS#12 (RFC 7292) support. Only the pieces needed to move an RSA key and
+// its certificate chain in and out of .p12/.pfx files are implemented:
+// password integrity mode (HMAC), plain and encrypted SafeContents, key,
+// shrouded key and X.509 certificate bags.
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+
+	oidCertTypeX509Certificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+)
+
+// pkcs12Iterations is the iteration count used for key derivation when
+// exporting, matching the OpenSSL default.
+const pkcs12Iterations = 2048
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// SetKeyPKCS12 loads the RSA private key and certificate chain from a
+// DER encoded PKCS#12 (.p12/.pfx) bundle protected by password.
+//
+// Shrouded key bags and encrypted certificate bags may use either
+// pbeWithSHAAnd3-KeyTripleDES-CBC or PBES2 (PBKDF2 with AES-CBC or
+// DES-EDE3-CBC). The certificate matching the private key is returned
+// first by Certificates, followed by the remaining certificates in file order.
+// Iteration counts above 2,097,152 are rejected.
+func (j *JSEncrypt) SetKeyPKCS12(data []byte, password string) error {
+	bags, err := decodePKCS12(data, password)
+	if err != nil {
+		return err
+	}
+
+	var priv *rsa.PrivateKey
+	var certs []*x509.Certificate
+	for _, bag := range bags {
+		switch {
+		case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+			if priv != nil {
+				return errors.New("pkcs12: bundle contains more than one private key")
+			}
+			der := bag.Value.Bytes
+			if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+				var info encryptedPrivateKeyInfo
+				if err := unmarshalParams(bag.Value.Bytes, &info); err != nil {
+					return err
+				}
+				if der, err = pbeDecrypt(info.Algorithm, password, info.EncryptedData); err != nil {
+					return err
+				}
+			}
+			key, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return err
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return errors.New("pkcs12: private key is not an RSA key")
+			}
+			priv = rsaKey
+
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			if err := unmarshalParams(bag.Value.Bytes, &cb); err != nil {
+				return err
+			}
+			if !cb.ID.Equal(oidCertTypeX509Certificate) {
+				continue
+			}
+			cert, err := x509.ParseCertificate(cb.Data)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if priv == nil {
+		return errors.New("pkcs12: bundle does not contain a private key")
+	}
+
+	certs = leafFirst(certs, &priv.PublicKey)
+	return j.setKeys(keyState{privateKey: priv, publicKey: &priv.PublicKey, certificates: certs})
+}
+
+// leafFirst moves the certificate for pub to the front of certs, keeping the
+// others in their original order.
+func leafFirst(certs []*x509.Certificate, pub *rsa.PublicKey) []*x509.Certificate {
+	for i, cert := range certs {
+		if certPub, ok := cert.PublicKey.(*rsa.PublicKey); ok && publicKeysEqual(certPub, pub) {
+			copy(certs[1:i+1], certs[:i])
+			certs[0] = cert
+			break
+		}
+	}
+	return certs
+}
+
+// Certificates returns the certificate chain loaded by SetKeyPKCS12, leaf
+// certificate first. It returns nil when no certificates were loaded.
+func (j *JSEncrypt) Certificates() []*x509.Certificate {
+	return j.keys().certificates
+}
+
+// GetPKCS12 encodes the private key and certificates as a DER encoded
+// PKCS#12 bundle protected by password. When no certificates are given, the
+// chain loaded by SetKeyPKCS12 is used; the first certificate is treated as
+// the leaf and must match the private key.
+//
+// Key and certificate bags are encrypted with PBES2 (PBKDF2-HMAC-SHA256,
+// AES-256-CBC) and the bundle is authenticated with HMAC-SHA256, which is
+// the OpenSSL 3 default and is readable by current Java and Windows releases.
+func (j *JSEncrypt) GetPKCS12(password string, certs ...*x509.Certificate) ([]byte, error) {
+	priv, release, err := j.getKey()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if len(certs) == 0 {
+		certs = j.Certificates()
+	}
+
+	var localKeyID []byte
+	if len(certs) > 0 {
+		pub, ok := certs[0].PublicKey.(*rsa.PublicKey)
+		if !ok || !publicKeysEqual(pub, &priv.PublicKey) {
+			return nil, errors.New("pkcs12: certificate does not match the private key")
+		}
+		sum := sha1.Sum(certs[0].Raw)
+		localKeyID = sum[:]
+	}
+
+	// Certificates go into an encrypted SafeContents.
+	var certBags []safeBag
+	for i, cert := range certs {
+		cb, err := asn1.Marshal(certBag{ID: oidCertTypeX509Certificate, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		bag := safeBag{ID: oidCertBag, Value: explicitContent(cb)}
+		if i == 0 {
+			if bag.Attributes, err = localKeyIDAttributes(localKeyID); err != nil {
+				return nil, err
+			}
+		}
+		certBags = append(certBags, bag)
+	}
+
+	// The private key goes into a shrouded key bag inside a plain SafeContents.
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	keyAlg, err := randomPBES2Algorithm()
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := pbeEncrypt(keyAlg, password, pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	shrouded, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: keyAlg, EncryptedData: encKey})
+	if err != nil {
+		return nil, err
+	}
+	keyBag := safeBag{ID: oidPKCS8ShroudedKeyBag, Value: explicitContent(shrouded)}
+	if localKeyID != nil {
+		if keyBag.Attributes, err = localKeyIDAttributes(localKeyID); err != nil {
+			return nil, err
+		}
+	}
+
+	var authSafe []contentInfo
+	if len(certBags) > 0 {
+		ci, err := encryptedSafeContents(certBags, password)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, ci)
+	}
+	ci, err := plainSafeContents([]safeBag{keyBag})
+	if err != nil {
+		return nil, err
+	}
+	authSafe = append(authSafe, ci)
+
+	authSafeDER, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	mac := pkcs12MAC(sha256.New, salt, password, pkcs12Iterations, authSafeDER)
+
+	authSafeOctets, err := asn1.Marshal(authSafeDER)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicitContent(authSafeOctets)},
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+				Digest:    mac,
+			},
+			MacSalt:    salt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+// decodePKCS12 verifies the MAC of a PFX and returns all of its safe bags.
+func decodePKCS12(data []byte, password string) ([]safeBag, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var pfx pfxPdu
+	if err := unmarshalParams(der, &pfx); err != nil {
+		return nil, err
+	}
+	if pfx.Version != 3 {
+		return nil, errors.New("pkcs12: unsupported version")
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, errors.New("pkcs12: only password integrity mode is supported")
+	}
+
+	var authSafeDER []byte
+	if err := unmarshalParams(pfx.AuthSafe.Content.Bytes, &authSafeDER); err != nil {
+		return nil, err
+	}
+
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) == 0 {
+		return nil, errors.New("pkcs12: bundle has no MAC")
+	}
+	h, ok := hashForDigestOID(pfx.MacData.Mac.Algorithm.Algorithm)
+	if !ok {
+		return nil, errors.New("pkcs12: unsupported MAC algorithm " + pfx.MacData.Mac.Algorithm.Algorithm.String())
+	}
+	if err := checkIterations(pfx.MacData.Iterations); err != nil {
+		return nil, err
+	}
+	expected := pkcs12MAC(h, pfx.MacData.MacSalt, password, pfx.MacData.Iterations, authSafeDER)
+	if !hmac.Equal(expected, pfx.MacData.Mac.Digest) {
+		return nil, errIncorrectPassword
+	}
+
+	var authSafe []contentInfo
+	if err := unmarshalParams(authSafeDER, &authSafe); err != nil {
+		return nil, err
+	}
+
+	var bags []safeBag
+	for _, ci := range authSafe {
+		var contents []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if err := unmarshalParams(ci.Content.Bytes, &contents); err != nil {
+				return nil, err
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if err := unmarshalParams(ci.Content.Bytes, &ed); err != nil {
+				return nil, err
+			}
+			encrypted, err := implicitOctets(ed.EncryptedContentInfo.EncryptedContent)
+			if err != nil {
+				return nil, err
+			}
+			if contents, err = pbeDecrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, password, encrypted); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("pkcs12: unsupported content type " + ci.ContentType.String())
+		}
+
+		var safeContents []safeBag
+		if err := unmarshalParams(contents, &safeContents); err != nil {
+			return nil, err
+		}
+		bags = append(bags, safeContents...)
+	}
+	return bags, nil
+}
+
+// pkcs12MAC computes the PFX integrity HMAC over the AuthenticatedSafe.
+func pkcs12MAC(h func() hash.Hash, salt []byte, password string, iterations int, data []byte) []byte {
+	key := pkcs12KDF(h, salt, bmpPassword(password), iterations, 3, h().Size())
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// implicitOctets returns the content of an [0] IMPLICIT OCTET STRING, which
+// BER encoders may split into constructed segments.
+func implicitOctets(v asn1.RawValue) ([]byte, error) {
+	if !v.IsCompound {
+		return v.Bytes, nil
+	}
+	var out []byte
+	rest := v.Bytes
+	for len(rest) > 0 {
+		var segment []byte
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &segment); err != nil {
+			return nil, err
+		}
+		out = append(out, segment...)
+	}
+	return out, nil
+}
+
+// explicitContent wraps DER in a [0] EXPLICIT context specific tag.
+func explicitContent(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func localKeyIDAttributes(id []byte) ([]pkcs12Attribute, error) {
+	value, err := asn1.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	return []pkcs12Attribute{{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+	}}, nil
+}
+
+func randomPBES2Algorithm() (pkix.AlgorithmIdentifier, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	return newPBES2Algorithm(salt, iv, pkcs12Iterations)
+}
+
+func plainSafeContents(bags []safeBag) (contentInfo, error) {
+	der, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	octets, err := asn1.Marshal(der)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicitContent(octets)}, nil
+}
+
+func encryptedSafeContents(bags []safeBag, password string) (contentInfo, error) {
+	der, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	alg, err := randomPBES2Algorithm()
+	if err != nil {
+		return contentInfo{}, err
+	}
+	encrypted, err := pbeEncrypt(alg, password, der)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	ed, err := asn1.Marshal(encryptedData{
+		Version: 0,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: alg,
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: encrypted},
+		},
+	})
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidEncryptedDataContentType, Content: explicitContent(ed)}, nil
+}
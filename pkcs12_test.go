@@ -0,0 +1,158 @@
+package jsencrypt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// Fixtures in testdata/pkcs12 were generated with OpenSSL 3:
+//
+//	openssl pkcs12 -export -inkey key.pem -in cert.pem -certfile ca.pem \
+//	    -passout pass:changeit -out aes256.p12
+//	openssl pkcs12 -export -inkey key.pem -in cert.pem -certfile ca.pem \
+//	    -passout pass:changeit -keypbe PBE-SHA1-3DES -certpbe PBE-SHA1-3DES \
+//	    -macalg sha1 -out 3des.p12
+const pkcs12TestPassword = "changeit"
+
+func TestJSEncrypt_SetKeyPKCS12(t *testing.T) {
+	for _, name := range []string{"aes256.p12", "3des.p12"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/pkcs12/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jsCrypt := NewJSEncrypt()
+			if err := jsCrypt.SetKeyPKCS12(data, pkcs12TestPassword); err != nil {
+				t.Fatalf("Failed to load PKCS#12 bundle: %v", err)
+			}
+
+			certs := jsCrypt.Certificates()
+			if len(certs) != 2 {
+				t.Fatalf("Expected 2 certificates, got %d", len(certs))
+			}
+			if certs[0].Subject.CommonName != "go-jsencrypt test leaf" {
+				t.Errorf("Leaf certificate should come first, got %q", certs[0].Subject.CommonName)
+			}
+			if certs[1].Subject.CommonName != "go-jsencrypt test CA" {
+				t.Errorf("Unexpected CA certificate %q", certs[1].Subject.CommonName)
+			}
+
+			// The loaded key must be usable and match the leaf certificate.
+			verifier := NewJSEncrypt()
+			verifier.publicKey = certs[0].PublicKey.(*rsa.PublicKey)
+
+			signature, err := jsCrypt.Sign("pkcs12")
+			if err != nil {
+				t.Fatalf("Signing failed: %v", err)
+			}
+			valid, err := verifier.Verify("pkcs12", signature)
+			if err != nil || !valid {
+				t.Errorf("Signature from PKCS#12 key did not verify with certificate key: %v", err)
+			}
+		})
+	}
+}
+
+func TestJSEncrypt_SetKeyPKCS12WrongPassword(t *testing.T) {
+	data, err := os.ReadFile("testdata/pkcs12/aes256.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetKeyPKCS12(data, "wrong"); err == nil {
+		t.Error("Should have failed with the wrong password")
+	}
+	if err := jsCrypt.SetKeyPKCS12([]byte("not a pfx"), pkcs12TestPassword); err == nil {
+		t.Error("Should have failed to parse garbage input")
+	}
+}
+
+func TestJSEncrypt_GetPKCS12(t *testing.T) {
+	src := NewJSEncrypt()
+	priv, release, err := src.getKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "round trip"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pfx, err := src.GetPKCS12("secret", cert)
+	if err != nil {
+		t.Fatalf("Failed to export PKCS#12: %v", err)
+	}
+
+	dest := NewJSEncrypt()
+	if err := dest.SetKeyPKCS12(pfx, "secret"); err != nil {
+		t.Fatalf("Failed to load exported PKCS#12: %v", err)
+	}
+	if len(dest.Certificates()) != 1 || dest.Certificates()[0].Subject.CommonName != "round trip" {
+		t.Errorf("Certificate not preserved in exported bundle")
+	}
+
+	msg := "PKCS#12 round trip"
+	encrypted, err := src.Encrypt(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := dest.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decryption with imported key failed: %v", err)
+	}
+	if decrypted != msg {
+		t.Errorf("Decrypted message doesn't match. Got %s, want %s", decrypted, msg)
+	}
+
+	// A certificate for a different key must be rejected.
+	other := NewJSEncrypt()
+	if _, err := other.GetPKCS12("secret", cert); err == nil {
+		t.Error("Should have rejected a certificate that does not match the key")
+	}
+}
+
+func TestLeafFirst(t *testing.T) {
+	certs := make([]*x509.Certificate, 4)
+	for i := range certs {
+		pub := &rsa.PublicKey{N: big.NewInt(int64(1000 + i)), E: 3}
+		certs[i] = &x509.Certificate{PublicKey: pub, SerialNumber: big.NewInt(int64(i))}
+	}
+	// The leaf is last; the other certificates must keep their order.
+	leaf := &rsa.PublicKey{N: big.NewInt(1000), E: 3}
+	got := leafFirst([]*x509.Certificate{certs[1], certs[2], certs[3], certs[0]}, leaf)
+	for i, cert := range got {
+		if cert.SerialNumber.Int64() != int64(i) {
+			t.Fatalf("leafFirst order: position %d has certificate %d", i, cert.SerialNumber.Int64())
+		}
+	}
+}
+
+func TestPBEIterationLimit(t *testing.T) {
+	alg, err := newPBES2Algorithm(make([]byte, 8), make([]byte, 16), maxPBEIterations+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pbeDecrypt(alg, "password", make([]byte, 16)); err == nil {
+		t.Error("pbeDecrypt accepted an iteration count above the limit")
+	}
+}
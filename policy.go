@@ -0,0 +1,149 @@
+package jsencrypt
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// ErrPolicyViolation is matched by errors.Is for every error returned when an
+// operation is rejected by a Policy.
+var ErrPolicyViolation = errors.New("rejected by key policy")
+
+// Policy restricts the keys and algorithms a JSEncrypt instance will use.
+// It is checked on every encrypt, decrypt, sign and verify operation. A nil
+// Policy (the default) permits everything, as earlier versions did.
+//
+// Separate modulus minimums allow existing data protected with older,
+// smaller keys to be decrypted and verified while refusing to produce new
+// ciphertexts and signatures with them.
+type Policy struct {
+	// MinEncryptSignBits is the minimum modulus size for Encrypt and Sign.
+	MinEncryptSignBits int
+	// MinDecryptVerifyBits is the minimum modulus size for Decrypt and Verify.
+	MinDecryptVerifyBits int
+	// AllowedPaddings lists the permitted padding schemes. Empty permits all.
+	AllowedPaddings []Padding
+	// AllowedHashes lists the permitted hashes for signatures and OAEP.
+	// Empty permits all.
+	AllowedHashes []crypto.Hash
+	// AllowedExponents lists the permitted public exponents. Empty permits
+	// all, and no preset sets it: keys with small exponents such as 3 or 17
+	// from legacy systems are valid, so restricting them is an opt-in.
+	AllowedExponents []int
+}
+
+// LegacyPolicy accepts 1024-bit keys for new operations and 512-bit keys for
+// decrypting and verifying existing data, with any padding, hash or exponent.
+func LegacyPolicy() *Policy {
+	return &Policy{
+		MinEncryptSignBits:   1024,
+		MinDecryptVerifyBits: 512,
+	}
+}
+
+// DefaultPolicy requires 2048-bit keys and rejects MD5.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinEncryptSignBits:   2048,
+		MinDecryptVerifyBits: 2048,
+		AllowedPaddings:      []Padding{PaddingPKCS1v15, PaddingOAEP, PaddingPSS},
+		AllowedHashes:        []crypto.Hash{crypto.SHA1, crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512},
+	}
+}
+
+// StrictPolicy requires 3072-bit keys, OAEP or PSS
+// padding and a SHA-2 hash of at least 256 bits. Encrypt, Decrypt, Sign and
+// Verify use PKCS#1 v1.5 and are therefore rejected; use EncryptWith and
+// friends with an OAEP or PSS Scheme instead.
+func StrictPolicy() *Policy {
+	return &Policy{
+		MinEncryptSignBits:   3072,
+		MinDecryptVerifyBits: 3072,
+		AllowedPaddings:      []Padding{PaddingOAEP, PaddingPSS},
+		AllowedHashes:        []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512},
+	}
+}
+
+// PolicyError describes why an operation was rejected by a Policy.
+type PolicyError struct {
+	Op     string // "encrypt", "decrypt", "sign" or "verify"
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return "policy: " + e.Op + ": " + e.Reason
+}
+
+// Is makes errors.Is(err, ErrPolicyViolation) report true.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+type operation string
+
+const (
+	opEncrypt operation = "encrypt"
+	opDecrypt operation = "decrypt"
+	opSign    operation = "sign"
+	opVerify  operation = "verify"
+)
+
+// check returns a *PolicyError if the policy forbids using pub with scheme
+// for op. A nil policy permits everything.
+func (p *Policy) check(op operation, pub *rsa.PublicKey, scheme Scheme) error {
+	if p == nil {
+		return nil
+	}
+	reject := func(format string, args ...interface{}) error {
+		return &PolicyError{Op: string(op), Reason: fmt.Sprintf(format, args...)}
+	}
+
+	minBits := p.MinDecryptVerifyBits
+	if op == opEncrypt || op == opSign {
+		minBits = p.MinEncryptSignBits
+	}
+	if bits := pub.N.BitLen(); bits < minBits {
+		return reject("modulus of %d bits is below the minimum of %d", bits, minBits)
+	}
+
+	if len(p.AllowedExponents) > 0 && !contains(p.AllowedExponents, pub.E) {
+		return reject("public exponent %d is not allowed", pub.E)
+	}
+	if len(p.AllowedPaddings) > 0 && !contains(p.AllowedPaddings, scheme.Padding) {
+		return reject("padding %s is not allowed", scheme.Padding)
+	}
+
+	// PKCS#1 v1.5 encryption does not involve a hash.
+	usesHash := !(scheme.Padding == PaddingPKCS1v15 && (op == opEncrypt || op == opDecrypt))
+	if usesHash && len(p.AllowedHashes) > 0 && !contains(p.AllowedHashes, scheme.Hash) {
+		return reject("hash %s is not allowed", hashName(scheme.Hash))
+	}
+	return nil
+}
+
+// minKeyBits returns the smallest modulus the policy accepts for new
+// operations, or zero for a nil policy.
+func (p *Policy) minKeyBits() int {
+	if p == nil {
+		return 0
+	}
+	return p.MinEncryptSignBits
+}
+
+func hashName(h crypto.Hash) string {
+	if h == 0 {
+		return "none"
+	}
+	return h.String()
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package jsencrypt
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestJSEncrypt_SchemeRoundTrip(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPrivateKey(exampleTestKeys.privateKey); err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("scheme round trip")
+
+	ciphertext, err := jsCrypt.EncryptWith(msg, SchemeOAEPSHA256)
+	if err != nil {
+		t.Fatalf("OAEP encryption failed: %v", err)
+	}
+	plaintext, err := jsCrypt.DecryptWith(ciphertext, SchemeOAEPSHA256)
+	if err != nil {
+		t.Fatalf("OAEP decryption failed: %v", err)
+	}
+	if string(plaintext) != string(msg) {
+		t.Errorf("Decrypted message doesn't match. Got %s, want %s", plaintext, msg)
+	}
+	if _, err := jsCrypt.DecryptWith(ciphertext, SchemePKCS1v15); err == nil {
+		t.Error("Decrypting OAEP ciphertext with PKCS#1 v1.5 should fail")
+	}
+
+	signature, err := jsCrypt.SignWith(msg, SchemePSSSHA256)
+	if err != nil {
+		t.Fatalf("PSS signing failed: %v", err)
+	}
+	if err := jsCrypt.VerifyWith(msg, signature, SchemePSSSHA256); err != nil {
+		t.Errorf("PSS verification failed: %v", err)
+	}
+	if err := jsCrypt.VerifyWith([]byte("tampered"), signature, SchemePSSSHA256); !errors.Is(err, rsa.ErrVerification) {
+		t.Errorf("Expected rsa.ErrVerification for a tampered message, got %v", err)
+	}
+
+	if _, err := jsCrypt.EncryptWith(msg, SchemePSSSHA256); err == nil {
+		t.Error("PSS is not an encryption padding")
+	}
+	if _, err := jsCrypt.SignWith(msg, Scheme{Padding: PaddingPKCS1v15}); err == nil {
+		t.Error("Signing without a hash should fail")
+	}
+}
+
+func TestJSEncrypt_DefaultPolicy(t *testing.T) {
+	// testPublicKeys[3] is a 1024-bit key.
+	jsCrypt := NewJSEncrypt()
+	jsCrypt.Policy = DefaultPolicy()
+	if err := jsCrypt.SetPublicKey(testPublicKeys[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := jsCrypt.Encrypt("too small")
+	if !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("Expected policy violation, got %v", err)
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Op != "encrypt" || !strings.Contains(policyErr.Reason, "1024 bits") {
+		t.Errorf("Unexpected policy error: %v", err)
+	}
+
+	// A 2048-bit key passes, but MD5 is rejected.
+	if err := jsCrypt.SetPrivateKey(exampleTestKeys.privateKey); err != nil {
+		t.Fatal(err)
+	}
+	jsCrypt.publicKey = &jsCrypt.privateKey.PublicKey
+	if _, err := jsCrypt.Encrypt("ok"); err != nil {
+		t.Errorf("2048-bit key should be allowed: %v", err)
+	}
+	_, err = jsCrypt.SignWith([]byte("msg"), Scheme{Padding: PaddingPKCS1v15, Hash: crypto.MD5})
+	if !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), "MD5") {
+		t.Errorf("Expected MD5 to be rejected, got %v", err)
+	}
+
+	// Presets accept any exponent, so legacy keys keep working.
+	weak := NewJSEncrypt()
+	weak.Policy = DefaultPolicy()
+	weak.publicKey = &rsa.PublicKey{N: jsCrypt.privateKey.N, E: 3}
+	if _, err := weak.Encrypt("msg"); err != nil {
+		t.Errorf("DefaultPolicy rejected exponent 3: %v", err)
+	}
+
+	// Restricting exponents is opt-in; a rejected exponent fails before
+	// any RSA operation takes place.
+	weak.Policy.AllowedExponents = []int{65537}
+	if _, err := weak.Encrypt("msg"); !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), "exponent 3") {
+		t.Errorf("Expected exponent 3 to be rejected, got %v", err)
+	}
+}
+
+func TestJSEncrypt_LegacyPolicySplitsThresholds(t *testing.T) {
+	// testPrivateKeys[2] is a 512-bit key: allowed for decrypt and verify,
+	// rejected for encrypt and sign.
+	signer := NewJSEncrypt()
+	if err := signer.SetPrivateKey(testPrivateKeys[2]); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := signer.Encrypt("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer.Policy = LegacyPolicy()
+	if valid, err := signer.Verify("legacy", signature); err != nil || !valid {
+		t.Errorf("Legacy policy should verify with a 512-bit key: %v", err)
+	}
+	if plaintext, err := signer.Decrypt(ciphertext); err != nil || plaintext != "legacy" {
+		t.Errorf("Legacy policy should decrypt with a 512-bit key: %v", err)
+	}
+	if _, err := signer.Sign("legacy"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Legacy policy should refuse to sign with a 512-bit key, got %v", err)
+	}
+	if _, err := signer.Encrypt("legacy"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Legacy policy should refuse to encrypt with a 512-bit key, got %v", err)
+	}
+}
+
+func TestJSEncrypt_StrictPolicy(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	jsCrypt.Policy = StrictPolicy()
+
+	// Generated keys honour the policy minimum instead of DefaultKeySize.
+	if _, err := jsCrypt.GetPublicKey(); err != nil {
+		t.Fatal(err)
+	}
+	if bits := jsCrypt.publicKey.N.BitLen(); bits != 3072 {
+		t.Fatalf("Expected a 3072-bit generated key, got %d", bits)
+	}
+
+	if _, err := jsCrypt.Encrypt("pkcs1"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Strict policy should reject PKCS#1 v1.5 encryption, got %v", err)
+	}
+	if _, err := jsCrypt.Sign("pkcs1"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Strict policy should reject PKCS#1 v1.5 signatures, got %v", err)
+	}
+
+	ciphertext, err := jsCrypt.EncryptWith([]byte("oaep"), SchemeOAEPSHA256)
+	if err != nil {
+		t.Fatalf("Strict policy should allow OAEP-SHA256: %v", err)
+	}
+	if _, err := jsCrypt.DecryptWith(ciphertext, SchemeOAEPSHA256); err != nil {
+		t.Errorf("Strict policy should allow OAEP-SHA256 decryption: %v", err)
+	}
+	if _, err := jsCrypt.EncryptWith([]byte("oaep"), Scheme{Padding: PaddingOAEP, Hash: crypto.SHA1}); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Strict policy should reject OAEP-SHA1, got %v", err)
+	}
+
+	small := NewJSEncrypt()
+	small.Policy = StrictPolicy()
+	small.publicKey = &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), 2047), E: 65537}
+	if _, err := small.EncryptWith([]byte("oaep"), SchemeOAEPSHA256); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Strict policy should reject 2048-bit keys, got %v", err)
+	}
+}
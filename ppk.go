@@ -0,0 +1,297 @@
+package jsencrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gmodx/go-jsencrypt/internal/argon2"
+)
+
+// PuTTY private key files (.ppk), format versions 2 and 3. Only ssh-rsa keys
+// are supported. Encrypted files use aes256-cbc; version 2 derives the key
+// from the passphrase with SHA-1, version 3 with Argon2.
+
+// ppkFile holds the header fields and decoded blobs of a .ppk file.
+type ppkFile struct {
+	version    int
+	algorithm  string
+	encryption string
+	comment    string
+	public     []byte
+	private    []byte
+	mac        []byte
+	headers    map[string]string
+}
+
+// SetKeyPPK loads an RSA private key from a PuTTY .ppk file. The passphrase
+// is ignored for unencrypted files. The file MAC is always verified, so a
+// wrong passphrase or a tampered file results in an error.
+func (j *JSEncrypt) SetKeyPPK(data []byte, passphrase string) error {
+	f, err := parsePPK(data)
+	if err != nil {
+		return err
+	}
+	if f.algorithm != "ssh-rsa" {
+		return errors.New("ppk: unsupported key algorithm " + f.algorithm)
+	}
+
+	var cipherKey, iv, macKey []byte
+	var newMAC func() hash.Hash
+	switch f.version {
+	case 2:
+		newMAC = sha1.New
+		if f.encryption == "none" {
+			passphrase = ""
+		} else {
+			k0 := sha1.Sum(append([]byte{0, 0, 0, 0}, passphrase...))
+			k1 := sha1.Sum(append([]byte{0, 0, 0, 1}, passphrase...))
+			cipherKey = append(k0[:], k1[:]...)[:32]
+			iv = make([]byte, aes.BlockSize)
+		}
+		sum := sha1.Sum([]byte("putty-private-key-file-mac-key" + passphrase))
+		macKey = sum[:]
+	case 3:
+		newMAC = sha256.New
+		if f.encryption != "none" {
+			derived, err := ppkArgon2(f.headers, passphrase)
+			if err != nil {
+				return err
+			}
+			cipherKey, iv, macKey = derived[:32], derived[32:48], derived[48:]
+		}
+	}
+
+	private := f.private
+	if f.encryption != "none" {
+		if len(private) == 0 || len(private)%aes.BlockSize != 0 {
+			return errors.New("ppk: invalid encrypted private key length")
+		}
+		block, err := aes.NewCipher(cipherKey)
+		if err != nil {
+			return err
+		}
+		private = make([]byte, len(f.private))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(private, f.private)
+	}
+
+	mac := hmac.New(newMAC, macKey)
+	for _, field := range [][]byte{[]byte(f.algorithm), []byte(f.encryption), []byte(f.comment), f.public, private} {
+		mac.Write(sshString(field))
+	}
+	if !hmac.Equal(mac.Sum(nil), f.mac) {
+		if f.encryption != "none" {
+			return errors.New("ppk: wrong passphrase or corrupted key file")
+		}
+		return errors.New("ppk: MAC verification failed")
+	}
+
+	// Public blob: string "ssh-rsa", mpint e, mpint n.
+	r := sshReader(f.public)
+	if alg := r.readString(); string(alg) != "ssh-rsa" {
+		return errors.New("ppk: public key algorithm mismatch")
+	}
+	e := r.readMPInt()
+	n := r.readMPInt()
+	if r.err != nil {
+		return r.err
+	}
+
+	// Private blob: mpint d, mpint p, mpint q, mpint iqmp, then padding.
+	r = sshReader(private)
+	d := r.readMPInt()
+	p := r.readMPInt()
+	q := r.readMPInt()
+	r.readMPInt() // iqmp is recomputed by Precompute
+	if r.err != nil {
+		return r.err
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return errors.New("ppk: public exponent too large")
+	}
+	priv := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+		D:         d,
+		Primes:    []*big.Int{p, q},
+	}
+	if err := priv.Validate(); err != nil {
+		return err
+	}
+	priv.Precompute()
+
+	return j.setPrivateKey(priv)
+}
+
+// parsePPK splits a .ppk file into its header fields and base64 blobs.
+func parsePPK(data []byte) (*ppkFile, error) {
+	f := &ppkFile{headers: make(map[string]string)}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+
+	next := func() (string, string, error) {
+		if !sc.Scan() {
+			return "", "", errors.New("ppk: unexpected end of file")
+		}
+		line := strings.TrimRight(sc.Text(), "\r")
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return "", "", errors.New("ppk: malformed header line")
+		}
+		return key, value, nil
+	}
+	readBlob := func(count string) ([]byte, error) {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 || n > 1024 {
+			return nil, errors.New("ppk: invalid line count")
+		}
+		var b64 strings.Builder
+		for i := 0; i < n; i++ {
+			if !sc.Scan() {
+				return nil, errors.New("ppk: unexpected end of file")
+			}
+			b64.WriteString(strings.TrimRight(sc.Text(), "\r"))
+		}
+		return base64.StdEncoding.DecodeString(b64.String())
+	}
+
+	key, value, err := next()
+	if err != nil {
+		return nil, err
+	}
+	switch key {
+	case "PuTTY-User-Key-File-2":
+		f.version = 2
+	case "PuTTY-User-Key-File-3":
+		f.version = 3
+	default:
+		return nil, errors.New("ppk: not a PuTTY version 2 or 3 key file")
+	}
+	f.algorithm = value
+
+	for {
+		key, value, err := next()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "Encryption":
+			f.encryption = value
+		case "Comment":
+			f.comment = value
+		case "Public-Lines":
+			if f.public, err = readBlob(value); err != nil {
+				return nil, err
+			}
+		case "Private-Lines":
+			if f.private, err = readBlob(value); err != nil {
+				return nil, err
+			}
+		case "Private-MAC":
+			if f.mac, err = hex.DecodeString(value); err != nil {
+				return nil, errors.New("ppk: invalid MAC encoding")
+			}
+			if f.encryption != "none" && f.encryption != "aes256-cbc" {
+				return nil, errors.New("ppk: unsupported encryption " + f.encryption)
+			}
+			return f, nil
+		default:
+			f.headers[key] = value
+		}
+	}
+}
+
+// ppkArgon2 derives the 80 bytes of cipher key, IV and MAC key used by
+// encrypted version 3 files.
+func ppkArgon2(headers map[string]string, passphrase string) ([]byte, error) {
+	var mode argon2.Mode
+	switch headers["Key-Derivation"] {
+	case "Argon2id":
+		mode = argon2.Argon2id
+	case "Argon2i":
+		mode = argon2.Argon2i
+	case "Argon2d":
+		mode = argon2.Argon2d
+	default:
+		return nil, errors.New("ppk: unsupported key derivation " + headers["Key-Derivation"])
+	}
+
+	memory, err1 := strconv.ParseUint(headers["Argon2-Memory"], 10, 32)
+	passes, err2 := strconv.ParseUint(headers["Argon2-Passes"], 10, 32)
+	parallelism, err3 := strconv.ParseUint(headers["Argon2-Parallelism"], 10, 8)
+	salt, err4 := hex.DecodeString(headers["Argon2-Salt"])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || passes == 0 || parallelism == 0 {
+		return nil, errors.New("ppk: invalid Argon2 parameters")
+	}
+	// Refuse absurd memory requirements from untrusted files (1 GiB).
+	if memory > 1<<20 {
+		return nil, errors.New("ppk: Argon2 memory parameter too large")
+	}
+	// Likewise for time: PuTTY picks about 100ms of work, a few dozen passes
+	// over 8 MiB, so the total of 4 GiB processed leaves ample headroom.
+	if passes > 1<<10 || memory*passes > 1<<22 {
+		return nil, errors.New("ppk: Argon2 passes parameter too large")
+	}
+
+	return argon2.Key(mode, []byte(passphrase), salt, uint32(passes), uint32(memory), uint8(parallelism), 80), nil
+}
+
+// sshString encodes b as an SSH wire format string (RFC 4251).
+func sshString(b []byte) []byte {
+	out := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(out, uint32(len(b)))
+	copy(out[4:], b)
+	return out
+}
+
+// wireReader decodes SSH wire format values, recording the first error.
+type wireReader struct {
+	buf []byte
+	err error
+}
+
+func sshReader(b []byte) *wireReader {
+	return &wireReader{buf: b}
+}
+
+func (r *wireReader) readString() []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < 4 {
+		r.err = errors.New("ssh: truncated data")
+		return nil
+	}
+	n := binary.BigEndian.Uint32(r.buf)
+	if uint64(n) > uint64(len(r.buf)-4) {
+		r.err = errors.New("ssh: truncated data")
+		return nil
+	}
+	s := r.buf[4 : 4+n]
+	r.buf = r.buf[4+n:]
+	return s
+}
+
+func (r *wireReader) readMPInt() *big.Int {
+	b := r.readString()
+	if r.err != nil {
+		return nil
+	}
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		r.err = errors.New("ssh: negative mpint")
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
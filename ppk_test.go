@@ -0,0 +1,101 @@
+package jsencrypt
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// Fixtures in testdata/ppk hold the same 2048-bit key in PuTTY format
+// versions 2 and 3, unencrypted and encrypted with the passphrase below.
+// The version 3 encrypted file uses Argon2id.
+const ppkTestPassphrase = "correct horse"
+
+func TestJSEncrypt_SetKeyPPK(t *testing.T) {
+	pubPEM, err := os.ReadFile("testdata/ppk/public.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJSEncrypt()
+	if err := verifier.SetPublicKey(string(pubPEM)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"v2.ppk", "v2-encrypted.ppk", "v3.ppk", "v3-encrypted.ppk"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/ppk/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jsCrypt := NewJSEncrypt()
+			if err := jsCrypt.SetKeyPPK(data, ppkTestPassphrase); err != nil {
+				t.Fatalf("Failed to load PPK file: %v", err)
+			}
+
+			signature, err := jsCrypt.Sign("ppk")
+			if err != nil {
+				t.Fatalf("Signing failed: %v", err)
+			}
+			valid, err := verifier.Verify("ppk", signature)
+			if err != nil || !valid {
+				t.Errorf("Signature from PPK key did not verify: %v", err)
+			}
+
+			encrypted, err := verifier.Encrypt("ppk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := jsCrypt.Decrypt(encrypted)
+			if err != nil || decrypted != "ppk" {
+				t.Errorf("Decryption with PPK key failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestJSEncrypt_SetKeyPPKWrongPassphrase(t *testing.T) {
+	for _, name := range []string{"v2-encrypted.ppk", "v3-encrypted.ppk"} {
+		data, err := os.ReadFile("testdata/ppk/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewJSEncrypt().SetKeyPPK(data, "wrong"); err == nil {
+			t.Errorf("%s: should have failed with the wrong passphrase", name)
+		}
+	}
+}
+
+func TestJSEncrypt_SetKeyPPKTampered(t *testing.T) {
+	data, err := os.ReadFile("testdata/ppk/v3.ppk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), "Comment: rsa-key-v3", "Comment: rsa-key-XX", 1)
+	if err := NewJSEncrypt().SetKeyPPK([]byte(tampered), ""); err == nil {
+		t.Error("Should have rejected a file with a modified comment")
+	}
+
+	if err := NewJSEncrypt().SetKeyPPK([]byte("PuTTY-User-Key-File-1: ssh-rsa\n"), ""); err == nil {
+		t.Error("Should have rejected an unsupported format version")
+	}
+}
+
+func TestJSEncrypt_SetKeyPPKArgon2Limits(t *testing.T) {
+	data, err := os.ReadFile("testdata/ppk/v3-encrypted.ppk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, params := range [][2]string{
+		{"8192", "4294967295"},
+		{"1048576", "8"},
+		{"2097152", "1"},
+	} {
+		crafted := strings.Replace(string(data), "Argon2-Memory: 8192", "Argon2-Memory: "+params[0], 1)
+		crafted = strings.Replace(crafted, "Argon2-Passes: 8", "Argon2-Passes: "+params[1], 1)
+		err := NewJSEncrypt().SetKeyPPK([]byte(crafted), ppkTestPassphrase)
+		if err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Errorf("Memory %s, passes %s: SetKeyPPK = %v, want a parameter limit error", params[0], params[1], err)
+		}
+	}
+}
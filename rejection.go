@@ -0,0 +1,149 @@
+package jsencrypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// ErrDecryption is returned for every decryption failure caused by the
+// ciphertext: bad base64, wrong length or invalid padding. Returning one
+// error for all of them avoids turning Decrypt into a padding oracle. It is
+// rsa.ErrDecryption, so errors.Is matches either.
+var ErrDecryption = rsa.ErrDecryption
+
+// DecryptSessionKey decrypts a base64 PKCS#1 v1.5 ciphertext carrying a
+// session key of keyLen bytes, such as an AES key wrapped by the browser.
+// If the padding is invalid or the plaintext has the wrong length, a random
+// key of keyLen bytes is returned instead of an error, in constant time, so
+// a subsequent symmetric decryption fails without revealing why
+// (RFC 5246, section 7.4.7.1). This is the recommended way to receive
+// secrets encrypted with PKCS#1 v1.5 on a network facing endpoint.
+//
+// An error is only returned for malformed input whose properties the sender
+// already knows, such as bad base64 or a ciphertext of the wrong size.
+func (j *JSEncrypt) DecryptSessionKey(ciphertext string, keyLen int) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return j.decrypt(rand.Reader, decoded, SchemePKCS1v15, &rsa.PKCS1v15DecryptOptions{SessionKeyLen: keyLen})
+}
+
+// DecryptImplicit is Decrypt with implicit rejection, whatever the
+// ImplicitRejection field says: a ciphertext with invalid padding decrypts
+// to a deterministic pseudo-random plaintext instead of failing. It lets a
+// caller that does not own j, such as middleware, avoid acting as a padding
+// oracle without changing how others use the key.
+func (j *JSEncrypt) DecryptImplicit(str string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return "", ErrDecryption
+	}
+	decrypted, err := j.decryptImplicit(decoded, SchemePKCS1v15)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// implicitRejectionTries is the number of 16-bit length candidates drawn
+// for the synthetic message.
+const implicitRejectionTries = 128
+
+// decryptImplicit decrypts a PKCS#1 v1.5 ciphertext with implicit
+// rejection: on invalid padding it returns a synthetic message derived
+// deterministically from the private key and the ciphertext instead of an
+// error, as specified in draft-irtf-cfrg-rsa-guidance and implemented by
+// OpenSSL 3.2. The same bad ciphertext always produces the same plaintext,
+// so the caller cannot tell the difference from a successful decryption.
+func (j *JSEncrypt) decryptImplicit(ciphertext []byte, scheme Scheme) ([]byte, error) {
+	priv, release, err := j.getKey()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := j.Policy.check(opDecrypt, &priv.PublicKey, scheme); err != nil {
+		return nil, err
+	}
+	k := priv.Size()
+	if len(ciphertext) > k || k < 11 {
+		return nil, ErrDecryption
+	}
+
+	// The padding check only sets a flag. Both messages are copied into k
+	// byte buffers and the result is selected in constant time, so the
+	// same work is done whether or not the padding is valid.
+	synthetic := syntheticMessage(priv, ciphertext)
+	msg, err := rsa.DecryptPKCS1v15(rand.Reader, priv, ciphertext)
+	valid := 0
+	if err == nil {
+		valid = 1
+	}
+	out := make([]byte, k)
+	decrypted := make([]byte, k)
+	copy(out, synthetic)
+	copy(decrypted, msg)
+	subtle.ConstantTimeCopy(valid, out, decrypted)
+	wipeBytes(decrypted)
+	wipeBytes(msg)
+	return out[:subtle.ConstantTimeSelect(valid, len(msg), len(synthetic))], nil
+}
+
+// syntheticMessage computes the implicit rejection message for ciphertext.
+func syntheticMessage(priv *rsa.PrivateKey, ciphertext []byte) []byte {
+	k := priv.Size()
+
+	// KDK = HMAC-SHA256(SHA256(D), C), with D and C left-padded to k bytes.
+	dHash := sha256.Sum256(priv.D.FillBytes(make([]byte, k)))
+	mac := hmac.New(sha256.New, dHash[:])
+	mac.Write(make([]byte, k-len(ciphertext)))
+	mac.Write(ciphertext)
+	kdk := mac.Sum(nil)
+
+	message := implicitRejectionPRF(kdk, "message", k)
+	candidates := implicitRejectionPRF(kdk, "length", implicitRejectionTries*2)
+
+	// The message may be at most k-11 bytes long: two bytes for the block
+	// type and eight bytes of padding before the zero separator.
+	maxSepOffset := k - 2 - 8
+	mask := maxSepOffset
+	mask |= mask >> 1
+	mask |= mask >> 2
+	mask |= mask >> 4
+	mask |= mask >> 8
+	length := 0
+	for i := 0; i < len(candidates); i += 2 {
+		candidate := int(binary.BigEndian.Uint16(candidates[i:])) & mask
+		length = subtle.ConstantTimeSelect(constantTimeLess(candidate, maxSepOffset), candidate, length)
+	}
+	return message[k-length:]
+}
+
+// implicitRejectionPRF expands kdk into n bytes with HMAC-SHA256 in counter
+// mode: block i is HMAC(kdk, uint16(i) || label || uint16(n*8)).
+func implicitRejectionPRF(kdk []byte, label string, n int) []byte {
+	out := make([]byte, 0, n+sha256.Size)
+	var counter, bits [2]byte
+	binary.BigEndian.PutUint16(bits[:], uint16(n*8))
+	mac := hmac.New(sha256.New, kdk)
+	for i := 0; len(out) < n; i++ {
+		binary.BigEndian.PutUint16(counter[:], uint16(i))
+		mac.Reset()
+		mac.Write(counter[:])
+		mac.Write([]byte(label))
+		mac.Write(bits[:])
+		out = mac.Sum(out)
+	}
+	return out[:n]
+}
+
+// constantTimeLess returns 1 if x < y and 0 otherwise, for non-negative
+// values below 2^31.
+func constantTimeLess(x, y int) int {
+	return int((uint32(x) - uint32(y)) >> 31)
+}
@@ -0,0 +1,238 @@
+package jsencrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func tamper(t *testing.T, ciphertext string) string {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)/2] ^= 0x01
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestJSEncrypt_UniformDecryptionError(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	ciphertext, err := jsCrypt.Encrypt("uniform")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string]string{
+		"bad base64":      "not base64!",
+		"wrong length":    base64.StdEncoding.EncodeToString(make([]byte, 300)),
+		"invalid padding": tamper(t, ciphertext),
+	}
+	var first error
+	for name, input := range inputs {
+		_, err := jsCrypt.Decrypt(input)
+		if !errors.Is(err, ErrDecryption) || !errors.Is(err, rsa.ErrDecryption) {
+			t.Errorf("%s: got %v, want ErrDecryption", name, err)
+		}
+		if first == nil {
+			first = err
+		} else if err.Error() != first.Error() {
+			t.Errorf("%s: error %q differs from %q", name, err, first)
+		}
+	}
+}
+
+func TestJSEncrypt_ImplicitRejection(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	jsCrypt.ImplicitRejection = true
+
+	ciphertext, err := jsCrypt.Encrypt("valid padding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := jsCrypt.Decrypt(ciphertext); err != nil || plaintext != "valid padding" {
+		t.Fatalf("Decrypt() = %q, %v", plaintext, err)
+	}
+
+	bad := tamper(t, ciphertext)
+	first, err := jsCrypt.Decrypt(bad)
+	if err != nil {
+		t.Fatalf("Implicit rejection must not return an error, got %v", err)
+	}
+	second, _ := jsCrypt.Decrypt(bad)
+	if first != second {
+		t.Error("Synthetic plaintext must be deterministic")
+	}
+	if first == "valid padding" || len(first) > jsCrypt.privateKey.Size()-11 {
+		t.Errorf("Unexpected synthetic plaintext of %d bytes", len(first))
+	}
+	other, _ := jsCrypt.Decrypt(tamper(t, bad))
+	if other == first {
+		t.Error("Different ciphertexts should produce different synthetic plaintexts")
+	}
+
+	// The derivation is keyed by the private exponent.
+	raw, _ := base64.StdEncoding.DecodeString(bad)
+	otherD := &rsa.PrivateKey{PublicKey: jsCrypt.privateKey.PublicKey, D: new(big.Int).Add(jsCrypt.privateKey.D, big.NewInt(2))}
+	if string(syntheticMessage(otherD, raw)) == first {
+		t.Error("Synthetic plaintext should depend on the private key")
+	}
+
+	if _, err := jsCrypt.Decrypt(base64.StdEncoding.EncodeToString(make([]byte, 300))); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Oversized ciphertext should still fail, got %v", err)
+	}
+
+	external := NewJSEncrypt()
+	external.ImplicitRejection = true
+	if err := external.SetDecrypter(newExternalKey(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := external.Decrypt(ciphertext); !errors.Is(err, ErrExternalKey) {
+		t.Errorf("Implicit rejection needs the private exponent, got %v", err)
+	}
+}
+
+func TestJSEncrypt_DecryptImplicit(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	ciphertext, err := jsCrypt.Encrypt("valid padding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := jsCrypt.DecryptImplicit(ciphertext); err != nil || plaintext != "valid padding" {
+		t.Errorf("DecryptImplicit() = %q, %v", plaintext, err)
+	}
+
+	bad := tamper(t, ciphertext)
+	synthetic, err := jsCrypt.DecryptImplicit(bad)
+	if err != nil {
+		t.Fatalf("DecryptImplicit of invalid padding: %v", err)
+	}
+	if _, err := jsCrypt.Decrypt(bad); !errors.Is(err, ErrDecryption) {
+		t.Errorf("DecryptImplicit must not enable implicit rejection for Decrypt, got %v", err)
+	}
+	jsCrypt.ImplicitRejection = true
+	if plaintext, _ := jsCrypt.Decrypt(bad); plaintext != synthetic {
+		t.Error("DecryptImplicit and Decrypt with ImplicitRejection should agree")
+	}
+	if _, err := jsCrypt.DecryptImplicit("not base64!"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Bad base64: got %v, want ErrDecryption", err)
+	}
+}
+
+func TestImplicitRejectionPRF(t *testing.T) {
+	kdk := bytes.Repeat([]byte{0x42}, 32)
+	long := implicitRejectionPRF(kdk, "message", 100)
+	if len(long) != 100 {
+		t.Fatalf("Expected 100 bytes, got %d", len(long))
+	}
+	// The output length is part of the HMAC input, so a shorter request is
+	// not a prefix of a longer one.
+	if short := implicitRejectionPRF(kdk, "message", 64); bytes.Equal(short, long[:64]) {
+		t.Error("PRF output should depend on the requested length")
+	}
+	if bytes.Equal(implicitRejectionPRF(kdk, "length", 100), long) {
+		t.Error("PRF output should depend on the label")
+	}
+}
+
+// implicitRejectionVectors are known answers for testPrivateKeys[3],
+// computed with an independent implementation of the implicit rejection
+// algorithm of draft-irtf-cfrg-rsa-guidance, section 7: big integer RSA
+// decryption, HMAC-SHA256 key derivation and the PRF as specified.
+var implicitRejectionVectors = []struct {
+	name       string
+	ciphertext string
+	plaintext  string
+}{
+	{
+		name:       "valid",
+		ciphertext: "818c571df5c8d5698921e80d299c2fa72c8dc7f5f29fa0212381cde0fcf08b1a4115c89e0aa8b3cf6e14562be041efdd201cce114cdf4adbebf2a8b880ff4c6063878ba00d678790c6bcc69f1601208dcb7755478f53871374962bb41d26c5a8645d13efbdee1ebf4bf4305f66e4ac82bf97a45a7018302a37eaced9c4de4ac5",
+		plaintext:  "6c6f72656d20697073756d",
+	},
+	{
+		name:       "valid empty message",
+		ciphertext: "1a1b88eeb24ad4dc97751834738564e6d19fdf631049ee274df1849ea928750e9b07983f607d01d4f79c3a09c660a87b9b635efc554e959e75a342e6a94f9febdcf088102cd17d03857fc96bbd9c8362e85ddaae7cd205e024ae6f6f24583cba73872a9aade8923a105531a18545ce4fe0a34b285e3393a072b5f9b70866ae3e",
+		plaintext:  "",
+	},
+	{
+		name:       "wrong block type",
+		ciphertext: "79580d5e1149cb3590439e46370411f996f206a76d17089409e00811e37feda9dbd8b1aef3ea5c5b95031c2abc61c78b0490e6316e98a0976e703047d603aeeac3c30bf445b0f9ae8c524786823152eb0f96b5f22ab1cb1dd1b85b228b2090c55e6b006a361ab726db9d1f0f9fe71e1a8e4120227619a82815e3f96d094ca5c5",
+		plaintext:  "83c63393e69c9107b4b48e6bc8f111d215a63d82ef1e6c70cde531cacc173994e10e1d89e04e7d5575652a09561bab1a05",
+	},
+	{
+		name:       "no separator",
+		ciphertext: "1815832716486a3f8a12d52612dae023257541b1419037f6c7923801ee403feed82019c79a89be0ef0177b5d8d57c7e580ed7410bcc7594c6bbf3809faa0db910faa488e4fb82c3d95ceefc83a4704495bc761db0a6ee858c30b187e6d66176b97973f83ac3a26bf1722c0d853a54818f08d65d1c4d07f4c45f86d8eb7014e69",
+		plaintext:  "8091f22bb68bafe6792c8127be9690bc68fa79208b02c1b4290c12154720d88684211a0c089db15c25af3dfcba6282fb022fd8b431b25d633f3c33eeec4993129a822513dd7ce03013e3b8a8b36cb99f9f1e8150f78eef430078885c1e38",
+	},
+	{
+		name:       "padding too short",
+		ciphertext: "a38390bb5b96852a04508db49e944468635d2697c77364f8bea29c2ae98312f2487e04e44b0048b632353dd93bf6b5206daec661072be2fb0ca642f39b02262aa2aa09d22680a6584f3ca7ab1ed465ea5d7d3b2c1a19ed4609848718ef03713a05d05640b6ae3624517fb1988c89ea96dba1b651090d81a8d8ee59830ec0d89e",
+		plaintext:  "5d27b17a27c98fe1b466f35a5766184ceddc64d38f5cd26ddc74c8d22972f14ca4a1a486e04811a1555e06f09435228199a297ff0fad3aa98bdd6182416fadae49d6ad7514b04d1810ee8d587e24f1982b682612f2d5fd7c4794de87f1",
+	},
+	{
+		name:       "short ciphertext",
+		ciphertext: "00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000004efc812b23d50f92aa549f9e5b6be44f8d53ce4d92e",
+		plaintext:  "f8094bc2caa22b965d9540147d0a07d6b18d7e2b130af5992c29efa10bd74b3dac492bd33aae9b042da89a1f",
+	},
+}
+
+func TestJSEncrypt_ImplicitRejectionKnownAnswers(t *testing.T) {
+	jsCrypt := newTestKey(t, testPrivateKeys[3])
+	jsCrypt.ImplicitRejection = true
+	for _, v := range implicitRejectionVectors {
+		ciphertext, _ := hex.DecodeString(v.ciphertext)
+		plaintext, err := jsCrypt.DecryptWith(ciphertext, SchemePKCS1v15)
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+		if got := hex.EncodeToString(plaintext); got != v.plaintext {
+			t.Errorf("%s: got %s, want %s", v.name, got, v.plaintext)
+		}
+	}
+}
+
+func TestJSEncrypt_DecryptSessionKey(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := rsa.EncryptPKCS1v15(rand.Reader, jsCrypt.publicKey, sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := base64.StdEncoding.EncodeToString(wrapped)
+
+	key, err := jsCrypt.DecryptSessionKey(ciphertext, 32)
+	if err != nil || !bytes.Equal(key, sessionKey) {
+		t.Fatalf("DecryptSessionKey() = %x, %v; want %x", key, err, sessionKey)
+	}
+
+	// Invalid padding or a wrong key length yields a random key, not an error.
+	for name, input := range map[string]struct {
+		ciphertext string
+		keyLen     int
+	}{
+		"invalid padding": {tamper(t, ciphertext), 32},
+		"wrong length":    {ciphertext, 16},
+	} {
+		key, err := jsCrypt.DecryptSessionKey(input.ciphertext, input.keyLen)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if len(key) != input.keyLen || bytes.Equal(key, sessionKey[:input.keyLen]) {
+			t.Errorf("%s: expected a random key of %d bytes, got %x", name, input.keyLen, key)
+		}
+	}
+
+	if _, err := jsCrypt.DecryptSessionKey("%%%", 32); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for bad base64, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package jsencrypt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strconv"
+)
+
+// Padding identifies an RSA padding scheme.
+type Padding int
+
+const (
+	// PaddingPKCS1v15 is RSAES-PKCS1-v1_5 for encryption and
+	// RSASSA-PKCS1-v1_5 for signatures, as used by JSEncrypt.
+	PaddingPKCS1v15 Padding = iota + 1
+	// PaddingOAEP is RSAES-OAEP (encryption only).
+	PaddingOAEP
+	// PaddingPSS is RSASSA-PSS (signatures only).
+	PaddingPSS
+)
+
+func (p Padding) String() string {
+	switch p {
+	case PaddingPKCS1v15:
+		return "PKCS1v15"
+	case PaddingOAEP:
+		return "OAEP"
+	case PaddingPSS:
+		return "PSS"
+	}
+	return "Padding(" + strconv.Itoa(int(p)) + ")"
+}
+
+// Scheme selects the padding and hash used by EncryptWith, DecryptWith,
+// SignWith and VerifyWith.
+type Scheme struct {
+	Padding Padding
+	// Hash is the OAEP and MGF1 hash for encryption, or the message digest
+	// for signatures. It is ignored for PKCS#1 v1.5 encryption.
+	Hash crypto.Hash
+	// Label is the optional OAEP label.
+	Label []byte
+	// SaltLength is the PSS salt length. Zero means the salt is as long as
+	// the hash, which is what most other implementations use.
+	SaltLength int
+}
+
+var (
+	// SchemePKCS1v15 is the scheme used by Encrypt and Decrypt.
+	SchemePKCS1v15 = Scheme{Padding: PaddingPKCS1v15}
+	// SchemePKCS1v15SHA256 is the scheme used by Sign and Verify.
+	SchemePKCS1v15SHA256 = Scheme{Padding: PaddingPKCS1v15, Hash: crypto.SHA256}
+	// SchemeOAEPSHA256 is RSA-OAEP with SHA-256 and MGF1-SHA-256.
+	SchemeOAEPSHA256 = Scheme{Padding: PaddingOAEP, Hash: crypto.SHA256}
+	// SchemePSSSHA256 is RSASSA-PSS with SHA-256 and a 32 byte salt.
+	SchemePSSSHA256 = Scheme{Padding: PaddingPSS, Hash: crypto.SHA256}
+)
+
+// EncryptWith encrypts msg with the public key using the given scheme and
+// returns the raw ciphertext.
+func (j *JSEncrypt) EncryptWith(msg []byte, scheme Scheme) ([]byte, error) {
+	pub, err := j.ensurePublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := j.Policy.check(opEncrypt, pub, scheme); err != nil {
+		return nil, err
+	}
+
+	switch scheme.Padding {
+	case PaddingPKCS1v15:
+		return rsa.EncryptPKCS1v15(rand.Reader, pub, msg)
+	case PaddingOAEP:
+		if !scheme.Hash.Available() {
+			return nil, errors.New("OAEP hash function not available")
+		}
+		return rsa.EncryptOAEP(scheme.Hash.New(), rand.Reader, pub, msg, scheme.Label)
+	}
+	return nil, errors.New("unsupported encryption padding " + scheme.Padding.String())
+}
+
+// DecryptWith decrypts a raw ciphertext with the private key, or the
+// decrypter set with SetDecrypter, using the given scheme.
+func (j *JSEncrypt) DecryptWith(ciphertext []byte, scheme Scheme) ([]byte, error) {
+	var opts crypto.DecrypterOpts
+	switch scheme.Padding {
+	case PaddingPKCS1v15:
+		if j.ImplicitRejection {
+			return j.decryptImplicit(ciphertext, scheme)
+		}
+		opts = &rsa.PKCS1v15DecryptOptions{}
+	case PaddingOAEP:
+		if !scheme.Hash.Available() {
+			return nil, errors.New("OAEP hash function not available")
+		}
+		opts = &rsa.OAEPOptions{Hash: scheme.Hash, Label: scheme.Label}
+	default:
+		return nil, errors.New("unsupported encryption padding " + scheme.Padding.String())
+	}
+	return j.decrypt(rand.Reader, ciphertext, scheme, opts)
+}
+
+// SignWith hashes msg with scheme.Hash and signs the digest with the private
+// key, or the signer set with SetSigner, returning the raw signature.
+func (j *JSEncrypt) SignWith(msg []byte, scheme Scheme) ([]byte, error) {
+	var opts crypto.SignerOpts
+	switch scheme.Padding {
+	case PaddingPKCS1v15:
+		opts = scheme.Hash
+	case PaddingPSS:
+		opts = scheme.pssOptions()
+	default:
+		return nil, errors.New("unsupported signature padding " + scheme.Padding.String())
+	}
+	digest, err := hashMessage(scheme.Hash, msg)
+	if err != nil {
+		return nil, err
+	}
+	return j.signDigest(rand.Reader, digest, scheme, opts)
+}
+
+// VerifyWith checks a raw signature over msg with the public key. It returns
+// nil if the signature is valid and rsa.ErrVerification if it is not.
+func (j *JSEncrypt) VerifyWith(msg, signature []byte, scheme Scheme) error {
+	pub, err := j.ensurePublicKey()
+	if err != nil {
+		return err
+	}
+	if err := j.Policy.check(opVerify, pub, scheme); err != nil {
+		return err
+	}
+	digest, err := hashMessage(scheme.Hash, msg)
+	if err != nil {
+		return err
+	}
+
+	switch scheme.Padding {
+	case PaddingPKCS1v15:
+		err = rsa.VerifyPKCS1v15(pub, scheme.Hash, digest, signature)
+	case PaddingPSS:
+		err = rsa.VerifyPSS(pub, scheme.Hash, digest, signature, scheme.pssOptions())
+	default:
+		return errors.New("unsupported signature padding " + scheme.Padding.String())
+	}
+	if err != nil {
+		return rsa.ErrVerification
+	}
+	return nil
+}
+
+func (s Scheme) pssOptions() *rsa.PSSOptions {
+	saltLength := s.SaltLength
+	if saltLength == 0 {
+		saltLength = rsa.PSSSaltLengthEqualsHash
+	}
+	return &rsa.PSSOptions{SaltLength: saltLength, Hash: s.Hash}
+}
+
+func hashMessage(h crypto.Hash, msg []byte) ([]byte, error) {
+	if h == 0 {
+		return nil, errors.New("signature scheme requires a hash")
+	}
+	if !h.Available() {
+		return nil, errors.New("hash function not available")
+	}
+	hf := h.New()
+	hf.Write(msg)
+	return hf.Sum(nil), nil
+}
@@ -0,0 +1,114 @@
+package jsencrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMessageExpired is returned by Open for a sealed message older than
+	// the maximum age, or issued too far in the future.
+	ErrMessageExpired = errors.New("sealed message expired")
+	// ErrReplayed is returned by Open for a nonce that has been seen before.
+	ErrReplayed = errors.New("sealed message replayed")
+)
+
+const (
+	// sealVersion prefixes every sealed plaintext.
+	sealVersion = "v1"
+	// sealNonceSize is the size of the random nonce in bytes.
+	sealNonceSize = 16
+
+	defaultSealMaxAge    = 5 * time.Minute
+	defaultSealClockSkew = 30 * time.Second
+)
+
+// OpenOptions configures the freshness checks of Open.
+type OpenOptions struct {
+	// MaxAge is how long after its timestamp a message is accepted.
+	// Default: 5 minutes.
+	MaxAge time.Duration
+	// ClockSkew is how far in the future a timestamp may lie, to allow for
+	// browser clocks running ahead. Default: 30 seconds.
+	ClockSkew time.Duration
+	// Nonces records seen nonces to reject replays within MaxAge. If nil,
+	// only the timestamp is checked. A full MemoryNonceStore rejects
+	// messages older than the nonces it had to evict.
+	Nonces NonceStore
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+// Seal encrypts plaintext together with the current time and a random
+// nonce, so that Open can reject stale and replayed messages. The RSA
+// plaintext is the string
+//
+//	v1:<unix seconds>:<base64url nonce>:<plaintext>
+//
+// which is easy to produce in the browser before calling JSEncrypt's
+// encrypt. The framing takes 37 bytes of the RSA message size limit.
+func (j *JSEncrypt) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, sealNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	framed := sealVersion + ":" + strconv.FormatInt(time.Now().Unix(), 10) + ":" +
+		base64.RawURLEncoding.EncodeToString(nonce) + ":" + plaintext
+	return j.Encrypt(framed)
+}
+
+// Open decrypts a message produced by Seal and returns the plaintext. It
+// returns ErrMessageExpired if the timestamp is outside the accepted window,
+// ErrReplayed if the nonce store has seen the nonce, and ErrDecryption for
+// anything that does not decrypt to a well formed sealed message.
+func (j *JSEncrypt) Open(sealed string, opts OpenOptions) (string, error) {
+	framed, err := j.Decrypt(sealed)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(framed, ":", 4)
+	if len(parts) != 4 || parts[0] != sealVersion {
+		return "", ErrDecryption
+	}
+	issued, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrDecryption
+	}
+	if nonce, err := base64.RawURLEncoding.DecodeString(parts[2]); err != nil || len(nonce) < sealNonceSize {
+		return "", ErrDecryption
+	}
+
+	maxAge, skew, now := opts.MaxAge, opts.ClockSkew, time.Now
+	if maxAge <= 0 {
+		maxAge = defaultSealMaxAge
+	}
+	if skew <= 0 {
+		skew = defaultSealClockSkew
+	}
+	if opts.Now != nil {
+		now = opts.Now
+	}
+
+	issuedAt := time.Unix(issued, 0)
+	current := now()
+	if current.Sub(issuedAt) > maxAge || issuedAt.Sub(current) > skew {
+		return "", ErrMessageExpired
+	}
+
+	if opts.Nonces != nil {
+		// Once the timestamp check rejects the message, the nonce no longer
+		// needs to be remembered.
+		fresh, err := opts.Nonces.CheckAndStore(parts[2], issuedAt.Add(maxAge))
+		if err != nil {
+			return "", err
+		}
+		if !fresh {
+			return "", ErrReplayed
+		}
+	}
+	return parts[3], nil
+}
@@ -0,0 +1,107 @@
+package jsencrypt
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJSEncrypt_SealOpen(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	nonces := NewMemoryNonceStore(100)
+	opts := OpenOptions{Nonces: nonces}
+
+	sealed, err := jsCrypt.Seal("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := jsCrypt.Open(sealed, opts)
+	if err != nil || plaintext != "hunter2" {
+		t.Fatalf("Open() = %q, %v", plaintext, err)
+	}
+	if _, err := jsCrypt.Open(sealed, opts); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected ErrReplayed for a second Open, got %v", err)
+	}
+
+	// Plain Encrypt output is not a sealed message.
+	unsealed, err := jsCrypt.Encrypt("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jsCrypt.Open(unsealed, opts); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for an unsealed message, got %v", err)
+	}
+}
+
+func TestJSEncrypt_OpenFreshness(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	now := time.Now()
+	seal := func(issued time.Time) string {
+		// Browser style framing, built by hand.
+		sealed, err := jsCrypt.Encrypt(fmt.Sprintf("v1:%d:AAAAAAAAAAAAAAAAAAAAAA:secret", issued.Unix()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+	opts := OpenOptions{MaxAge: time.Minute, ClockSkew: 10 * time.Second, Now: func() time.Time { return now }}
+
+	for name, tc := range map[string]struct {
+		issued time.Time
+		want   error
+	}{
+		"fresh":          {now.Add(-30 * time.Second), nil},
+		"expired":        {now.Add(-2 * time.Minute), ErrMessageExpired},
+		"slightly ahead": {now.Add(5 * time.Second), nil},
+		"far future":     {now.Add(time.Hour), ErrMessageExpired},
+	} {
+		plaintext, err := jsCrypt.Open(seal(tc.issued), opts)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+		if tc.want == nil && plaintext != "secret" {
+			t.Errorf("%s: plaintext = %q", name, plaintext)
+		}
+	}
+
+	for _, framed := range []string{
+		"v2:0:AAAAAAAAAAAAAAAAAAAAAA:secret",
+		"v1:soon:AAAAAAAAAAAAAAAAAAAAAA:secret",
+		"v1:0:short:secret",
+		"v1:0:AAAAAAAAAAAAAAAAAAAAAA",
+	} {
+		sealed, err := jsCrypt.Encrypt(framed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jsCrypt.Open(sealed, opts); !errors.Is(err, ErrDecryption) {
+			t.Errorf("%q: expected ErrDecryption, got %v", framed, err)
+		}
+	}
+}
+
+func TestJSEncrypt_OpenReplayAfterFlood(t *testing.T) {
+	jsCrypt := newTestKey(t, testPrivateKeys[3])
+	opts := OpenOptions{Nonces: NewMemoryNonceStore(4)}
+	captured, err := jsCrypt.Seal("transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jsCrypt.Open(captured, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone holding the public key can seal messages to push the captured
+	// nonce out of the store. Its replay must still be rejected.
+	for i := 0; i < 8; i++ {
+		flood, err := jsCrypt.Seal("flood")
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsCrypt.Open(flood, opts)
+	}
+	if _, err := jsCrypt.Open(captured, opts); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Replay after flooding the nonce store: got %v, want ErrReplayed", err)
+	}
+}
@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Dotenv files are a flat mapping of string values. Comments, blank lines
+// and "export" prefixes are kept, so an encrypted file still reads like the
+// original.
+
+var dotenvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// parseDotenv parses KEY=value lines. Values may be unquoted, single-quoted
+// (literal) or double-quoted (with \n, \r, \t, \" and \\ escapes); quoted
+// values may span lines.
+func parseDotenv(data []byte) (*node, error) {
+	text := strings.TrimPrefix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+	n := &node{kind: mappingNode}
+	var before []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			before = append(before, line)
+			continue
+		}
+		errorf := func(format string, args ...any) error {
+			return fmt.Errorf("%w: dotenv line %d: %s", ErrMalformed, i+1, fmt.Sprintf(format, args...))
+		}
+
+		e := entry{before: before}
+		before = nil
+		if rest := strings.TrimPrefix(trimmed, "export "); rest != trimmed {
+			e.export = true
+			trimmed = strings.TrimSpace(rest)
+		}
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			return nil, errorf("expected KEY=value")
+		}
+		e.key = strings.TrimSpace(key)
+		if !dotenvKey.MatchString(e.key) {
+			return nil, errorf("invalid key %q", e.key)
+		}
+		if n.lookup(e.key) >= 0 {
+			return nil, errorf("duplicate key %q", e.key)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = value[:j]
+			}
+			e.value = newScalar(strings.TrimSpace(value), typeString)
+			n.entries = append(n.entries, e)
+			continue
+		}
+
+		// Quoted values run until the closing quote, possibly on a later
+		// line.
+		quote := value[0]
+		value = value[1:]
+		var b strings.Builder
+		for {
+			end := -1
+			for j := 0; j < len(value); j++ {
+				c := value[j]
+				if c == quote {
+					end = j
+					break
+				}
+				if c == '\\' && quote == '"' && j+1 < len(value) {
+					j++
+					switch value[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 'r':
+						b.WriteByte('\r')
+					case 't':
+						b.WriteByte('\t')
+					case '"', '\\', '$':
+						b.WriteByte(value[j])
+					default:
+						b.WriteByte('\\')
+						b.WriteByte(value[j])
+					}
+					continue
+				}
+				b.WriteByte(c)
+			}
+			if end >= 0 {
+				rest := strings.TrimSpace(value[end+1:])
+				if rest != "" && rest[0] != '#' {
+					return nil, errorf("unexpected %q after quoted value", rest)
+				}
+				break
+			}
+			if i+1 == len(lines) {
+				return nil, errorf("unterminated quoted value for %s", e.key)
+			}
+			b.WriteByte('\n')
+			i++
+			value = lines[i]
+		}
+		e.value = newScalar(b.String(), typeString)
+		n.entries = append(n.entries, e)
+	}
+	n.after = before
+	return n, nil
+}
+
+// emitDotenv writes n as KEY=value lines.
+func emitDotenv(n *node) []byte {
+	var b strings.Builder
+	for _, e := range n.entries {
+		for _, line := range e.before {
+			b.WriteString(line + "\n")
+		}
+		if e.export {
+			b.WriteString("export ")
+		}
+		b.WriteString(e.key + "=" + dotenvValue(e.value.value) + "\n")
+	}
+	for _, line := range n.after {
+		b.WriteString(line + "\n")
+	}
+	return []byte(b.String())
+}
+
+var dotenvPlain = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=\[\]-]*$`)
+
+// dotenvValue quotes value if needed: single quotes where possible, since
+// they are literal in every dotenv implementation, and double quotes with
+// escapes otherwise.
+func dotenvValue(value string) string {
+	switch {
+	case dotenvPlain.MatchString(value):
+		return value
+	case !strings.ContainsAny(value, "'\n\r"):
+		return "'" + value + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "$", `\$`)
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	input := "A=plain # comment\n" +
+		"export B = 'single # kept \\n'\n" +
+		"C=\"double \\\"q\\\" \\n \\$HOME\" # comment\n" +
+		"D=\"multi\nline\"\n" +
+		"E=\n"
+	n, err := parseDotenv([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"A": "plain",
+		"B": `single # kept \n`,
+		"C": "double \"q\" \n $HOME",
+		"D": "multi\nline",
+		"E": "",
+	}
+	if got := toValue(n); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDotenv = %#v", got)
+	}
+	if !n.entries[1].export {
+		t.Error("export prefix was lost")
+	}
+
+	reparsed, err := parseDotenv(emitDotenv(n))
+	if err != nil || !reflect.DeepEqual(toValue(reparsed), want) {
+		t.Errorf("Round trip = %#v, %v\n%s", toValue(reparsed), err, emitDotenv(n))
+	}
+
+	for _, bad := range []string{"NOVALUE\n", "1A=x\n", "A=1\nA=2\n", "A=\"open\n", "A='x' y\n"} {
+		if _, err := parseDotenv([]byte(bad)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("parseDotenv(%q) = %v, want ErrMalformed", bad, err)
+		}
+	}
+}
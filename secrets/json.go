@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseJSON parses a JSON document, keeping the order of object members.
+func parseJSON(data []byte) (*node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeJSON(dec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after the JSON value", ErrMalformed)
+	}
+	return n, nil
+}
+
+func decodeJSON(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			n := &node{kind: sequenceNode}
+			for dec.More() {
+				item, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+			_, err := dec.Token()
+			return n, err
+		}
+		n := &node{kind: mappingNode}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			if n.lookup(key) >= 0 {
+				return nil, fmt.Errorf("duplicate key %q", key)
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.entries = append(n.entries, entry{key: key, value: value})
+		}
+		_, err := dec.Token()
+		return n, err
+	case string:
+		return newScalar(t, typeString), nil
+	case json.Number:
+		if strings.ContainsAny(string(t), ".eE") {
+			return newScalar(string(t), typeFloat), nil
+		}
+		return newScalar(string(t), typeInt), nil
+	case bool:
+		return newScalar(fmt.Sprint(t), typeBool), nil
+	}
+	return newScalar("null", typeNull), nil
+}
+
+// emitJSON writes n indented by two spaces, like json.MarshalIndent.
+func emitJSON(n *node) []byte {
+	var b bytes.Buffer
+	writeJSON(&b, n, "")
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func writeJSON(b *bytes.Buffer, n *node, indent string) {
+	switch n.kind {
+	case mappingNode:
+		if len(n.entries) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{\n")
+		for i, e := range n.entries {
+			b.WriteString(indent + "  " + jsonString(e.key) + ": ")
+			writeJSON(b, e.value, indent+"  ")
+			if i < len(n.entries)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+	case sequenceNode:
+		if len(n.items) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[\n")
+		for i, item := range n.items {
+			b.WriteString(indent + "  ")
+			writeJSON(b, item, indent+"  ")
+			if i < len(n.items)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "]")
+	default:
+		if n.typ == typeString {
+			b.WriteString(jsonString(n.value))
+		} else {
+			b.WriteString(n.value)
+		}
+	}
+}
+
+// jsonString quotes s as a JSON string without escaping HTML characters.
+// The result is also a valid YAML double-quoted scalar.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,533 @@
+// Package secrets encrypts configuration files in the style of sops: only
+// the values of a JSON, YAML or dotenv file are encrypted, so keys and
+// structure stay readable and diffs stay meaningful.
+//
+// Each value becomes ENC[v1,<type>,<nonce>,<ciphertext>] under AES-256-GCM
+// with a random data key, bound to its path in the document so values
+// cannot be moved around. The data key is wrapped with RSA-OAEP-SHA256 for
+// every recipient and stored in the file with the recipient's KeyID, next to
+// an HMAC-SHA256 over the whole document that detects added, removed or
+// reordered values. Applications decrypt the file at startup into a map or
+// a struct:
+//
+//	var cfg Config
+//	err := (&secrets.Decrypter{Key: key}).LoadFile("config.enc.yaml", &cfg)
+package secrets
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+var (
+	// ErrMalformed is returned for a document that cannot be parsed, or
+	// whose encrypted values or metadata are not well formed.
+	ErrMalformed = errors.New("secrets: malformed document")
+	// ErrMAC is returned when the document was modified after encryption.
+	ErrMAC = errors.New("secrets: document MAC mismatch")
+	// ErrNotEncrypted is returned when decrypting a document without
+	// jsencrypt metadata.
+	ErrNotEncrypted = errors.New("secrets: document is not encrypted")
+	// ErrAlreadyEncrypted is returned when encrypting a document that
+	// already has jsencrypt metadata.
+	ErrAlreadyEncrypted = errors.New("secrets: document is already encrypted")
+	// ErrNoRecipients is returned by Encrypt without recipients.
+	ErrNoRecipients = errors.New("secrets: no recipients")
+)
+
+// Format is the syntax of a secrets file.
+type Format int
+
+const (
+	JSON Format = iota + 1
+	YAML
+	// Dotenv files hold KEY=value lines; all values are strings.
+	Dotenv
+)
+
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "json"
+	case YAML:
+		return "yaml"
+	case Dotenv:
+		return "dotenv"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseFormat returns the format named "json", "yaml" ("yml") or "dotenv"
+// ("env").
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return JSON, nil
+	case "yaml", "yml":
+		return YAML, nil
+	case "dotenv", "env":
+		return Dotenv, nil
+	}
+	return 0, fmt.Errorf("secrets: unknown format %q", name)
+}
+
+// FormatFromPath returns the format of a file by its extension: .json,
+// .yaml, .yml and .env, or a name starting with ".env" such as
+// ".env.production".
+func FormatFromPath(path string) (Format, error) {
+	base := filepath.Base(path)
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".env":
+		return Dotenv, nil
+	}
+	if strings.HasPrefix(base, ".env") {
+		return Dotenv, nil
+	}
+	return 0, fmt.Errorf("secrets: cannot tell the format of %s", path)
+}
+
+func parse(data []byte, format Format) (*node, error) {
+	var root *node
+	var err error
+	switch format {
+	case JSON:
+		root, err = parseJSON(data)
+	case YAML:
+		root, err = parseYAML(data)
+	case Dotenv:
+		root, err = parseDotenv(data)
+	default:
+		return nil, fmt.Errorf("secrets: unknown format %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != mappingNode {
+		return nil, fmt.Errorf("%w: the top level must be a mapping", ErrMalformed)
+	}
+	return root, nil
+}
+
+func marshal(root *node, format Format) []byte {
+	switch format {
+	case JSON:
+		return emitJSON(root)
+	case YAML:
+		return emitYAML(root)
+	}
+	return emitDotenv(root)
+}
+
+const (
+	version = 1
+	// metadataKey holds the metadata of JSON and YAML files. Dotenv files
+	// use the flat JSENCRYPT_* keys instead.
+	metadataKey          = "jsencrypt"
+	dotenvVersionKey     = "JSENCRYPT_VERSION"
+	dotenvRecipientsKey  = "JSENCRYPT_RECIPIENTS"
+	dotenvMACKey         = "JSENCRYPT_MAC"
+	dataKeySize          = 32
+	encryptedValuePrefix = "ENC[v1,"
+)
+
+var b64 = base64.RawURLEncoding
+
+type recipient struct {
+	kid string
+	// key is the data key wrapped with RSA-OAEP-SHA256.
+	key string
+}
+
+type metadata struct {
+	version    int
+	recipients []recipient
+	mac        string
+}
+
+// Encrypt encrypts every value of data for recipients. Each recipient's
+// private key can decrypt the result on its own. Null values are left as
+// they are; a document that is already encrypted is rejected, so decrypt it
+// first to change values or recipients.
+func Encrypt(data []byte, format Format, recipients ...*jsencrypt.JSEncrypt) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	root, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if hasMetadata(root, format) {
+		return nil, ErrAlreadyEncrypted
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+	aead, macKey, err := deriveKeys(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := metadata{version: version}
+	for _, r := range recipients {
+		kid, err := r.KeyID()
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := r.WrapContentKey(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		meta.recipients = append(meta.recipients, recipient{kid: kid, key: b64.EncodeToString(wrapped)})
+	}
+
+	err = walk(root, "", func(path string, n *node) error {
+		if n.kind != scalarNode || n.typ == typeNull {
+			return nil
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		ciphertext := aead.Seal(nil, nonce, []byte(n.value), valueAAD(path, n.typ))
+		n.value = encryptedValuePrefix + string(n.typ) + "," + b64.EncodeToString(nonce) + "," + b64.EncodeToString(ciphertext) + "]"
+		n.typ = typeString
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta.mac = computeMAC(macKey, root, meta.recipients)
+	setMetadata(root, format, meta)
+	return marshal(root, format), nil
+}
+
+// KeySet looks up decryption keys by key ID. *jsencrypt.Keyring implements
+// it.
+type KeySet interface {
+	Get(kid string) (*jsencrypt.JSEncrypt, bool)
+}
+
+// Decrypter decrypts secrets files with a private key.
+type Decrypter struct {
+	// Keys selects the private key by the key IDs of the file's recipients.
+	Keys KeySet
+	// Key decrypts files that list its KeyID as a recipient, when Keys is
+	// nil or has none of them.
+	Key *jsencrypt.JSEncrypt
+}
+
+// Decrypt decrypts data and returns the plaintext document in the same
+// format, without the jsencrypt metadata. It returns
+// jsencrypt.ErrUnknownKeyID if no recipient's key is available,
+// jsencrypt.ErrDecryption if the data key or a value does not decrypt, and
+// ErrMAC if the document was modified.
+func (d *Decrypter) Decrypt(data []byte, format Format) ([]byte, error) {
+	root, err := d.decrypt(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(root, format), nil
+}
+
+// Load decrypts data into a map, with the values encoding/json would
+// produce for the document, except that integers are int64.
+func (d *Decrypter) Load(data []byte, format Format) (map[string]any, error) {
+	root, err := d.decrypt(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return toValue(root).(map[string]any), nil
+}
+
+// Unmarshal decrypts data into v as encoding/json would unmarshal the
+// document, so struct fields are matched by their json tags.
+func (d *Decrypter) Unmarshal(data []byte, format Format, v any) error {
+	values, err := d.Load(data, format)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+// LoadFile reads and decrypts the file at path into v, taking the format
+// from the file name as FormatFromPath does.
+func (d *Decrypter) LoadFile(path string, v any) error {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return d.Unmarshal(data, format, v)
+}
+
+func (d *Decrypter) decrypt(data []byte, format Format) (*node, error) {
+	root, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := takeMetadata(root, format)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := d.dataKey(meta.recipients)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+	aead, macKey, err := deriveKeys(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := b64.DecodeString(meta.mac)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid MAC", ErrMalformed)
+	}
+	want, _ := b64.DecodeString(computeMAC(macKey, root, meta.recipients))
+	if !hmac.Equal(mac, want) {
+		return nil, ErrMAC
+	}
+
+	err = walk(root, "", func(path string, n *node) error {
+		if n.kind != scalarNode || n.typ == typeNull {
+			return nil
+		}
+		if n.typ != typeString || !strings.HasPrefix(n.value, encryptedValuePrefix) || !strings.HasSuffix(n.value, "]") {
+			return fmt.Errorf("%w: %s is not encrypted", ErrMalformed, path)
+		}
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(n.value, encryptedValuePrefix), "]"), ",")
+		if len(fields) != 3 {
+			return fmt.Errorf("%w: invalid encrypted value at %s", ErrMalformed, path)
+		}
+		typ := scalarType(fields[0])
+		nonce, err1 := b64.DecodeString(fields[1])
+		ciphertext, err2 := b64.DecodeString(fields[2])
+		if err1 != nil || err2 != nil || len(nonce) != aead.NonceSize() {
+			return fmt.Errorf("%w: invalid encrypted value at %s", ErrMalformed, path)
+		}
+		plaintext, err := aead.Open(nil, nonce, ciphertext, valueAAD(path, typ))
+		if err != nil {
+			return jsencrypt.ErrDecryption
+		}
+		n.value, n.typ = string(plaintext), typ
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// dataKey unwraps the data key with the first recipient whose key is
+// available.
+func (d *Decrypter) dataKey(recipients []recipient) ([]byte, error) {
+	err := jsencrypt.ErrUnknownKeyID
+	for _, r := range recipients {
+		key := d.key(r.kid)
+		if key == nil {
+			continue
+		}
+		wrapped, decodeErr := b64.DecodeString(r.key)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("%w: invalid wrapped key", ErrMalformed)
+		}
+		dataKey, decryptErr := key.UnwrapContentKey(wrapped)
+		if decryptErr == nil {
+			return dataKey, nil
+		}
+		err = jsencrypt.ErrDecryption
+	}
+	return nil, err
+}
+
+func (d *Decrypter) key(kid string) *jsencrypt.JSEncrypt {
+	if d.Keys != nil {
+		if key, ok := d.Keys.Get(kid); ok {
+			return key
+		}
+	}
+	if d.Key != nil {
+		if id, err := d.Key.KeyID(); err == nil && id == kid {
+			return d.Key
+		}
+	}
+	return nil
+}
+
+// deriveKeys derives the AES-256-GCM value key and the MAC key from the
+// data key.
+func deriveKeys(dataKey []byte) (cipher.AEAD, []byte, error) {
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, dataKey)
+		h.Write([]byte("jsencrypt secrets v1 " + label))
+		return h.Sum(nil)
+	}
+	encKey := derive("encryption")
+	defer wipe(encKey)
+	aead, err := jsencrypt.NewContentCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, derive("mac"), nil
+}
+
+// valueAAD binds an encrypted value to its path and type.
+func valueAAD(path string, typ scalarType) []byte {
+	return []byte(path + "\x00" + string(typ))
+}
+
+// computeMAC authenticates the recipients and every node of the document
+// in order, so values cannot be added, removed, reordered or swapped
+// between files.
+func computeMAC(macKey []byte, root *node, recipients []recipient) string {
+	h := hmac.New(sha256.New, macKey)
+	writeField := func(s string) {
+		h.Write([]byte(strconv.Itoa(len(s)) + ":" + s))
+	}
+	for _, r := range recipients {
+		writeField("recipient")
+		writeField(r.kid)
+		writeField(r.key)
+	}
+	walk(root, "", func(path string, n *node) error {
+		writeField(path)
+		switch n.kind {
+		case mappingNode:
+			writeField("mapping")
+		case sequenceNode:
+			writeField("sequence")
+		default:
+			writeField(string(n.typ))
+			writeField(n.value)
+		}
+		return nil
+	})
+	return b64.EncodeToString(h.Sum(nil))
+}
+
+func hasMetadata(root *node, format Format) bool {
+	if format == Dotenv {
+		return root.lookup(dotenvMACKey) >= 0 || root.lookup(dotenvRecipientsKey) >= 0
+	}
+	return root.lookup(metadataKey) >= 0
+}
+
+func setMetadata(root *node, format Format, meta metadata) {
+	if format == Dotenv {
+		kids := make([]string, len(meta.recipients))
+		for i, r := range meta.recipients {
+			kids[i] = r.kid + "." + r.key
+		}
+		root.entries = append(root.entries,
+			entry{key: dotenvVersionKey, value: newScalar(strconv.Itoa(meta.version), typeString)},
+			entry{key: dotenvRecipientsKey, value: newScalar(strings.Join(kids, ","), typeString)},
+			entry{key: dotenvMACKey, value: newScalar(meta.mac, typeString)},
+		)
+		return
+	}
+	list := &node{kind: sequenceNode}
+	for _, r := range meta.recipients {
+		list.items = append(list.items, &node{kind: mappingNode, entries: []entry{
+			{key: "kid", value: newScalar(r.kid, typeString)},
+			{key: "key", value: newScalar(r.key, typeString)},
+		}})
+	}
+	root.entries = append(root.entries, entry{key: metadataKey, value: &node{kind: mappingNode, entries: []entry{
+		{key: "version", value: newScalar(strconv.Itoa(meta.version), typeInt)},
+		{key: "recipients", value: list},
+		{key: "mac", value: newScalar(meta.mac, typeString)},
+	}}})
+}
+
+// takeMetadata removes the metadata from root and returns it.
+func takeMetadata(root *node, format Format) (metadata, error) {
+	var meta metadata
+	malformed := fmt.Errorf("%w: invalid jsencrypt metadata", ErrMalformed)
+	if !hasMetadata(root, format) {
+		return meta, ErrNotEncrypted
+	}
+
+	if format == Dotenv {
+		versionNode, recipients, mac := root.remove(dotenvVersionKey), root.remove(dotenvRecipientsKey), root.remove(dotenvMACKey)
+		if versionNode == nil || recipients == nil || mac == nil {
+			return meta, malformed
+		}
+		meta.version, _ = strconv.Atoi(versionNode.value)
+		for _, field := range strings.Split(recipients.value, ",") {
+			kid, key, ok := strings.Cut(field, ".")
+			if !ok {
+				return meta, malformed
+			}
+			meta.recipients = append(meta.recipients, recipient{kid: kid, key: key})
+		}
+		meta.mac = mac.value
+	} else {
+		m := root.remove(metadataKey)
+		scalar := func(n *node, key string) (string, bool) {
+			if i := n.lookup(key); i >= 0 && n.entries[i].value.kind == scalarNode {
+				return n.entries[i].value.value, true
+			}
+			return "", false
+		}
+		if m.kind != mappingNode {
+			return meta, malformed
+		}
+		versionText, ok1 := scalar(m, "version")
+		mac, ok2 := scalar(m, "mac")
+		i := m.lookup("recipients")
+		if !ok1 || !ok2 || i < 0 || m.entries[i].value.kind != sequenceNode {
+			return meta, malformed
+		}
+		meta.version, _ = strconv.Atoi(versionText)
+		meta.mac = mac
+		for _, item := range m.entries[i].value.items {
+			if item.kind != mappingNode {
+				return meta, malformed
+			}
+			kid, ok1 := scalar(item, "kid")
+			key, ok2 := scalar(item, "key")
+			if !ok1 || !ok2 {
+				return meta, malformed
+			}
+			meta.recipients = append(meta.recipients, recipient{kid: kid, key: key})
+		}
+	}
+	if meta.version != version {
+		return meta, fmt.Errorf("%w: unsupported version %d", ErrMalformed, meta.version)
+	}
+	if len(meta.recipients) == 0 {
+		return meta, malformed
+	}
+	return meta, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,244 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+	"github.com/gmodx/go-jsencrypt/internal/testkeys"
+)
+
+const testJSON = `{
+  "database": {
+    "user": "app",
+    "password": "s3cr3t <&>",
+    "port": 5432,
+    "ratio": 0.5,
+    "tls": true,
+    "replica": null
+  },
+  "tokens": [
+    "a",
+    "b"
+  ],
+  "empty": {}
+}
+`
+
+const testYAML = `# Connection settings
+database:
+  user: app
+  password: "s3cr3t: #1"
+  port: 5432
+  ratio: 0.5
+  tls: true
+  replica: null
+tokens:
+  # current
+  - a
+  - b
+certificate: |
+  -----BEGIN CERTIFICATE-----
+  MIIB
+  -----END CERTIFICATE-----
+# trailing comment
+`
+
+const testDotenv = `# Database
+export DB_USER=app
+DB_PASSWORD='pa ss#word'
+
+API_KEY="line1\nline2"
+# trailing comment
+`
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	d := &Decrypter{Key: key}
+	tests := []struct {
+		format Format
+		input  string
+		secret string
+	}{
+		{JSON, testJSON, "s3cr3t"},
+		{YAML, testYAML, "s3cr3t"},
+		{Dotenv, testDotenv, "pa ss"},
+	}
+	for _, test := range tests {
+		encrypted, err := Encrypt([]byte(test.input), test.format, key)
+		if err != nil {
+			t.Fatalf("%v: Encrypt: %v", test.format, err)
+		}
+		if strings.Contains(string(encrypted), test.secret) {
+			t.Errorf("%v: the encrypted document contains a plaintext value:\n%s", test.format, encrypted)
+		}
+		decrypted, err := d.Decrypt(encrypted, test.format)
+		if err != nil {
+			t.Fatalf("%v: Decrypt: %v\n%s", test.format, err, encrypted)
+		}
+		if string(decrypted) != test.input {
+			t.Errorf("%v: Decrypt =\n%s\nwant\n%s", test.format, decrypted, test.input)
+		}
+	}
+}
+
+func TestEncrypt_KeysStayReadable(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	encrypted, err := Encrypt([]byte(testYAML), YAML, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := key.KeyID()
+	for _, want := range []string{"# Connection settings\ndatabase:\n", "  # current\n  - ENC[", "  password: ENC[v1,str,", "  port: ENC[v1,int,", "  replica: null\n", "  - ENC[v1,str,", "kid: " + kid} {
+		if !strings.Contains(string(encrypted), want) {
+			t.Errorf("Encrypted YAML lacks %q:\n%s", want, encrypted)
+		}
+	}
+
+	encrypted, err = Encrypt([]byte(testDotenv), Dotenv, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Database\nexport DB_USER=ENC[v1,str,", "JSENCRYPT_RECIPIENTS=" + kid + ".", "JSENCRYPT_MAC="} {
+		if !strings.Contains(string(encrypted), want) {
+			t.Errorf("Encrypted dotenv lacks %q:\n%s", want, encrypted)
+		}
+	}
+
+	if _, err := Encrypt(encrypted, Dotenv, key); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("Encrypting twice: %v", err)
+	}
+	if _, err := Encrypt([]byte(testJSON), JSON); !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("No recipients: %v", err)
+	}
+}
+
+func TestDecrypter_Load(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	encrypted, err := Encrypt([]byte(testYAML), YAML, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := (&Decrypter{Key: key}).Load(encrypted, YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"database": map[string]any{
+			"user": "app", "password": "s3cr3t: #1", "port": int64(5432),
+			"ratio": 0.5, "tls": true, "replica": nil,
+		},
+		"tokens":      []any{"a", "b"},
+		"certificate": "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Load = %#v", values)
+	}
+}
+
+func TestDecrypter_LoadFile(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	encrypted, err := Encrypt([]byte(testJSON), JSON, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.enc.json")
+	if err := os.WriteFile(path, encrypted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Database struct {
+			User     string
+			Password string
+			Port     int
+			TLS      bool
+		}
+		Tokens []string
+	}
+	if err := (&Decrypter{Key: key}).LoadFile(path, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Database.Password != "s3cr3t <&>" || config.Database.Port != 5432 || !config.Database.TLS || len(config.Tokens) != 2 {
+		t.Errorf("LoadFile = %+v", config)
+	}
+}
+
+func TestDecrypter_Recipients(t *testing.T) {
+	alice, bob, eve := testkeys.Key(t, 0), testkeys.Key(t, 1), testkeys.Key(t, 2)
+	encrypted, err := Encrypt([]byte(testDotenv), Dotenv, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := jsencrypt.NewKeyring()
+	if _, err := ring.Add(bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Decrypter{Keys: ring}).Decrypt(encrypted, Dotenv); err != nil {
+		t.Errorf("Decrypt with the second recipient's keyring: %v", err)
+	}
+	if _, err := (&Decrypter{Key: alice}).Decrypt(encrypted, Dotenv); err != nil {
+		t.Errorf("Decrypt with the first recipient: %v", err)
+	}
+	if _, err := (&Decrypter{Key: eve}).Decrypt(encrypted, Dotenv); !errors.Is(err, jsencrypt.ErrUnknownKeyID) {
+		t.Errorf("Decrypt with another key: %v", err)
+	}
+	if _, err := (&Decrypter{Key: alice}).Decrypt([]byte(testDotenv), Dotenv); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Decrypt of a plaintext file: %v", err)
+	}
+}
+
+func TestDecrypter_Tampering(t *testing.T) {
+	key := testkeys.Key(t, 0)
+	d := &Decrypter{Key: key}
+	input := "a: one\nb: two\nc: [1, 2]\n"
+	encrypted, err := Encrypt([]byte(input), YAML, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(encrypted), "\n")
+	valueA := strings.TrimPrefix(lines[0], "a: ")
+	valueB := strings.TrimPrefix(lines[1], "b: ")
+
+	tests := map[string]string{
+		"swapped values":  strings.Replace(strings.Replace(string(encrypted), valueA, "X", 1), valueB+"\n", valueA+"\n", 1),
+		"removed value":   strings.Replace(string(encrypted), lines[1]+"\n", "", 1),
+		"added value":     "d: plain\n" + string(encrypted),
+		"reordered items": strings.Replace(string(encrypted), lines[3]+"\n"+lines[4], lines[4]+"\n"+lines[3], 1),
+		"changed null":    strings.Replace(string(encrypted), "a: ", "a: null\nz: ", 1),
+	}
+	for name, tampered := range tests {
+		if _, err := d.Decrypt([]byte(tampered), YAML); !errors.Is(err, ErrMAC) {
+			t.Errorf("%s: Decrypt = %v, want ErrMAC", name, err)
+		}
+	}
+
+	flipped := []byte(strings.Replace(string(encrypted), valueA, valueA[:len(valueA)-3]+"AA]", 1))
+	if _, err := d.Decrypt(flipped, YAML); !errors.Is(err, ErrMAC) {
+		t.Errorf("Modified ciphertext: %v", err)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := map[string]Format{
+		"config.json":     JSON,
+		"config.enc.yaml": YAML,
+		"x/settings.YML":  YAML,
+		".env":            Dotenv,
+		".env.production": Dotenv,
+		"deploy/prod.env": Dotenv,
+	}
+	for path, want := range tests {
+		if got, err := FormatFromPath(path); err != nil || got != want {
+			t.Errorf("FormatFromPath(%q) = %v, %v", path, got, err)
+		}
+	}
+	if _, err := FormatFromPath("config.toml"); err == nil {
+		t.Error("FormatFromPath accepted .toml")
+	}
+}
@@ -0,0 +1,183 @@
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The three formats are parsed into the same order preserving tree, so
+// encryption, the MAC and the conversion to Go values are format agnostic.
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+// scalarType is the type of a scalar, recorded in encrypted values so it
+// can be restored on decryption.
+type scalarType string
+
+const (
+	typeString scalarType = "str"
+	typeInt    scalarType = "int"
+	typeFloat  scalarType = "float"
+	typeBool   scalarType = "bool"
+	typeNull   scalarType = "null"
+)
+
+type node struct {
+	kind nodeKind
+	// value is the lexical form of a scalar, e.g. "0x1F" for a YAML int.
+	value string
+	typ   scalarType
+
+	entries []entry // mapping
+	items   []*node // sequence
+	// before holds the YAML comment and blank lines before a sequence item.
+	before []string
+	// after holds the comment and blank lines after the last entry of the
+	// document.
+	after []string
+}
+
+type entry struct {
+	key   string
+	value *node
+	// before holds the comment and blank lines before the entry: verbatim
+	// for dotenv, without indentation for YAML.
+	before []string
+	// export records a dotenv "export " prefix.
+	export bool
+}
+
+func newScalar(value string, typ scalarType) *node {
+	return &node{kind: scalarNode, value: value, typ: typ}
+}
+
+// lookup returns the index of key in a mapping, or -1.
+func (n *node) lookup(key string) int {
+	for i, e := range n.entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove deletes key from a mapping and returns its value.
+func (n *node) remove(key string) *node {
+	i := n.lookup(key)
+	if i < 0 {
+		return nil
+	}
+	value := n.entries[i].value
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+	return value
+}
+
+// walk calls fn for n and every node below it in document order, with its
+// RFC 6901 JSON Pointer path.
+func walk(n *node, path string, fn func(path string, n *node) error) error {
+	if err := fn(path, n); err != nil {
+		return err
+	}
+	switch n.kind {
+	case mappingNode:
+		for _, e := range n.entries {
+			if err := walk(e.value, path+"/"+pointerEscaper.Replace(e.key), fn); err != nil {
+				return err
+			}
+		}
+	case sequenceNode:
+		for i, item := range n.items {
+			if err := walk(item, path+"/"+strconv.Itoa(i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// toValue converts n to map[string]any, []any, string, int64, float64, bool
+// or nil, as encoding/json would decode it.
+func toValue(n *node) any {
+	switch n.kind {
+	case mappingNode:
+		m := make(map[string]any, len(n.entries))
+		for _, e := range n.entries {
+			m[e.key] = toValue(e.value)
+		}
+		return m
+	case sequenceNode:
+		s := make([]any, len(n.items))
+		for i, item := range n.items {
+			s[i] = toValue(item)
+		}
+		return s
+	}
+	switch n.typ {
+	case typeNull:
+		return nil
+	case typeBool:
+		return strings.EqualFold(n.value, "true")
+	case typeInt:
+		if i, err := parseInt(n.value); err == nil {
+			return i
+		}
+		return parseFloat(n.value)
+	case typeFloat:
+		return parseFloat(n.value)
+	}
+	return n.value
+}
+
+func parseInt(s string) (int64, error) {
+	switch {
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0o"):
+		return strconv.ParseInt(s, 0, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseFloat(s string) float64 {
+	switch strings.ToLower(strings.TrimLeft(s, "+")) {
+	case ".inf":
+		return math.Inf(1)
+	case "-.inf":
+		return math.Inf(-1)
+	case ".nan":
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// YAML 1.2 core schema resolution of plain scalars.
+var (
+	yamlInt   = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yamlFloat = regexp.MustCompile(`^([-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?|[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// resolvePlain returns the type of a plain (unquoted) YAML scalar.
+func resolvePlain(s string) scalarType {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return typeNull
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return typeBool
+	}
+	if yamlInt.MatchString(s) {
+		return typeInt
+	}
+	if yamlFloat.MatchString(s) {
+		return typeFloat
+	}
+	return typeString
+}
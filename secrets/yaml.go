@@ -0,0 +1,742 @@
+package secrets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The YAML support covers what configuration files use: block mappings and
+// sequences, plain and quoted scalars, literal and folded block scalars,
+// flow collections and comments. Anchors, aliases, tags and multiple
+// documents are rejected rather than silently lost. Comment and blank lines
+// are kept with the entry or sequence item that follows them, or at the end
+// of the document; comments at the end of a value line are dropped.
+
+type yamlParser struct {
+	lines []string
+	pos   int
+	// comments holds the comment and blank lines skipped since the last
+	// entry or sequence item.
+	comments []string
+}
+
+// parseYAML parses a single YAML document. An empty document is an empty
+// mapping.
+func parseYAML(data []byte) (*node, error) {
+	text := strings.TrimPrefix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff")
+	p := &yamlParser{lines: strings.Split(strings.TrimSuffix(text, "\n"), "\n")}
+	if _, content, ok, err := p.peek(); err != nil {
+		return nil, err
+	} else if ok && (content == "---" || strings.HasPrefix(content, "--- ")) {
+		if rest := stripComment(strings.TrimSpace(content[3:])); rest != "" {
+			return nil, p.errorf("content on the document start line is not supported")
+		}
+		p.pos++
+	}
+
+	root, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, content, ok, err := p.peek(); err != nil {
+		return nil, err
+	} else if ok {
+		switch {
+		case content == "...":
+			p.pos++
+			if _, _, ok, _ := p.peek(); ok {
+				return nil, p.errorf("multiple documents are not supported")
+			}
+		case strings.HasPrefix(content, "---"):
+			return nil, p.errorf("multiple documents are not supported")
+		default:
+			return nil, p.errorf("unexpected content")
+		}
+	}
+	if root == nil {
+		root = &node{kind: mappingNode}
+	}
+	root.after = p.takeComments()
+	return root, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: yaml line %d: %s", ErrMalformed, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// peek skips blank and comment lines, collecting them for takeComments, and
+// returns the indentation and content of the next line, without consuming
+// it.
+func (p *yamlParser) peek() (int, string, bool, error) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		content := strings.TrimLeft(line, " ")
+		if trimmed := strings.TrimSpace(content); trimmed == "" || trimmed[0] == '#' {
+			p.comments = append(p.comments, trimmed)
+			continue
+		}
+		if content[0] == '\t' {
+			return 0, "", false, p.errorf("tabs cannot be used for indentation")
+		}
+		return len(line) - len(content), strings.TrimRight(content, " \t"), true, nil
+	}
+	return 0, "", false, nil
+}
+
+// takeComments returns the comment and blank lines collected by peek.
+func (p *yamlParser) takeComments() []string {
+	comments := p.comments
+	p.comments = nil
+	return comments
+}
+
+// parseBlock parses the node starting on the next line, if that line is
+// indented by at least minIndent. It returns nil if there is no such node.
+func (p *yamlParser) parseBlock(minIndent int) (*node, error) {
+	indent, content, ok, err := p.peek()
+	if err != nil || !ok || indent < minIndent {
+		return nil, err
+	}
+	if isSequenceItem(content) {
+		return p.parseSequence(indent)
+	}
+	if _, _, isKey, err := p.splitKey(content); err != nil {
+		return nil, err
+	} else if isKey {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return p.parseValue(indent-1, content)
+}
+
+func (p *yamlParser) parseMapping(indent int) (*node, error) {
+	n := &node{kind: mappingNode}
+	for {
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || ind < indent || content == "..." || strings.HasPrefix(content, "---") && ind == 0 {
+			return n, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, isKey, err := p.splitKey(content)
+		if err != nil {
+			return nil, err
+		}
+		if !isKey {
+			return nil, p.errorf("expected a mapping key")
+		}
+		if n.lookup(key) >= 0 {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		before := p.takeComments()
+		p.pos++
+
+		var value *node
+		if rest == "" {
+			// A sequence may be indented as much as its key.
+			if ind, content, ok, err := p.peek(); err != nil {
+				return nil, err
+			} else if ok && ind == indent && isSequenceItem(content) {
+				value, err = p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if value == nil {
+			if value, err = p.parseValue(indent, rest); err != nil {
+				return nil, err
+			}
+		}
+		n.entries = append(n.entries, entry{key: key, value: value, before: before})
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) (*node, error) {
+	n := &node{kind: sequenceNode}
+	for {
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || ind < indent || !isSequenceItem(content) {
+			return n, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		rest := strings.TrimLeft(content[1:], " ")
+		itemIndent := indent + len(content) - len(rest)
+		before := p.takeComments()
+
+		var item *node
+		_, _, isKey, err := p.splitKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		if isSequenceItem(rest) || isKey {
+			// A compact nested collection: parse the rest of the line as
+			// if it started a line of its own at the item's indentation.
+			p.lines[p.pos] = strings.Repeat(" ", itemIndent) + rest
+			item, err = p.parseBlock(itemIndent)
+		} else {
+			p.pos++
+			item, err = p.parseValue(indent, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.before = before
+		n.items = append(n.items, item)
+	}
+}
+
+// parseValue parses the value following a key or sequence indicator at
+// indentation parent: rest of that line, or the block below it.
+func (p *yamlParser) parseValue(parent int, rest string) (*node, error) {
+	rest = strings.TrimSpace(stripComment(rest))
+	switch {
+	case rest == "":
+		n, err := p.parseBlock(parent + 1)
+		if n == nil && err == nil {
+			n = newScalar("null", typeNull)
+		}
+		return n, err
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(parent, rest)
+	}
+	n, err := p.parseInline(rest)
+	if err != nil {
+		return nil, err
+	}
+	if ind, _, ok, err := p.peek(); err != nil {
+		return nil, err
+	} else if ok && ind > parent && n.kind == scalarNode {
+		return nil, p.errorf("multi-line scalars must use a | or > block scalar")
+	}
+	return n, nil
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar whose
+// header has already been consumed.
+func (p *yamlParser) parseBlockScalar(parent int, header string) (*node, error) {
+	chomp, indent := byte(0), 0
+	for _, c := range header[1:] {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = byte(c)
+		case c >= '1' && c <= '9' && indent == 0:
+			indent = parent + int(c-'0')
+			if parent < 0 {
+				indent = int(c - '0')
+			}
+		default:
+			return nil, p.errorf("invalid block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		ind := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			if ind <= parent {
+				break
+			}
+			indent = ind
+		}
+		if ind < indent {
+			break
+		}
+		lines = append(lines, line[indent:])
+	}
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+
+	var text string
+	if header[0] == '|' {
+		text = strings.Join(body, "\n")
+	} else {
+		text = fold(body)
+	}
+	switch {
+	case chomp == '+':
+		if len(body) > 0 {
+			text += "\n"
+		}
+		text += strings.Repeat("\n", trailing)
+	case chomp == 0 && len(body) > 0:
+		text += "\n"
+	}
+	return newScalar(text, typeString), nil
+}
+
+// fold joins the lines of a folded block scalar.
+func fold(lines []string) string {
+	moreIndented := func(s string) bool { return s != "" && (s[0] == ' ' || s[0] == '\t') }
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case l == "":
+				b.WriteByte('\n')
+			case prev == "" && moreIndented(l):
+				b.WriteByte('\n')
+			case prev == "":
+			case moreIndented(prev) || moreIndented(l):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// parseInline parses a scalar or flow collection that fits on one line.
+func (p *yamlParser) parseInline(s string) (*node, error) {
+	if !strings.ContainsRune("[{\"'&*!@`%", rune(s[0])) {
+		// Outside flow collections, plain scalars may contain ",[]{}".
+		return newScalar(s, resolvePlain(s)), nil
+	}
+	f := &flowParser{p: p, s: s}
+	n, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpaces()
+	if f.i < len(s) {
+		return nil, p.errorf("unexpected %q after value", s[f.i:])
+	}
+	return n, nil
+}
+
+// splitKey splits a "key: value" line. It reports false if content is not a
+// mapping entry.
+func (p *yamlParser) splitKey(content string) (string, string, bool, error) {
+	if content == "" {
+		return "", "", false, nil
+	}
+	switch content[0] {
+	case '"', '\'':
+		key, end, err := p.quoted(content)
+		if err != nil {
+			return "", "", false, err
+		}
+		rest := strings.TrimLeft(content[end:], " ")
+		if !strings.HasPrefix(rest, ":") || len(rest) > 1 && rest[1] != ' ' {
+			return "", "", false, nil
+		}
+		return key, rest[1:], true, nil
+	case '[', '{', '#', '|', '>':
+		return "", "", false, nil
+	case '?':
+		if content == "?" || content[1] == ' ' {
+			return "", "", false, p.errorf("complex mapping keys are not supported")
+		}
+	}
+	for i := 0; i < len(content); i++ {
+		switch {
+		case content[i] == '#' && i > 0 && content[i-1] == ' ':
+			return "", "", false, nil
+		case content[i] == ':' && (i+1 == len(content) || content[i+1] == ' '):
+			key := strings.TrimRight(content[:i], " ")
+			if key == "" {
+				return "", "", false, p.errorf("empty mapping key")
+			}
+			if strings.ContainsAny(key[:1], "&*!") {
+				return "", "", false, p.errorf("anchors, aliases and tags are not supported")
+			}
+			return key, content[i+1:], true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// quoted parses the quoted scalar at the start of s and returns its value
+// and the index after the closing quote.
+func (p *yamlParser) quoted(s string) (string, int, error) {
+	var b strings.Builder
+	if s[0] == '\'' {
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+			} else if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+			} else {
+				return b.String(), i + 1, nil
+			}
+		}
+		return "", 0, p.errorf("unterminated single-quoted scalar")
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			if r, ok := yamlEscapes[s[i]]; ok {
+				b.WriteString(r)
+				continue
+			}
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			if size == 0 || i+size >= len(s) {
+				return "", 0, p.errorf("invalid escape in double-quoted scalar")
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) && !utf16Surrogate(code) {
+				return "", 0, p.errorf("invalid escape in double-quoted scalar")
+			}
+			i += size
+			if utf16Surrogate(code) {
+				// A JSON style surrogate pair such as \ud83d\ude00.
+				if code >= 0xdc00 || i+6 >= len(s) || s[i+1:i+3] != "\\u" {
+					return "", 0, p.errorf("invalid surrogate in double-quoted scalar")
+				}
+				low, err := strconv.ParseUint(s[i+3:i+7], 16, 32)
+				if err != nil || low < 0xdc00 || low > 0xdfff {
+					return "", 0, p.errorf("invalid surrogate in double-quoted scalar")
+				}
+				code = 0x10000 + (code-0xd800)<<10 + (low - 0xdc00)
+				i += 6
+			}
+			b.WriteRune(rune(code))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, p.errorf("unterminated double-quoted scalar")
+}
+
+func utf16Surrogate(code uint64) bool { return code >= 0xd800 && code <= 0xdfff }
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+}
+
+// stripComment removes a trailing " # comment" outside quoted scalars.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			if i == 0 || strings.IndexByte(" [{,", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// flowParser parses flow collections and scalars within a single line.
+type flowParser struct {
+	p *yamlParser
+	s string
+	i int
+}
+
+func (f *flowParser) skipSpaces() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *flowParser) value() (*node, error) {
+	f.skipSpaces()
+	if f.i == len(f.s) {
+		return nil, f.p.errorf("missing value")
+	}
+	switch c := f.s[f.i]; c {
+	case '[':
+		f.i++
+		n := &node{kind: sequenceNode}
+		for {
+			f.skipSpaces()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return n, nil
+			}
+			item, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		n := &node{kind: mappingNode}
+		for {
+			f.skipSpaces()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return n, nil
+			}
+			key, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			if key.kind != scalarNode || key.value == "" {
+				return nil, f.p.errorf("invalid flow mapping key")
+			}
+			if n.lookup(key.value) >= 0 {
+				return nil, f.p.errorf("duplicate key %q", key.value)
+			}
+			f.skipSpaces()
+			value := newScalar("null", typeNull)
+			if f.i < len(f.s) && f.s[f.i] == ':' {
+				f.i++
+				f.skipSpaces()
+				if f.i < len(f.s) && f.s[f.i] != ',' && f.s[f.i] != '}' {
+					if value, err = f.value(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			n.entries = append(n.entries, entry{key: key.value, value: value})
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '&', '*', '!':
+		return nil, f.p.errorf("anchors, aliases and tags are not supported")
+	case '@', '`', '%', ']', '}', ',':
+		return nil, f.p.errorf("unexpected %q", c)
+	}
+	return f.scalar(false)
+}
+
+// separator consumes the "," between flow items, or the closing bracket,
+// which is left for the caller.
+func (f *flowParser) separator(end byte) error {
+	f.skipSpaces()
+	if f.i < len(f.s) {
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+			return nil
+		case end:
+			return nil
+		}
+	}
+	return f.p.errorf("expected , or %c in flow collection", end)
+}
+
+// scalar parses a quoted or plain scalar. Keys are always strings.
+func (f *flowParser) scalar(key bool) (*node, error) {
+	if c := f.s[f.i]; c == '"' || c == '\'' {
+		value, end, err := f.p.quoted(f.s[f.i:])
+		if err != nil {
+			return nil, err
+		}
+		f.i += end
+		return newScalar(value, typeString), nil
+	}
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' || c == '[' || c == '{' {
+			break
+		}
+		if c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" ,]}", f.s[f.i+1]) >= 0) {
+			break
+		}
+	}
+	text := strings.TrimSpace(f.s[start:f.i])
+	if key {
+		return newScalar(text, typeString), nil
+	}
+	return newScalar(text, resolvePlain(text)), nil
+}
+
+// emitYAML writes n as a block style YAML document.
+func emitYAML(n *node) []byte {
+	var b strings.Builder
+	switch {
+	case n.kind == mappingNode && len(n.entries) > 0:
+		writeYAMLMapping(&b, n, 0, false)
+	case n.kind == sequenceNode && len(n.items) > 0:
+		writeYAMLSequence(&b, n, 0)
+	default:
+		writeYAMLValue(&b, n, 0)
+		return []byte(strings.TrimPrefix(b.String(), " "))
+	}
+	writeYAMLComments(&b, n.after, 0)
+	return []byte(b.String())
+}
+
+// writeYAMLComments writes comment and blank lines at indent.
+func writeYAMLComments(b *strings.Builder, lines []string, indent int) {
+	for _, line := range lines {
+		if line != "" {
+			b.WriteString(strings.Repeat(" ", indent) + line)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// writeYAMLMapping writes the entries of n. If inline is set, the first key
+// continues the current line.
+func writeYAMLMapping(b *strings.Builder, n *node, indent int, inline bool) {
+	for i, e := range n.entries {
+		if i > 0 || !inline {
+			writeYAMLComments(b, e.before, indent)
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteString(yamlScalar(e.key, typeString) + ":")
+		writeYAMLValue(b, e.value, indent)
+	}
+}
+
+func writeYAMLSequence(b *strings.Builder, n *node, indent int) {
+	for _, item := range n.items {
+		writeYAMLComments(b, item.before, indent)
+		b.WriteString(strings.Repeat(" ", indent) + "-")
+		if item.kind == mappingNode && len(item.entries) > 0 {
+			b.WriteByte(' ')
+			writeYAMLMapping(b, item, indent+2, true)
+			continue
+		}
+		writeYAMLValue(b, item, indent)
+	}
+}
+
+// writeYAMLValue writes the value following a key or "-" at indent.
+func writeYAMLValue(b *strings.Builder, n *node, indent int) {
+	switch {
+	case n.kind == mappingNode && len(n.entries) == 0:
+		b.WriteString(" {}\n")
+	case n.kind == mappingNode:
+		b.WriteByte('\n')
+		writeYAMLMapping(b, n, indent+2, false)
+	case n.kind == sequenceNode && len(n.items) == 0:
+		b.WriteString(" []\n")
+	case n.kind == sequenceNode:
+		b.WriteByte('\n')
+		writeYAMLSequence(b, n, indent+2)
+	case n.typ == typeString && literalBlock(n.value):
+		body := strings.TrimRight(n.value, "\n")
+		switch len(n.value) - len(body) {
+		case 0:
+			b.WriteString(" |-\n")
+		case 1:
+			b.WriteString(" |\n")
+		default:
+			b.WriteString(" |+\n")
+		}
+		for _, line := range strings.Split(body, "\n") {
+			if line != "" {
+				b.WriteString(strings.Repeat(" ", indent+2) + line)
+			}
+			b.WriteByte('\n')
+		}
+		if trailing := len(n.value) - len(body); trailing > 1 {
+			b.WriteString(strings.Repeat("\n", trailing-1))
+		}
+	default:
+		b.WriteString(" " + yamlScalar(n.value, n.typ) + "\n")
+	}
+}
+
+// literalBlock reports whether s is a multi-line string that a literal block
+// scalar reproduces exactly.
+func literalBlock(s string) bool {
+	body := strings.TrimRight(s, "\n")
+	if !strings.Contains(body, "\n") || body[0] == ' ' || body[0] == '\t' {
+		return false
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimRight(line, " \t") != line {
+			return false
+		}
+		for _, r := range line {
+			if r == '\t' {
+				continue
+			}
+			if !unicode.IsPrint(r) || r == '\ufeff' {
+				return false
+			}
+		}
+	}
+	return utf8.ValidString(s)
+}
+
+// yamlScalar returns the plain form of a scalar if it reads back unchanged,
+// and a double-quoted form otherwise.
+func yamlScalar(value string, typ scalarType) string {
+	if typ != typeString {
+		return value
+	}
+	if plainSafe(value) {
+		return value
+	}
+	return jsonString(value)
+}
+
+func plainSafe(s string) bool {
+	if s == "" || resolvePlain(s) != typeString || strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`. ", rune(s[0])) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off":
+		// Booleans in YAML 1.1.
+		return false
+	}
+	if last := s[len(s)-1]; last == ' ' || last == ':' {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) || r == '\ufeff' {
+			return false
+		}
+	}
+	return true
+}
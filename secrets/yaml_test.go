@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"", map[string]any{}},
+		{"---\na: 1 # comment\n...\n", map[string]any{"a": int64(1)}},
+		{"a: 0x1F\nb: 0o17\nc: 017\nd: -1.5e3\ne: .inf\nf: ~\ng: yes\nh: 'it''s'\n", map[string]any{
+			"a": int64(31), "b": int64(15), "c": int64(17), "d": -1500.0, "e": posInf,
+			"f": nil, "g": "yes", "h": "it's",
+		}},
+		{"url: http://example.com/a#b\nquoted: \"tab\\there \\u00e9 \\ud83d\\ude00\"\n", map[string]any{
+			"url": "http://example.com/a#b", "quoted": "tab\there é 😀",
+		}},
+		{"list:\n- a\n- b: 1\n  c: 2\n- - x\n  - y\n-\n", map[string]any{
+			"list": []any{"a", map[string]any{"b": int64(1), "c": int64(2)}, []any{"x", "y"}, nil},
+		}},
+		{"flow: {a: [1, \"two\", {b: c}], d: }\n", map[string]any{
+			"flow": map[string]any{"a": []any{int64(1), "two", map[string]any{"b": "c"}}, "d": nil},
+		}},
+		{"keep: |+\n  a\n\nstrip: |-\n  a\n  b\nfolded: >\n  a\n  b\n\n  c\n    d\n", map[string]any{
+			"keep": "a\n\n", "strip": "a\nb", "folded": "a b\nc\n  d\n",
+		}},
+		{"\"quoted key\": 1\nnested:\n  deeper:\n    value: x\n", map[string]any{
+			"quoted key": int64(1), "nested": map[string]any{"deeper": map[string]any{"value": "x"}},
+		}},
+	}
+	for _, test := range tests {
+		n, err := parseYAML([]byte(test.input))
+		if err != nil {
+			t.Errorf("parseYAML(%q): %v", test.input, err)
+			continue
+		}
+		if got := toValue(n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseYAML(%q) = %#v, want %#v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseYAML_Unsupported(t *testing.T) {
+	for _, input := range []string{
+		"a: &anchor 1\nb: *anchor\n",
+		"a: !!str 1\n",
+		"a: 1\n---\nb: 2\n",
+		"a: 1\na: 2\n",
+		"a: b\n  c\n",
+		"a:\n\tb: 1\n",
+		"a: \"unterminated\n",
+		"? complex\n",
+	} {
+		if _, err := parseYAML([]byte(input)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("parseYAML(%q) = %v, want ErrMalformed", input, err)
+		}
+	}
+}
+
+func TestEmitYAML_RoundTrip(t *testing.T) {
+	root := &node{kind: mappingNode}
+	for _, s := range []string{
+		"plain", "", "true", "123", "yes", "- dash", "a: b", "a #b", "trailing ", "multi\nline",
+		"multi\nline\n", "keep\n\n", " leading\nspace", "tab\tand\x00nul", "ENC[v1,str,abc,def]", "é",
+	} {
+		root.entries = append(root.entries, entry{key: s + "key", value: newScalar(s, typeString)})
+	}
+	root.entries = append(root.entries,
+		entry{key: "empty map", value: &node{kind: mappingNode}},
+		entry{key: "empty list", value: &node{kind: sequenceNode}},
+		entry{key: "list", value: &node{kind: sequenceNode, items: []*node{
+			{kind: mappingNode, entries: []entry{{key: "a", value: newScalar("1", typeInt)}, {key: "b", value: newScalar("x\ny", typeString)}}},
+			{kind: sequenceNode, items: []*node{newScalar("null", typeNull)}},
+		}}},
+	)
+
+	emitted := emitYAML(root)
+	parsed, err := parseYAML(emitted)
+	if err != nil {
+		t.Fatalf("parseYAML: %v\n%s", err, emitted)
+	}
+	if got, want := toValue(parsed), toValue(root); !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip =\n%#v\nwant\n%#v\n%s", got, want, emitted)
+	}
+	if again := emitYAML(parsed); string(again) != string(emitted) {
+		t.Errorf("Second emit differs:\n%s\n%s", again, emitted)
+	}
+}
+
+func TestYAML_Comments(t *testing.T) {
+	input := "# Database settings\n" +
+		"database:\n" +
+		"  # Read from the primary\n" +
+		"  host: db.internal\n" +
+		"\n" +
+		"  ports:\n" +
+		"    # main\n" +
+		"    - 5432\n" +
+		"    - user: app\n" +
+		"      # rotated monthly\n" +
+		"      password: s3cr3t\n" +
+		"notes: |\n" +
+		"  # not a comment\n" +
+		"  either\n" +
+		"# trailing comment\n"
+	n, err := parseYAML([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(emitYAML(n)); got != input {
+		t.Errorf("Comments were not kept:\n%s", got)
+	}
+	if notes := toValue(n).(map[string]any)["notes"]; notes != "# not a comment\neither\n" {
+		t.Errorf("Block scalar = %q", notes)
+	}
+}
+
+var posInf = parseFloat(".inf")
@@ -0,0 +1,288 @@
+package jsencrypt
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownChallenge is returned for a challenge ID that was never
+	// issued or has already been used.
+	ErrUnknownChallenge = errors.New("session keys: unknown or used challenge")
+	// ErrChallengeExpired is returned for a challenge used after its TTL.
+	ErrChallengeExpired = errors.New("session keys: challenge expired")
+	// ErrTooManyChallenges is returned by MemoryChallengeStore.Put when the
+	// store holds its capacity of unexpired challenges.
+	ErrTooManyChallenges = errors.New("session keys: too many outstanding challenges")
+	// ErrBusy is returned by Issue when MaxConcurrentIssues key pairs are
+	// already being generated.
+	ErrBusy = errors.New("session keys: too many key generations in progress")
+)
+
+const (
+	defaultSessionKeyTTL  = 5 * time.Minute
+	defaultSessionKeySize = 2048
+	challengeIDSize       = 16
+
+	// DefaultChallengeCapacity is the capacity of the in-memory store used
+	// when SessionKeys.Store is nil.
+	DefaultChallengeCapacity = 10000
+)
+
+// ChallengeStore holds the private keys of issued challenges.
+// Implementations backed by a shared cache allow the page and the login
+// request to be served by different servers.
+type ChallengeStore interface {
+	// Put stores the DER encoded private key for a challenge until expires.
+	Put(id string, key []byte, expires time.Time) error
+	// Take removes a challenge and returns its key and expiry, or
+	// ErrUnknownChallenge. It must be atomic: of two concurrent calls for
+	// the same challenge, only one may succeed.
+	Take(id string) (key []byte, expires time.Time, err error)
+}
+
+// SessionKeys issues a fresh key pair per login challenge so that a
+// captured ciphertext is worthless once the challenge is used or expired.
+// The page receives the challenge ID and public key from Issue; the login
+// request carries the ID back, and Decrypt decrypts with that challenge's
+// key exactly once.
+//
+// Every Issue generates an RSA key pair, which takes tens of milliseconds of
+// CPU, typically for a request that is not yet authenticated. At most
+// MaxConcurrentIssues key pairs are generated at a time, so a flood of page
+// loads cannot occupy every core: further calls to Issue fail with ErrBusy
+// at once, and IssueContext waits only as long as its context allows. Rate
+// limit the page per client as well.
+//
+// A SessionKeys is safe for concurrent use.
+type SessionKeys struct {
+	// Store holds the issued keys. Default: a MemoryChallengeStore of
+	// DefaultChallengeCapacity using Now as its clock.
+	Store ChallengeStore
+	// TTL is how long an issued challenge may be used. Default: 5 minutes.
+	TTL time.Duration
+	// KeySize is the modulus size of issued keys. Default: 2048.
+	KeySize int
+	// MaxConcurrentIssues limits how many key pairs Issue generates at a
+	// time. Default: a quarter of GOMAXPROCS, at least one.
+	MaxConcurrentIssues int
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+
+	once   sync.Once
+	memory *MemoryChallengeStore
+	slots  chan struct{}
+}
+
+// NewSessionKeys returns a SessionKeys with an in-memory store and the
+// given TTL.
+func NewSessionKeys(ttl time.Duration) *SessionKeys {
+	return &SessionKeys{TTL: ttl}
+}
+
+// Issue generates a key pair for a new challenge and returns the challenge
+// ID and the public key PEM to embed in the page. It fails with ErrBusy
+// instead of waiting when MaxConcurrentIssues key pairs are being generated.
+func (s *SessionKeys) Issue() (challengeID, publicKeyPEM string, err error) {
+	s.init()
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return "", "", ErrBusy
+	}
+	defer func() { <-s.slots }()
+	return s.issue()
+}
+
+// IssueContext is like Issue, but waits for a key generation to finish when
+// MaxConcurrentIssues are in progress. It returns ctx.Err() if ctx is done
+// first; pass the page request's context, with a timeout, so waiting calls
+// do not pile up.
+func (s *SessionKeys) IssueContext(ctx context.Context) (challengeID, publicKeyPEM string, err error) {
+	s.init()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+	defer func() { <-s.slots }()
+	return s.issue()
+}
+
+// issue generates and stores the key pair of a new challenge.
+func (s *SessionKeys) issue() (challengeID, publicKeyPEM string, err error) {
+	id := make([]byte, challengeIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	challengeID = b64url.EncodeToString(id)
+
+	key := NewJSEncrypt()
+	key.DefaultKeySize = defaultSessionKeySize
+	if s.KeySize > 0 {
+		key.DefaultKeySize = s.KeySize
+	}
+	defer key.Close()
+	if publicKeyPEM, err = key.GetPublicKey(); err != nil {
+		return "", "", err
+	}
+	der, err := key.ExportPrivateKey(ExportOptions{Encoding: EncodingDER})
+	if err != nil {
+		return "", "", err
+	}
+	defer wipeBytes(der)
+
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = defaultSessionKeyTTL
+	}
+	if err := s.store().Put(challengeID, der, s.now().Add(ttl)); err != nil {
+		return "", "", err
+	}
+	return challengeID, publicKeyPEM, nil
+}
+
+// Decrypt decrypts a base64 ciphertext with the key of a challenge and
+// discards the key, so the challenge cannot be used again, whether or not
+// decryption succeeds.
+func (s *SessionKeys) Decrypt(challengeID, ciphertext string) (string, error) {
+	der, expires, err := s.store().Take(challengeID)
+	if err != nil {
+		return "", err
+	}
+	defer wipeBytes(der)
+	if !s.now().Before(expires) {
+		return "", ErrChallengeExpired
+	}
+
+	key := NewJSEncrypt()
+	defer key.Close()
+	if err := key.SetPrivateKeyBytes(der); err != nil {
+		return "", err
+	}
+	return key.Decrypt(ciphertext)
+}
+
+// init creates the default store and the keygen slots on first use.
+func (s *SessionKeys) init() {
+	s.once.Do(func() {
+		s.memory = NewMemoryChallengeStore(DefaultChallengeCapacity)
+		s.memory.now = s.now
+		n := s.MaxConcurrentIssues
+		if n <= 0 {
+			if n = runtime.GOMAXPROCS(0) / 4; n < 1 {
+				n = 1
+			}
+		}
+		s.slots = make(chan struct{}, n)
+	})
+}
+
+func (s *SessionKeys) store() ChallengeStore {
+	if s.Store != nil {
+		return s.Store
+	}
+	s.init()
+	return s.memory
+}
+
+func (s *SessionKeys) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// MemoryChallengeStore is an in-memory ChallengeStore holding up to a fixed
+// number of challenges. Expired challenges are removed, and their keys
+// wiped, in the order they were stored whenever a new one is stored; when
+// the store is still full, Put fails with ErrTooManyChallenges rather than
+// evict a challenge a user may be about to answer.
+//
+// A MemoryChallengeStore is safe for concurrent use.
+type MemoryChallengeStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front was stored first
+	now      func() time.Time
+}
+
+type challengeEntry struct {
+	id      string
+	key     []byte
+	expires time.Time
+}
+
+// NewMemoryChallengeStore returns an empty store holding at most capacity
+// challenges.
+func NewMemoryChallengeStore(capacity int) *MemoryChallengeStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryChallengeStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Put implements ChallengeStore. It keeps a copy of key.
+func (s *MemoryChallengeStore) Put(id string, key []byte, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Challenges usually share one TTL, so the oldest expire first and the
+	// sweep stops at the first live one.
+	now := s.now()
+	for elem := s.order.Front(); elem != nil; elem = s.order.Front() {
+		if now.Before(elem.Value.(*challengeEntry).expires) {
+			break
+		}
+		s.remove(elem)
+	}
+	if elem, ok := s.entries[id]; ok {
+		s.remove(elem)
+	}
+	if s.order.Len() >= s.capacity {
+		return ErrTooManyChallenges
+	}
+	s.entries[id] = s.order.PushBack(&challengeEntry{id: id, key: append([]byte(nil), key...), expires: expires})
+	return nil
+}
+
+// Take implements ChallengeStore. The caller owns the returned key.
+func (s *MemoryChallengeStore) Take(id string) ([]byte, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return nil, time.Time{}, ErrUnknownChallenge
+	}
+	entry := elem.Value.(*challengeEntry)
+	delete(s.entries, id)
+	s.order.Remove(elem)
+	return entry.key, entry.expires, nil
+}
+
+// Len returns the number of challenges currently held.
+func (s *MemoryChallengeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// remove drops a challenge and wipes its key.
+func (s *MemoryChallengeStore) remove(elem *list.Element) {
+	entry := elem.Value.(*challengeEntry)
+	wipeBytes(entry.key)
+	delete(s.entries, entry.id)
+	s.order.Remove(elem)
+}
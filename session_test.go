@@ -0,0 +1,178 @@
+package jsencrypt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encryptFor(t *testing.T, publicKeyPEM, plaintext string) string {
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetPublicKey(publicKeyPEM); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := jsCrypt.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func TestSessionKeys(t *testing.T) {
+	sessions := NewSessionKeys(time.Minute)
+	sessions.KeySize = 1024
+
+	id, pem, err := sessions.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, otherPEM, err := sessions.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == otherID || pem == otherPEM {
+		t.Fatal("Every challenge needs its own ID and key")
+	}
+
+	ciphertext := encryptFor(t, pem, "hunter2")
+	if _, err := sessions.Decrypt(otherID, ciphertext); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Decrypt with another challenge's key: got %v, want ErrDecryption", err)
+	}
+	if _, err := sessions.Decrypt(otherID, encryptFor(t, otherPEM, "x")); !errors.Is(err, ErrUnknownChallenge) {
+		t.Errorf("A failed decryption must still consume the challenge, got %v", err)
+	}
+
+	plaintext, err := sessions.Decrypt(id, ciphertext)
+	if err != nil || plaintext != "hunter2" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+	if _, err := sessions.Decrypt(id, ciphertext); !errors.Is(err, ErrUnknownChallenge) {
+		t.Errorf("Replayed ciphertext: got %v, want ErrUnknownChallenge", err)
+	}
+	if _, err := sessions.Decrypt("never-issued", ciphertext); !errors.Is(err, ErrUnknownChallenge) {
+		t.Errorf("Unknown challenge: got %v, want ErrUnknownChallenge", err)
+	}
+}
+
+func TestSessionKeys_Expiry(t *testing.T) {
+	// The default store shares the clock of its SessionKeys.
+	now := time.Now()
+	sessions := &SessionKeys{TTL: time.Minute, KeySize: 1024, Now: func() time.Time { return now }}
+
+	id, pem, err := sessions.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := sessions.Decrypt(id, encryptFor(t, pem, "late")); !errors.Is(err, ErrChallengeExpired) {
+		t.Errorf("Expired challenge: got %v, want ErrChallengeExpired", err)
+	}
+
+	// Unused challenges are dropped once expired.
+	if _, _, err := sessions.Issue(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, _, err := sessions.Issue(); err != nil {
+		t.Fatal(err)
+	}
+	if n := sessions.memory.Len(); n != 1 {
+		t.Errorf("Expected expired challenges to be removed, have %d", n)
+	}
+}
+
+func TestMemoryChallengeStore_Capacity(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryChallengeStore(2)
+	store.now = func() time.Time { return now }
+
+	if err := store.Put("a", []byte("key a"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("b", []byte("key b"), now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("c", []byte("key c"), now.Add(2*time.Minute)); !errors.Is(err, ErrTooManyChallenges) {
+		t.Fatalf("Put into a full store: got %v, want ErrTooManyChallenges", err)
+	}
+	if _, _, err := store.Take("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("c", []byte("key c"), now.Add(2*time.Minute)); err != nil {
+		t.Errorf("Put after Take: %v", err)
+	}
+
+	// Expired challenges make room.
+	now = now.Add(2 * time.Minute)
+	if err := store.Put("d", []byte("key d"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected expired challenges to be removed, have %d", store.Len())
+	}
+	if key, _, err := store.Take("d"); err != nil || string(key) != "key d" {
+		t.Errorf("Take = %q, %v", key, err)
+	}
+}
+
+func TestSessionKeys_MaxConcurrentIssues(t *testing.T) {
+	sessions := &SessionKeys{KeySize: 1024, MaxConcurrentIssues: 1}
+	sessions.init()
+
+	// Hold the only slot: Issue fails at once, IssueContext waits.
+	sessions.slots <- struct{}{}
+	if _, _, err := sessions.Issue(); !errors.Is(err, ErrBusy) {
+		t.Errorf("Issue with no free slot: got %v, want ErrBusy", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := sessions.IssueContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("IssueContext past its deadline: got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := sessions.IssueContext(context.Background())
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("IssueContext generated a key beyond MaxConcurrentIssues")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-sessions.slots
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sessions.Issue(); err != nil {
+		t.Errorf("Issue with a free slot: %v", err)
+	}
+}
+
+func TestSessionKeys_ConcurrentDecrypt(t *testing.T) {
+	sessions := &SessionKeys{KeySize: 1024}
+	id, pem, err := sessions.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := encryptFor(t, pem, "once")
+
+	var wg sync.WaitGroup
+	var successes int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sessions.Decrypt(id, ciphertext); err == nil {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if successes != 1 {
+		t.Errorf("Expected exactly one successful decryption, got %d", successes)
+	}
+}
@@ -0,0 +1,115 @@
+package jsencrypt
+
+import (
+	"encoding/binary"
+)
+
+// signcryptionContext is prepended to the signed data so signatures made
+// for SealFor cannot be confused with signatures made by Sign.
+const signcryptionContext = "jsencrypt signcryption v1"
+
+// signcryptionScheme is the signature scheme used by SealFor.
+var signcryptionScheme = SchemePSSSHA256
+
+// SealFor signs plaintext with j's private key and encrypts it for
+// recipient's public key, returning one compact token:
+//
+//	<sender kid>.<recipient kid>.<wrapped key>.<nonce>.<ciphertext>
+//
+// The payload is encrypted with the hybrid scheme (AES-256-GCM, content key
+// wrapped with RSA-OAEP-SHA256), so it is not limited by the key size. The
+// signature is RSA-PSS-SHA256 over both key IDs and the plaintext, so a
+// recipient cannot re-encrypt the signed message for a third party and
+// present it as having been sent to them (surreptitious forwarding).
+func (j *JSEncrypt) SealFor(recipient *JSEncrypt, plaintext []byte) (string, error) {
+	senderKID, err := j.KeyID()
+	if err != nil {
+		return "", err
+	}
+	recipientKID, err := recipient.KeyID()
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := j.SignWith(signcryptionSignedData(senderKID, recipientKID, plaintext), signcryptionScheme)
+	if err != nil {
+		return "", err
+	}
+	inner := make([]byte, 2, 2+len(signature)+len(plaintext))
+	binary.BigEndian.PutUint16(inner, uint16(len(signature)))
+	inner = append(append(inner, signature...), plaintext...)
+
+	header := senderKID + "." + recipientKID
+	key, nonce, ciphertext, err := sealContent(inner, []byte(header))
+	if err != nil {
+		return "", err
+	}
+	defer wipeBytes(key)
+	wrapped, err := recipient.WrapContentKey(key)
+	if err != nil {
+		return "", err
+	}
+	return header + "." + b64url.EncodeToString(wrapped) + "." + b64url.EncodeToString(nonce) + "." + b64url.EncodeToString(ciphertext), nil
+}
+
+// OpenFrom decrypts a token produced by SealFor with j's private key and
+// verifies that it was signed by sender and addressed to j. It returns
+// ErrKeyIDMismatch if the token names other keys, ErrDecryption if it
+// cannot be decrypted, and rsa.ErrVerification if the signature is invalid.
+func (j *JSEncrypt) OpenFrom(sender *JSEncrypt, token string) ([]byte, error) {
+	parts, err := splitCompact(token, 5)
+	if err != nil {
+		return nil, err
+	}
+	senderKID, err := sender.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	recipientKID, err := j.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	if parts[0] != senderKID || parts[1] != recipientKID {
+		return nil, ErrKeyIDMismatch
+	}
+
+	segments, err := decodeSegments(parts[2:])
+	if err != nil {
+		return nil, err
+	}
+	key, err := j.UnwrapContentKey(segments[0])
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(key)
+	inner, err := openContent(key, segments[1], segments[2], []byte(parts[0]+"."+parts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inner) < 2 {
+		return nil, ErrDecryption
+	}
+	sigLen := int(binary.BigEndian.Uint16(inner))
+	if len(inner) < 2+sigLen {
+		return nil, ErrDecryption
+	}
+	signature, plaintext := inner[2:2+sigLen], inner[2+sigLen:]
+	if err := sender.VerifyWith(signcryptionSignedData(senderKID, recipientKID, plaintext), signature, signcryptionScheme); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// signcryptionSignedData binds both key IDs to the plaintext. Key IDs are
+// base64url and cannot contain the zero separator.
+func signcryptionSignedData(senderKID, recipientKID string, plaintext []byte) []byte {
+	data := make([]byte, 0, len(signcryptionContext)+len(senderKID)+len(recipientKID)+3+len(plaintext))
+	data = append(data, signcryptionContext...)
+	data = append(data, 0)
+	data = append(data, senderKID...)
+	data = append(data, 0)
+	data = append(data, recipientKID...)
+	data = append(data, 0)
+	return append(data, plaintext...)
+}
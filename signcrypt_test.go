@@ -0,0 +1,109 @@
+package jsencrypt
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSEncrypt_SealForOpenFrom(t *testing.T) {
+	alice := newTestKey(t, exampleTestKeys.privateKey)
+	bob := newTestKey(t, testPrivateKeys[3])
+
+	// Each side only needs the other's public key.
+	alicePublic, bobPublic := NewJSEncrypt(), NewJSEncrypt()
+	if err := alicePublic.SetPublicKey(exampleTestKeys.publicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := bobPublic.SetPublicKey(testPublicKeys[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte("larger than any RSA block "), 400)
+	token, err := alice.SealFor(bobPublic, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(token, "."); n != 4 {
+		t.Fatalf("Expected a 5 segment token, got %d separators", n)
+	}
+	plaintext, err := bob.OpenFrom(alicePublic, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, payload) {
+		t.Error("Decrypted payload does not match")
+	}
+
+	// Wrong expected sender, or a token for someone else.
+	if _, err := bob.OpenFrom(bobPublic, token); !errors.Is(err, ErrKeyIDMismatch) {
+		t.Errorf("Expected ErrKeyIDMismatch for the wrong sender, got %v", err)
+	}
+	if _, err := alice.OpenFrom(alicePublic, token); !errors.Is(err, ErrKeyIDMismatch) {
+		t.Errorf("Expected ErrKeyIDMismatch for the wrong recipient, got %v", err)
+	}
+
+	// Tampering with any segment is detected.
+	parts := strings.Split(token, ".")
+	ciphertext := []byte(parts[4])
+	ciphertext[10] ^= 'A' ^ 'B'
+	parts[4] = string(ciphertext)
+	if _, err := bob.OpenFrom(alicePublic, strings.Join(parts, ".")); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for a tampered token, got %v", err)
+	}
+	if _, err := bob.OpenFrom(alicePublic, "a.b.c"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for a malformed token, got %v", err)
+	}
+}
+
+func TestJSEncrypt_OpenFromRejectsForwarding(t *testing.T) {
+	alice := newTestKey(t, exampleTestKeys.privateKey)
+	bob := newTestKey(t, testPrivateKeys[3])
+	carol := NewJSEncrypt()
+	if _, err := carol.GetPrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := alice.SealFor(bob, []byte("for bob only"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob decrypts the signed inner message and re-encrypts it, unchanged,
+	// for Carol under a header claiming Alice sent it to her.
+	parts := strings.Split(token, ".")
+	segments, err := decodeSegments(parts[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := bob.UnwrapContentKey(segments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := openContent(key, segments[1], segments[2], []byte(parts[0]+"."+parts[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sigLen := binary.BigEndian.Uint16(inner); int(sigLen) != alice.privateKey.Size() {
+		t.Fatalf("Unexpected signature length %d", sigLen)
+	}
+
+	carolKID, _ := carol.KeyID()
+	header := parts[0] + "." + carolKID
+	key, nonce, ciphertext, err := sealContent(inner, []byte(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := carol.WrapContentKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarded := header + "." + b64url.EncodeToString(wrapped) + "." + b64url.EncodeToString(nonce) + "." + b64url.EncodeToString(ciphertext)
+
+	if _, err := carol.OpenFrom(alice, forwarded); !errors.Is(err, rsa.ErrVerification) {
+		t.Errorf("Forwarded message must fail signature verification, got %v", err)
+	}
+}
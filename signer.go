@@ -0,0 +1,170 @@
+package jsencrypt
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"io"
+)
+
+// ErrExternalKey is returned by operations that need the private key
+// material itself, such as ExportPrivateKey and GetPKCS12, when the private
+// key is held by an external signer or decrypter.
+var ErrExternalKey = errors.New("private key is held by an external signer or decrypter")
+
+// SetSigner backs SignWith and Sign with an external RSA signer, such as a
+// key held in an HSM, a PKCS#11 module or a remote signing service. The
+// public key is taken from s.Public() and replaces any key currently set.
+//
+// A decrypter set earlier is kept only if it belongs to the same key.
+func (j *JSEncrypt) SetSigner(s crypto.Signer) error {
+	pub, ok := s.Public().(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signer does not hold an RSA key")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+	if j.decrypter != nil && !publicKeysEqual(pub, j.publicKey) {
+		j.decrypter = nil
+	}
+	j.privateKey = nil
+	j.publicKey = pub
+	j.signer = s
+	return nil
+}
+
+// SetDecrypter backs DecryptWith and Decrypt with an external RSA
+// decrypter. The public key is taken from d.Public() and replaces any key
+// currently set.
+//
+// A signer set earlier is kept only if it belongs to the same key.
+func (j *JSEncrypt) SetDecrypter(d crypto.Decrypter) error {
+	pub, ok := d.Public().(*rsa.PublicKey)
+	if !ok {
+		return errors.New("decrypter does not hold an RSA key")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+	if j.signer != nil && !publicKeysEqual(pub, j.publicKey) {
+		j.signer = nil
+	}
+	j.privateKey = nil
+	j.publicKey = pub
+	j.decrypter = d
+	return nil
+}
+
+// Signer returns a crypto.Signer backed by this instance, for use with
+// crypto/tls, x509.CreateCertificate and similar APIs. Signatures go through
+// the same key, external signer and Policy as SignWith. PSS is used when
+// opts is an *rsa.PSSOptions, PKCS#1 v1.5 otherwise.
+//
+// JSEncrypt cannot implement crypto.Signer itself because its Sign method
+// keeps the string based signature of the JavaScript library.
+func (j *JSEncrypt) Signer() crypto.Signer {
+	return jsSigner{j}
+}
+
+// Decrypter returns a crypto.Decrypter backed by this instance. OAEP is used
+// when opts is an *rsa.OAEPOptions, PKCS#1 v1.5 otherwise.
+func (j *JSEncrypt) Decrypter() crypto.Decrypter {
+	return jsDecrypter{j}
+}
+
+type jsSigner struct{ j *JSEncrypt }
+
+// Public returns the *rsa.PublicKey, or nil if no key could be generated.
+func (s jsSigner) Public() crypto.PublicKey {
+	pub, err := s.j.ensurePublicKey()
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+func (s jsSigner) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	scheme := Scheme{Padding: PaddingPKCS1v15, Hash: opts.HashFunc()}
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		scheme.Padding = PaddingPSS
+	}
+	return s.j.signDigest(random, digest, scheme, opts)
+}
+
+type jsDecrypter struct{ j *JSEncrypt }
+
+// Public returns the *rsa.PublicKey, or nil if no key could be generated.
+func (d jsDecrypter) Public() crypto.PublicKey {
+	return jsSigner(d).Public()
+}
+
+func (d jsDecrypter) Decrypt(random io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	scheme := SchemePKCS1v15
+	if oaep, ok := opts.(*rsa.OAEPOptions); ok {
+		scheme = Scheme{Padding: PaddingOAEP, Hash: oaep.Hash, Label: oaep.Label}
+	}
+	return d.j.decrypt(random, ciphertext, scheme, opts)
+}
+
+// signDigest checks the policy and signs digest with the in-memory key or
+// the external signer.
+func (j *JSEncrypt) signDigest(random io.Reader, digest []byte, scheme Scheme, opts crypto.SignerOpts) ([]byte, error) {
+	keys, err := j.openKeys()
+	if err != nil {
+		return nil, err
+	}
+	var signer crypto.Signer = keys.signer
+	if signer == nil {
+		priv, release, err := j.getKey()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		signer = priv
+	}
+	pub, _ := signer.Public().(*rsa.PublicKey)
+	if err := j.Policy.check(opSign, pub, scheme); err != nil {
+		return nil, err
+	}
+	return signer.Sign(random, digest, opts)
+}
+
+// decrypt checks the policy and decrypts with the in-memory key or the
+// external decrypter. Every failure of an in-memory decryption is caused by
+// the ciphertext and is reported as ErrDecryption. An external decrypter may
+// also fail for its own reasons, e.g. an unreachable HSM, so only its padding
+// failures are mapped to ErrDecryption and other errors are returned as is.
+func (j *JSEncrypt) decrypt(random io.Reader, ciphertext []byte, scheme Scheme, opts crypto.DecrypterOpts) ([]byte, error) {
+	keys, err := j.openKeys()
+	if err != nil {
+		return nil, err
+	}
+	var decrypter crypto.Decrypter = keys.decrypter
+	if decrypter == nil {
+		priv, release, err := j.getKey()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		decrypter = priv
+	}
+	pub, _ := decrypter.Public().(*rsa.PublicKey)
+	if err := j.Policy.check(opDecrypt, pub, scheme); err != nil {
+		return nil, err
+	}
+	plaintext, err := decrypter.Decrypt(random, ciphertext, opts)
+	if err != nil {
+		if keys.decrypter == nil || errors.Is(err, rsa.ErrDecryption) {
+			return nil, ErrDecryption
+		}
+		return nil, err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,205 @@
+package jsencrypt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// externalKey stands in for an HSM or remote signing service: it only
+// exposes crypto.Signer and crypto.Decrypter and counts operations.
+type externalKey struct {
+	priv            *rsa.PrivateKey
+	signs, decrypts int
+	// decryptErr, if set, is returned by Decrypt.
+	decryptErr error
+}
+
+func (e *externalKey) Public() crypto.PublicKey { return &e.priv.PublicKey }
+
+func (e *externalKey) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	e.signs++
+	return e.priv.Sign(random, digest, opts)
+}
+
+func (e *externalKey) Decrypt(random io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	e.decrypts++
+	if e.decryptErr != nil {
+		return nil, e.decryptErr
+	}
+	return e.priv.Decrypt(random, ciphertext, opts)
+}
+
+func newExternalKey(t *testing.T) *externalKey {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	return &externalKey{priv: jsCrypt.privateKey}
+}
+
+func TestJSEncrypt_ExternalSignerAndDecrypter(t *testing.T) {
+	hsm := newExternalKey(t)
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetSigner(hsm); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsCrypt.SetDecrypter(hsm); err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := jsCrypt.Sign("signed in hardware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := jsCrypt.Encrypt("decrypted in hardware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := jsCrypt.Decrypt(ciphertext)
+	if err != nil || plaintext != "decrypted in hardware" {
+		t.Fatalf("Decrypt() = %q, %v", plaintext, err)
+	}
+	oaep, err := jsCrypt.EncryptWith([]byte("oaep"), SchemeOAEPSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jsCrypt.DecryptWith(oaep, SchemeOAEPSHA256); err != nil {
+		t.Fatalf("OAEP decryption through the decrypter failed: %v", err)
+	}
+	if hsm.signs != 1 || hsm.decrypts != 2 {
+		t.Errorf("Expected 1 sign and 2 decrypts on the external key, got %d and %d", hsm.signs, hsm.decrypts)
+	}
+
+	// A software instance with the same public key verifies the signature.
+	verifier := NewJSEncrypt()
+	if err := verifier.SetPublicKey(exampleTestKeys.publicKey); err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := verifier.Verify("signed in hardware", signature); err != nil || !valid {
+		t.Errorf("Signature from the external signer should verify: %v", err)
+	}
+
+	// The private key material is not available, and no key is generated.
+	if _, err := jsCrypt.ExportPrivateKey(ExportOptions{}); !errors.Is(err, ErrExternalKey) {
+		t.Errorf("Expected ErrExternalKey, got %v", err)
+	}
+
+	// The policy still applies to external keys.
+	jsCrypt.Policy = StrictPolicy()
+	if _, err := jsCrypt.Sign("weak"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Expected policy violation, got %v", err)
+	}
+	if hsm.signs != 1 {
+		t.Error("The external signer must not be called when the policy rejects the operation")
+	}
+}
+
+func TestJSEncrypt_SignerOnly(t *testing.T) {
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetSigner(newExternalKey(t)); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := jsCrypt.Encrypt("msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jsCrypt.Decrypt(ciphertext); !errors.Is(err, ErrExternalKey) {
+		t.Errorf("Decrypting without a decrypter should fail with ErrExternalKey, got %v", err)
+	}
+
+	// Setting an in-memory key replaces the external signer.
+	if err := jsCrypt.SetPrivateKey(testPrivateKeys[3]); err != nil {
+		t.Fatal(err)
+	}
+	if jsCrypt.signer != nil {
+		t.Error("SetPrivateKey should clear the external signer")
+	}
+}
+
+func TestJSEncrypt_AsCryptoSigner(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	signer := jsCrypt.Signer()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jsencrypt signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatalf("CreateCertificate with JSEncrypt signer failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		t.Errorf("Self-signed certificate does not verify: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("pss"))
+	pss := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	signature, err := signer.Sign(rand.Reader, digest[:], pss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rsa.VerifyPSS(jsCrypt.publicKey, crypto.SHA256, digest[:], signature, pss); err != nil {
+		t.Errorf("PSS signature from the crypto.Signer adapter does not verify: %v", err)
+	}
+}
+
+func TestJSEncrypt_AsCryptoDecrypter(t *testing.T) {
+	jsCrypt := newTestKey(t, exampleTestKeys.privateKey)
+	decrypter := jsCrypt.Decrypter()
+	pub := decrypter.Public().(*rsa.PublicKey)
+
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, []byte("oaep"), []byte("label"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := decrypter.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")})
+	if err != nil || string(plaintext) != "oaep" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+
+	ciphertext, err = rsa.EncryptPKCS1v15(rand.Reader, pub, []byte("pkcs1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := decrypter.Decrypt(rand.Reader, ciphertext, nil); err != nil || string(plaintext) != "pkcs1" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+}
+
+func TestJSEncrypt_ExternalDecrypterErrors(t *testing.T) {
+	hsm := newExternalKey(t)
+	jsCrypt := NewJSEncrypt()
+	if err := jsCrypt.SetDecrypter(hsm); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := jsCrypt.Encrypt("msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jsCrypt.Decrypt(tamper(t, ciphertext)); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Invalid padding: got %v, want ErrDecryption", err)
+	}
+
+	// Failures of the decrypter itself are not disguised as bad ciphertexts.
+	unavailable := errors.New("hsm: session closed")
+	hsm.decryptErr = unavailable
+	if _, err := jsCrypt.Decrypt(ciphertext); !errors.Is(err, unavailable) {
+		t.Errorf("Decrypter failure: got %v, want %v", err, unavailable)
+	}
+	hsm.decryptErr = rsa.ErrDecryption
+	if _, err := jsCrypt.Decrypt(ciphertext); !errors.Is(err, ErrDecryption) {
+		t.Errorf("Decrypter padding failure: got %v, want ErrDecryption", err)
+	}
+}
@@ -0,0 +1,90 @@
+package jsencrypt
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+// sshRSA is the OpenSSH key type name of RSA keys.
+const sshRSA = "ssh-rsa"
+
+// ExportSSHPublicKey returns the public key as an OpenSSH authorized_keys
+// line, "ssh-rsa <base64> <comment>\n", as written by ssh-keygen. The
+// comment is omitted when empty.
+func (j *JSEncrypt) ExportSSHPublicKey(comment string) ([]byte, error) {
+	pub, err := j.ensurePublicKey()
+	if err != nil {
+		return nil, err
+	}
+	line := sshRSA + " " + base64.StdEncoding.EncodeToString(sshPublicBlob(pub))
+	if comment != "" {
+		line += " " + comment
+	}
+	return []byte(line + "\n"), nil
+}
+
+// SetSSHPublicKey sets the public key from an OpenSSH public key: a single
+// authorized_keys line or the contents of an id_rsa.pub file. Lines with
+// options before the key type are not accepted.
+func (j *JSEncrypt) SetSSHPublicKey(data []byte) error {
+	fields := bytes.Fields(data)
+	if len(fields) < 2 || string(fields[0]) != sshRSA {
+		return errors.New("ssh: not an ssh-rsa public key")
+	}
+	blob, err := base64.StdEncoding.DecodeString(string(fields[1]))
+	if err != nil {
+		return errors.New("ssh: invalid base64 key data")
+	}
+
+	// Public blob: string "ssh-rsa", mpint e, mpint n.
+	r := sshReader(blob)
+	if alg := r.readString(); r.err == nil && string(alg) != sshRSA {
+		return errors.New("ssh: key type mismatch")
+	}
+	e := r.readMPInt()
+	n := r.readMPInt()
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.buf) != 0 {
+		return errors.New("ssh: trailing data after public key")
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return errors.New("ssh: public exponent too large")
+	}
+	return j.setPublicKey(&rsa.PublicKey{N: n, E: int(e.Int64())})
+}
+
+// FingerprintSSH returns the fingerprint printed by "ssh-keygen -l":
+// "SHA256:" followed by the unpadded base64 SHA-256 digest of the SSH wire
+// format public key.
+func (j *JSEncrypt) FingerprintSSH() (string, error) {
+	pub, err := j.identityKey()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(sshPublicBlob(pub))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// sshPublicBlob encodes pub in the SSH wire format (RFC 4253, section 6.6).
+func sshPublicBlob(pub *rsa.PublicKey) []byte {
+	var blob []byte
+	blob = append(blob, sshString([]byte(sshRSA))...)
+	blob = append(blob, sshMPInt(big.NewInt(int64(pub.E)))...)
+	return append(blob, sshMPInt(pub.N)...)
+}
+
+// sshMPInt encodes a non-negative n as an SSH wire format mpint: big-endian
+// with a leading zero byte when the high bit is set.
+func sshMPInt(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return sshString(b)
+}
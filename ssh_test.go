@@ -0,0 +1,51 @@
+package jsencrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+// Output of "ssh-keygen -y" and "ssh-keygen -l" for testPrivateKeys[3].
+const (
+	testSSHPublicKey   = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQC5LO5xVlO9g4PL1xdWudnihIAPbMsixr396bIbBIwKBul98UWQ3UALbqByq2bXVuoIbl48UokxOVstenGCyyo026NFh3Fg6Cnvj9ptvbmqk2i3eTOBrt+e26Z1sepsnQL5OojiVIbrWwS6v1pFCXpnnLLvyy6GPt/kftbhazH3oQ=="
+	testSSHFingerprint = "SHA256:tYvmyoGbVUA6REHUhdY5KEzygcIQ5MjVFpQ+eP7H3Og"
+)
+
+func TestExportSSHPublicKey(t *testing.T) {
+	key := newTestKey(t, testPrivateKeys[3])
+	out, err := key.ExportSSHPublicKey("")
+	if err != nil || string(out) != testSSHPublicKey+"\n" {
+		t.Errorf("ExportSSHPublicKey = %q, %v", out, err)
+	}
+	out, _ = key.ExportSSHPublicKey("ops@example")
+	if string(out) != testSSHPublicKey+" ops@example\n" {
+		t.Errorf("With comment = %q", out)
+	}
+	if fp, err := key.FingerprintSSH(); err != nil || fp != testSSHFingerprint {
+		t.Errorf("FingerprintSSH = %q, %v", fp, err)
+	}
+}
+
+func TestSetSSHPublicKey(t *testing.T) {
+	key := NewJSEncrypt()
+	if err := key.SetSSHPublicKey([]byte(testSSHPublicKey + " some comment\n")); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := newTestKey(t, testPrivateKeys[3]).GetPublicKey()
+	if got, _ := key.GetPublicKey(); got != want {
+		t.Errorf("Imported key differs:\n%s", got)
+	}
+
+	blob := strings.Fields(testSSHPublicKey)[1]
+	for _, bad := range []string{
+		"",
+		"ssh-ed25519 " + blob,
+		"ssh-rsa !!!",
+		"ssh-rsa " + blob[:40],
+		`command="ls" ssh-rsa ` + blob,
+	} {
+		if err := NewJSEncrypt().SetSSHPublicKey([]byte(bad)); err == nil {
+			t.Errorf("SetSSHPublicKey(%.30q) should fail", bad)
+		}
+	}
+}
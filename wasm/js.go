@@ -0,0 +1,66 @@
+//go:build js && wasm
+
+package wasm
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// Register sets the global name (usually "JSEncrypt") to a constructor
+// taking the browser library's options: default_key_size,
+// default_public_exponent and log. Failed operations are reported with
+// console.error when log is true. default_public_exponent is accepted for
+// compatibility but ignored: keys are always generated with exponent 65537.
+//
+// Each object holds its own method functions, which are released only
+// when the program exits, so create one object per key rather than per
+// operation.
+func Register(name string) {
+	js.Global().Set(name, js.FuncOf(construct))
+}
+
+func construct(_ js.Value, args []js.Value) any {
+	console := js.Global().Get("console")
+	logf := func(format string, args ...any) {
+		console.Call("error", "JSEncrypt: "+fmt.Sprintf(format, args...))
+	}
+
+	var opts options
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		o := args[0]
+		switch size := o.Get("default_key_size"); size.Type() {
+		case js.TypeNumber:
+			opts.defaultKeySize = size.Int()
+		case js.TypeString:
+			if bits, err := parseKeySize(size.String()); err == nil {
+				opts.defaultKeySize = bits
+			} else {
+				logf("%v", err)
+			}
+		}
+		if exp := o.Get("default_public_exponent"); exp.Type() == js.TypeString {
+			opts.defaultPublicExponent = exp.String()
+		}
+		opts.log = o.Get("log").Truthy()
+	}
+
+	s := newShim(opts, logf)
+	obj := js.Global().Get("Object").New()
+	for name, m := range methods {
+		m := m
+		obj.Set(name, js.FuncOf(func(_ js.Value, args []js.Value) any {
+			strs := make([]string, len(args))
+			for i, a := range args {
+				if a.Type() == js.TypeString {
+					strs[i] = a.String()
+				}
+			}
+			if result := m(s, strs); result != nil {
+				return result
+			}
+			return js.Undefined()
+		}))
+	}
+	return obj
+}
@@ -0,0 +1,200 @@
+// Package wasm exposes JSEncrypt to JavaScript from a js/wasm build, so
+// browsers and Go services share one implementation. Register installs a
+// JSEncrypt constructor with the methods of the browser library:
+//
+//	const crypt = new JSEncrypt({ default_key_size: 2048 });
+//	crypt.setPublicKey(pem);
+//	const ciphertext = crypt.encrypt("secret"); // false on failure
+//
+// The shim itself is plain Go; only Register depends on syscall/js.
+package wasm
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// options are the options of the JSEncrypt constructor.
+type options struct {
+	defaultKeySize        int
+	defaultPublicExponent string
+	log                   bool
+}
+
+// shim backs one JavaScript JSEncrypt object.
+type shim struct {
+	key *jsencrypt.JSEncrypt
+	// logf reports errors when the "log" option is set, as JSEncrypt does
+	// with console.
+	logf func(format string, args ...any)
+}
+
+func newShim(opts options, logf func(format string, args ...any)) *shim {
+	key := jsencrypt.NewJSEncrypt()
+	if opts.defaultKeySize > 0 {
+		key.DefaultKeySize = opts.defaultKeySize
+	}
+	key.DefaultPublicExp = opts.defaultPublicExponent
+	key.Log = opts.log
+	if !opts.log {
+		logf = func(string, ...any) {}
+	}
+	return &shim{key: key, logf: logf}
+}
+
+// method is a JSEncrypt method. Arguments that are not strings in
+// JavaScript arrive as "". The result is a string, a bool or nil
+// (undefined).
+type method func(s *shim, args []string) any
+
+// methods are named as in the browser library. Like JSEncrypt, operations
+// return false instead of throwing when they fail.
+var methods = map[string]method{
+	"setKey":           (*shim).setKey,
+	"setPublicKey":     (*shim).setKey,
+	"setPrivateKey":    (*shim).setKey,
+	"encrypt":          (*shim).encrypt,
+	"decrypt":          (*shim).decrypt,
+	"sign":             (*shim).sign,
+	"verify":           (*shim).verify,
+	"signSha256":       (*shim).signSha256,
+	"verifySha256":     (*shim).verifySha256,
+	"getPublicKey":     (*shim).getPublicKey,
+	"getPrivateKey":    (*shim).getPrivateKey,
+	"getPublicKeyB64":  (*shim).getPublicKeyB64,
+	"getPrivateKeyB64": (*shim).getPrivateKeyB64,
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// result returns value, or false after logging err.
+func (s *shim) result(value string, err error) any {
+	if err != nil {
+		s.logf("%v", err)
+		return false
+	}
+	return value
+}
+
+// setKey sets a PEM key. JSEncrypt parses private and public keys with
+// each of its setters, so all three do the same. It returns false if the
+// key cannot be parsed.
+func (s *shim) setKey(args []string) any {
+	if err := s.key.SetKey(arg(args, 0)); err != nil {
+		s.logf("%v", err)
+		return false
+	}
+	return nil
+}
+
+func (s *shim) encrypt(args []string) any {
+	return s.result(s.key.Encrypt(arg(args, 0)))
+}
+
+func (s *shim) decrypt(args []string) any {
+	return s.result(s.key.Decrypt(arg(args, 0)))
+}
+
+// digests are the digestName values accepted by sign, and the hashes
+// verify recognizes.
+var digests = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha224": crypto.SHA224,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// sign implements sign(str, digestMethod, digestName). The digest is
+// computed in Go from digestName, so the digestMethod function is ignored.
+// digestName defaults to "sha256".
+func (s *shim) sign(args []string) any {
+	name := strings.ToLower(arg(args, 2))
+	if name == "" {
+		name = "sha256"
+	}
+	hash, ok := digests[name]
+	if !ok {
+		s.logf("unsupported digest %q", name)
+		return false
+	}
+	signature, err := s.key.SignWith([]byte(arg(args, 0)), jsencrypt.Scheme{Padding: jsencrypt.PaddingPKCS1v15, Hash: hash})
+	if err != nil {
+		s.logf("%v", err)
+		return false
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// verify implements verify(str, signature, digestMethod). Like JSEncrypt it
+// takes the digest algorithm from the signature, so any of the digests sign
+// accepts verifies.
+func (s *shim) verify(args []string) any {
+	signature, err := base64.StdEncoding.DecodeString(arg(args, 1))
+	if err != nil {
+		s.logf("signature is not base64")
+		return false
+	}
+	for _, name := range []string{"sha256", "sha1", "sha384", "sha512", "sha224"} {
+		scheme := jsencrypt.Scheme{Padding: jsencrypt.PaddingPKCS1v15, Hash: digests[name]}
+		if err := s.key.VerifyWith([]byte(arg(args, 0)), signature, scheme); err == nil {
+			return true
+		} else if !errors.Is(err, rsa.ErrVerification) {
+			s.logf("%v", err)
+			return false
+		}
+	}
+	return false
+}
+
+func (s *shim) signSha256(args []string) any {
+	return s.result(s.key.Sign(arg(args, 0)))
+}
+
+func (s *shim) verifySha256(args []string) any {
+	ok, err := s.key.Verify(arg(args, 0), arg(args, 1))
+	if err != nil {
+		s.logf("%v", err)
+	}
+	return ok
+}
+
+// getPublicKey and getPrivateKey return PEM keys, generating a key pair if
+// none is set, as JSEncrypt does.
+func (s *shim) getPublicKey([]string) any {
+	return s.result(s.key.GetPublicKey())
+}
+
+func (s *shim) getPrivateKey([]string) any {
+	return s.result(s.key.GetPrivateKey())
+}
+
+func (s *shim) getPublicKeyB64([]string) any {
+	return s.result(s.key.GetPublicKeyB64())
+}
+
+func (s *shim) getPrivateKeyB64([]string) any {
+	return s.result(s.key.GetPrivateKeyB64())
+}
+
+// parseKeySize accepts the default_key_size option as a number or a
+// numeric string, as JSEncrypt does.
+func parseKeySize(value string) (int, error) {
+	bits, err := strconv.Atoi(value)
+	if err != nil || bits <= 0 {
+		return 0, fmt.Errorf("invalid default_key_size %q", value)
+	}
+	return bits, nil
+}
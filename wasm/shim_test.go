@@ -0,0 +1,146 @@
+package wasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	jsencrypt "github.com/gmodx/go-jsencrypt"
+)
+
+// call invokes a method as JavaScript would.
+func call(t *testing.T, s *shim, name string, args ...string) any {
+	t.Helper()
+	m, ok := methods[name]
+	if !ok {
+		t.Fatalf("no method %s", name)
+	}
+	return m(s, args)
+}
+
+func TestMethods_BrowserNames(t *testing.T) {
+	for _, name := range []string{
+		"setPublicKey", "setPrivateKey", "encrypt", "decrypt",
+		"sign", "verify", "getPublicKey", "getPrivateKey",
+	} {
+		if _, ok := methods[name]; !ok {
+			t.Errorf("Missing JSEncrypt method %s", name)
+		}
+	}
+}
+
+func TestShim_EncryptDecrypt(t *testing.T) {
+	private := newShim(options{}, nil)
+	privatePEM, ok := call(t, private, "getPrivateKey").(string)
+	if !ok || !strings.Contains(privatePEM, "RSA PRIVATE KEY") {
+		t.Fatalf("getPrivateKey = %v", privatePEM)
+	}
+	public := newShim(options{}, nil)
+	if result := call(t, public, "setPublicKey", call(t, private, "getPublicKey").(string)); result != nil {
+		t.Fatalf("setPublicKey = %v", result)
+	}
+
+	ciphertext, ok := call(t, public, "encrypt", "hello").(string)
+	if !ok {
+		t.Fatal("encrypt failed")
+	}
+	if plaintext := call(t, private, "decrypt", ciphertext); plaintext != "hello" {
+		t.Errorf("decrypt = %v", plaintext)
+	}
+
+	// Ciphertexts are those of the Go library.
+	key := jsencrypt.NewJSEncrypt()
+	if err := key.SetPrivateKey(privatePEM); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := key.Decrypt(ciphertext); err != nil || plaintext != "hello" {
+		t.Errorf("Library Decrypt = %q, %v", plaintext, err)
+	}
+
+	if result := call(t, private, "decrypt", "not base64"); result != false {
+		t.Errorf("decrypt of garbage = %v, want false", result)
+	}
+	if result := call(t, public, "setPrivateKey", "not a key"); result != false {
+		t.Errorf("setPrivateKey of garbage = %v, want false", result)
+	}
+}
+
+func TestShim_SignVerify(t *testing.T) {
+	private := newShim(options{}, nil)
+	public := newShim(options{}, nil)
+	call(t, public, "setPublicKey", call(t, private, "getPublicKey").(string))
+
+	for _, digest := range []string{"", "sha1", "sha256", "SHA512"} {
+		// The digestMethod argument is a function in JavaScript and arrives
+		// as "".
+		signature, ok := call(t, private, "sign", "message", "", digest).(string)
+		if !ok {
+			t.Fatalf("sign with %q failed", digest)
+		}
+		if result := call(t, public, "verify", "message", signature, ""); result != true {
+			t.Errorf("verify of a %q signature = %v", digest, result)
+		}
+		if result := call(t, public, "verify", "messagE", signature, ""); result != false {
+			t.Errorf("verify of a tampered message = %v", result)
+		}
+	}
+	if result := call(t, private, "sign", "message", "", "md4"); result != false {
+		t.Errorf("sign with an unsupported digest = %v", result)
+	}
+
+	signature := call(t, private, "signSha256", "message").(string)
+	if result := call(t, public, "verifySha256", "message", signature); result != true {
+		t.Errorf("verifySha256 = %v", result)
+	}
+	if result := call(t, private, "sign", "message", "", "sha256"); result != signature {
+		t.Errorf("sign with sha256 differs from signSha256")
+	}
+}
+
+func TestShim_Options(t *testing.T) {
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+
+	s := newShim(options{defaultKeySize: 512, defaultPublicExponent: "03", log: true}, logf)
+	call(t, s, "getPrivateKey")
+	info, err := s.key.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// default_public_exponent is accepted but, as in the Go library, keys
+	// are always generated with exponent 65537.
+	if info.Bits != 512 || info.PublicExponent != 65537 {
+		t.Errorf("Generated key: %d bits, exponent %d", info.Bits, info.PublicExponent)
+	}
+	call(t, s, "decrypt", "AAAA")
+	if len(logged) != 1 {
+		t.Errorf("log: true logged %q", logged)
+	}
+
+	quiet := newShim(options{}, logf)
+	call(t, quiet, "decrypt", "AAAA")
+	if len(logged) != 1 {
+		t.Errorf("Errors were logged without the log option: %q", logged)
+	}
+
+	if bits, err := parseKeySize("2048"); err != nil || bits != 2048 {
+		t.Errorf("parseKeySize = %d, %v", bits, err)
+	}
+	if _, err := parseKeySize("big"); err == nil {
+		t.Error("parseKeySize accepted a non-number")
+	}
+}
+
+func TestMethods_MissingArguments(t *testing.T) {
+	// Every method must accept missing arguments, as JavaScript allows.
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := newShim(options{defaultKeySize: 512}, nil)
+	for _, name := range names {
+		call(t, s, name)
+	}
+}
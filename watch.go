@@ -0,0 +1,166 @@
+package jsencrypt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher polls a key file or directory and reloads the keys when its
+// contents change. A failed reload, e.g. of a half written or corrupt file,
+// keeps the previous keys and is reported to the error callback once per
+// change. A file or directory that cannot be read is likewise reported once,
+// not on every poll, until it can be read again.
+type Watcher struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// WatchKeyFile loads the key at path into j and then checks the file every
+// interval, replacing the key of j when the contents change. The replacement
+// is atomic: concurrent operations on j use either the old or the new key.
+//
+// onError, which may be nil, is called from the watcher goroutine.
+func WatchKeyFile(j *JSEncrypt, path string, interval time.Duration, onError func(error)) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, errWatchInterval
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := reloadKeyFile(j, path, data); err != nil {
+		return nil, err
+	}
+
+	last := sha256.Sum256(data)
+	var readErr repeatFilter
+	return startWatcher(interval, onError, func() error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return readErr.filter(err)
+		}
+		readErr.clear()
+		sum := sha256.Sum256(data)
+		if sum == last {
+			return nil
+		}
+		last = sum
+		return reloadKeyFile(j, path, data)
+	}), nil
+}
+
+// WatchKeyDir loads the key files in dir into ring as LoadKeyDir does, and
+// then checks the directory every interval. When a key file is added,
+// removed or changed, the contents of ring are replaced atomically and the
+// most recently modified key becomes active again.
+//
+// onError, which may be nil, is called from the watcher goroutine.
+func WatchKeyDir(ring *Keyring, dir string, interval time.Duration, onError func(error)) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, errWatchInterval
+	}
+	files, err := keyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := keyringFromFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	ring.replace(loaded)
+
+	last := keyFilesDigest(files)
+	var readErr repeatFilter
+	return startWatcher(interval, onError, func() error {
+		files, err := keyFiles(dir)
+		if err != nil {
+			return readErr.filter(err)
+		}
+		readErr.clear()
+		sum := keyFilesDigest(files)
+		if sum == last {
+			return nil
+		}
+		last = sum
+		loaded, err := keyringFromFiles(files)
+		if err != nil {
+			return err
+		}
+		ring.replace(loaded)
+		return nil
+	}), nil
+}
+
+// Close stops the watcher and waits for a reload in progress to finish.
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+var errWatchInterval = errors.New("watch interval must be positive")
+
+// repeatFilter drops an error that repeats on consecutive polls, so a file
+// that stays missing or unreadable is reported once rather than every tick.
+type repeatFilter struct {
+	last string
+}
+
+// filter returns err, or nil if it is the error of the previous poll.
+func (f *repeatFilter) filter(err error) error {
+	if err.Error() == f.last {
+		return nil
+	}
+	f.last = err.Error()
+	return err
+}
+
+// clear records a successful poll.
+func (f *repeatFilter) clear() {
+	f.last = ""
+}
+
+func startWatcher(interval time.Duration, onError func(error), poll func() error) *Watcher {
+	w := &Watcher{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if err := poll(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// reloadKeyFile parses data into a fresh instance and swaps its keys into j,
+// leaving j untouched on error.
+func reloadKeyFile(j *JSEncrypt, path string, data []byte) error {
+	fresh := NewJSEncrypt()
+	if err := fresh.setKeyData(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return j.setKeys(fresh.keys())
+}
+
+func keyFilesDigest(files []keyFile) [sha256.Size]byte {
+	h := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(h, "%d:%s%d:", len(file.path), file.path, len(file.data))
+		h.Write(file.data)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}